@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// affinityCPUs returns the IDs of the CPUs the process may run on. Under
+// taskset or a container cpuset these are not 0 to NumCPU-1, for example
+// 4-7. Where the list cannot be read, those IDs are assumed.
+func affinityCPUs() []int {
+	if cpus, err := allowedCPUs(); err == nil && len(cpus) > 0 {
+		return cpus
+	}
+	cpus := make([]int, runtime.NumCPU())
+	for i := range cpus {
+		cpus[i] = i
+	}
+	return cpus
+}
+
+// affinityCore is the logical core thread threadID is pinned to with
+// -affinity-stride, counted through the allowed cpus. Where SMT siblings are
+// numbered next to each other, stride 1 packs the threads onto them and
+// stride 2 spreads them over physical cores.
+func affinityCore(threadID, stride int, cpus []int) int {
+	return cpus[threadID*stride%len(cpus)]
+}
+
+// formatCoreAssignment lists the core of every thread, such as "0→0, 1→2"
+func formatCoreAssignment(threads, stride int, cpus []int) string {
+	assignments := make([]string, threads)
+	for i := range assignments {
+		assignments[i] = fmt.Sprintf("%d→%d", i, affinityCore(i, stride, cpus))
+	}
+	return strings.Join(assignments, ", ")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// CPUs covered by the affinity mask passed to the kernel
+const affinityMaskCPUs = 1024
+
+type cpuMask [affinityMaskCPUs / 64]uint64
+
+// allowedCPUs reads the IDs of the CPUs in the process's affinity mask
+func allowedCPUs() ([]int, error) {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return nil, errno
+	}
+	var cpus []int
+	for cpu := 0; cpu < affinityMaskCPUs; cpu++ {
+		if mask[cpu/64]&(1<<(cpu%64)) != 0 {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// pinThread locks the calling goroutine to its OS thread and restricts that
+// thread to core. The goroutine keeps the thread until it exits.
+func pinThread(core int) error {
+	if core < 0 || core >= affinityMaskCPUs {
+		return fmt.Errorf("core %d is outside the %d CPUs of the affinity mask", core, affinityMaskCPUs)
+	}
+	runtime.LockOSThread()
+	var mask cpuMask
+	mask[core/64] |= 1 << (core % 64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
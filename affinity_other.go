@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func allowedCPUs() ([]int, error) {
+	return nil, errors.New("thread affinity is only supported on Linux")
+}
+
+func pinThread(core int) error {
+	return errors.New("thread affinity is only supported on Linux")
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestAffinityCore(t *testing.T) {
+	eight := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		threadID int
+		stride   int
+		cpus     []int
+		expected int
+	}{
+		{0, 1, eight, 0},
+		{3, 1, eight, 3},
+		{3, 2, eight, 6},
+		// Threads wrap around once they run out of cores
+		{4, 2, eight, 0},
+		{5, 2, eight, 2},
+		{2, 3, eight, 6},
+		{3, 3, eight, 1},
+		{5, 1, []int{0}, 0},
+		// Under a cpuset of 4-7 the threads stay on those IDs
+		{0, 1, []int{4, 5, 6, 7}, 4},
+		{3, 2, []int{4, 5, 6, 7}, 6},
+		{1, 1, []int{2, 9}, 9},
+	}
+
+	for _, test := range tests {
+		if core := affinityCore(test.threadID, test.stride, test.cpus); core != test.expected {
+			t.Errorf("affinityCore(%d, %d, %v) = %d, expected %d", test.threadID, test.stride, test.cpus, core, test.expected)
+		}
+	}
+}
+
+func TestFormatCoreAssignment(t *testing.T) {
+	if result := formatCoreAssignment(3, 2, []int{0, 1, 2, 3}); result != "0→0, 1→2, 2→0" {
+		t.Errorf("formatCoreAssignment(3, 2, 0-3) = %q, expected \"0→0, 1→2, 2→0\"", result)
+	}
+	if result := formatCoreAssignment(2, 1, []int{4, 5}); result != "0→4, 1→5" {
+		t.Errorf("formatCoreAssignment(2, 1, 4-5) = %q, expected \"0→4, 1→5\"", result)
+	}
+}
+
+func TestAffinityCPUs(t *testing.T) {
+	cpus := affinityCPUs()
+	if len(cpus) == 0 {
+		t.Fatal("affinityCPUs() = [], expected at least the CPU running the test")
+	}
+	for i := 1; i < len(cpus); i++ {
+		if cpus[i] <= cpus[i-1] {
+			t.Errorf("affinityCPUs() = %v, expected ascending IDs", cpus)
+		}
+	}
+}
+
+func TestPinThreadOutOfRange(t *testing.T) {
+	if err := pinThread(1 << 20); err == nil {
+		t.Error("pinThread(1048576) = nil, expected an error")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a temporary file next to path, named after pattern
+// as in os.CreateTemp, and renames it over path, so readers and a crash see
+// either the previous file or the complete new one
+func writeFileAtomic(path, pattern string, write func(io.Writer) error) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), pattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if err := write(tempFile); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600, but collectors and other tools usually run as
+	// another user
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	write := func(content string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		}
+	}
+
+	if err := writeFileAtomic(path, ".test_*.tmp", write("first")); err != nil {
+		t.Fatalf("writeFileAtomic() returned error: %v", err)
+	}
+	if err := writeFileAtomic(path, ".test_*.tmp", write("second")); err != nil {
+		t.Fatalf("writeFileAtomic() returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Errorf("ReadFile() = %q, %v, expected the second write", data, err)
+	}
+	if info, err := os.Stat(path); runtime.GOOS != "windows" && (err != nil || info.Mode().Perm() != 0644) {
+		t.Errorf("Stat() = %v, %v, expected mode 0644", info, err)
+	}
+
+	// A failed write keeps the previous file and leaves no temporary file
+	failed := errors.New("disk full")
+	if err := writeFileAtomic(path, ".test_*.tmp", func(w io.Writer) error { return failed }); !errors.Is(err, failed) {
+		t.Errorf("writeFileAtomic() = %v, expected %v", err, failed)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "second" {
+		t.Errorf("ReadFile() after a failed write = %q, %v, expected the previous content", data, err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir() = %v, %v, expected only the written file", entries, err)
+	}
+}
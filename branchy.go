@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// The classic branch prediction benchmark: 32K random bytes, half of them
+// at or above the threshold
+const (
+	branchyArraySize = 32 * 1024
+	branchyThreshold = 128
+	branchyPasses    = 100
+)
+
+// sumAboveThreshold returns the sum and count of the values at or above
+// threshold. Updating two results in the branch keeps the compiler from
+// replacing it with a conditional move, which would hide mispredictions.
+func sumAboveThreshold(values []int32, threshold int32) (int64, int) {
+	var sum int64
+	count := 0
+	for _, v := range values {
+		if v >= threshold {
+			sum += int64(v)
+			count++
+		}
+	}
+	return sum, count
+}
+
+func branchyValues(size int, sorted bool, seed int64) []int32 {
+	rng := rand.New(rand.NewSource(seed))
+	values := make([]int32, size)
+	for i := range values {
+		values[i] = int32(rng.Intn(256))
+	}
+	if sorted {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	}
+	return values
+}
+
+// newBranchyIteration counts one operation per array element visited. With
+// -branchy-sorted the branch becomes predictable, so the gap between the two
+// runs shows the cost of mispredictions.
+func newBranchyIteration(threadID int, config Config) func() int {
+	values := branchyValues(branchyArraySize, config.branchySorted, workloadSeed(threadID, config))
+	var total int64
+	return func() int {
+		for pass := 0; pass < branchyPasses; pass++ {
+			// Use both results so inlining cannot drop one of the updates
+			sum, count := sumAboveThreshold(values, branchyThreshold)
+			total += sum + int64(count)
+		}
+		return branchyPasses * len(values)
+	}
+}
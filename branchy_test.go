@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSumAboveThreshold(t *testing.T) {
+	values := []int32{0, 127, 128, 200, 255, 3, 128}
+	sum, count := sumAboveThreshold(values, 128)
+	if sum != 128+200+255+128 || count != 4 {
+		t.Errorf("sumAboveThreshold() = %d, %d, expected %d, 4", sum, count, 128+200+255+128)
+	}
+	if sum, count := sumAboveThreshold(nil, 128); sum != 0 || count != 0 {
+		t.Errorf("sumAboveThreshold(nil) = %d, %d, expected 0, 0", sum, count)
+	}
+}
+
+func TestBranchyValuesSortedSameSum(t *testing.T) {
+	// Sorting must change only the order, never the result
+	unsortedSum, unsortedCount := sumAboveThreshold(branchyValues(1000, false, 42), branchyThreshold)
+	sortedSum, sortedCount := sumAboveThreshold(branchyValues(1000, true, 42), branchyThreshold)
+	if unsortedSum != sortedSum || unsortedCount != sortedCount {
+		t.Errorf("Sorted data summed to %d (%d values), unsorted to %d (%d values)",
+			sortedSum, sortedCount, unsortedSum, unsortedCount)
+	}
+}
+
+func TestBranchyIterationOps(t *testing.T) {
+	if ops := newBranchyIteration(0, Config{})(); ops != branchyPasses*branchyArraySize {
+		t.Errorf("Branchy iteration reported %d ops, expected %d", ops, branchyPasses*branchyArraySize)
+	}
+}
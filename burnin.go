@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// burnInCollapseRatio is how far below its running average a disk
+// iteration's throughput may drop before burn-in counts it as a failure
+const burnInCollapseRatio = 0.1
+
+// burnInMinIterations is how many iterations make up a trustworthy average
+const burnInMinIterations = 3
+
+type Failure struct {
+	Time      time.Time
+	Component string
+	Message   string
+}
+
+// FailureLog collects errors hit by the benchmarks. Each failure is printed
+// as it happens, to stderr if that is set; with -burn-in any of them fails
+// the run.
+type FailureLog struct {
+	stderr bool
+
+	mu       sync.Mutex
+	failures []Failure
+}
+
+// Record prints the failure as "<component>: <message>" and keeps it. A nil
+// log only prints.
+func (l *FailureLog) Record(component, format string, args ...interface{}) {
+	failure := Failure{Time: time.Now(), Component: component, Message: fmt.Sprintf(format, args...)}
+	if l == nil {
+		fmt.Printf("%s: %s\n", failure.Component, failure.Message)
+		return
+	}
+	if l.stderr {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", failure.Component, failure.Message)
+	} else {
+		fmt.Printf("%s: %s\n", failure.Component, failure.Message)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures = append(l.failures, failure)
+}
+
+func (l *FailureLog) Failures() []Failure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Failure(nil), l.failures...)
+}
+
+// errorExitCode is the exit status of a run whose benchmarks hit errors, to
+// tell it apart from status 1 for a failed verdict or upload
+const errorExitCode = 2
+
+// benchmarkErrors returns the failures of the benchmarks themselves. A failed
+// upload only fails the run with -post-results-required.
+func benchmarkErrors(failures []Failure) []Failure {
+	var errors []Failure
+	for _, failure := range failures {
+		if failure.Component != "Upload" {
+			errors = append(errors, failure)
+		}
+	}
+	return errors
+}
+
+// formatErrorSubsystems lists every subsystem with its number of errors in
+// the order of their first error, such as "Disk (3), Memory (1)"
+func formatErrorSubsystems(errors []Failure) string {
+	var subsystems []string
+	counts := make(map[string]int)
+	for _, failure := range errors {
+		if counts[failure.Component] == 0 {
+			subsystems = append(subsystems, failure.Component)
+		}
+		counts[failure.Component]++
+	}
+	for i, subsystem := range subsystems {
+		subsystems[i] = fmt.Sprintf("%s (%d)", subsystem, counts[subsystem])
+	}
+	return strings.Join(subsystems, ", ")
+}
+
+// runExitCode is the exit status of a finished run. failed covers the
+// verdicts that exit with 1. Benchmark errors exit with errorExitCode,
+// except with -burn-in, whose verdict already counts them.
+func runExitCode(config Config, failures []Failure, failed bool) int {
+	if !config.burnIn && len(benchmarkErrors(failures)) > 0 {
+		return errorExitCode
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// applyBurnIn turns on the aggressive settings of -burn-in, leaving alone
+// the flags the user set explicitly
+func applyBurnIn(config Config, explicit map[string]bool) Config {
+	if !explicit["memory-percent"] {
+		config.memoryPercent = 0.95
+	}
+	if !explicit["cpu-threads"] {
+		config.cpuThreads = runtime.NumCPU()
+	}
+	if !explicit["mem-verify"] {
+		config.memVerify = true
+	}
+	// Direct I/O reads every block back from the device and checks it. Where
+	// it is not available the sequential disk test compares a checksum of
+	// what it reads back instead.
+	if !explicit["disk-direct-io-verify"] && directIOSupported && !directVerifyConflict(config) && !config.diskSparse &&
+		diskBlockSize(config)%directIOAlignment == 0 {
+		config.diskDirectVerify = true
+	}
+	return config
+}
+
+// throughputCollapsed reports whether current has fallen far below the
+// average of the previous iterations
+func throughputCollapsed(current, average float64, iterations int) bool {
+	return iterations > burnInMinIterations && current < average*burnInCollapseRatio
+}
+
+// printBurnInResult prints PASS or FAIL with every failure and its time,
+// and reports whether the run passed
+func printBurnInResult(failures []Failure, elapsed time.Duration, timestampTZ string) bool {
+	if len(failures) == 0 {
+		fmt.Printf("Burn-in: PASS, no failures in %v\n", elapsed.Round(time.Second))
+		return true
+	}
+
+	first := failures[0]
+	fmt.Printf("Burn-in: FAIL, %d failures in %v, first at %s: %s: %s\n", len(failures), elapsed.Round(time.Second),
+		formatTimestamp(first.Time, timestampTZ), first.Component, first.Message)
+	for _, failure := range failures {
+		fmt.Printf("  %s %s: %s\n", formatTimestamp(failure.Time, timestampTZ), failure.Component, failure.Message)
+	}
+	return false
+}
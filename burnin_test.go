@@ -0,0 +1,138 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFailureLogRecord(t *testing.T) {
+	failures := &FailureLog{}
+	failures.Record("Disk", "Write error: %v", "input/output error")
+	failures.Record("Memory", "%d mismatches while verifying the allocation", 3)
+
+	recorded := failures.Failures()
+	if len(recorded) != 2 {
+		t.Fatalf("Failures() returned %d failures, expected 2", len(recorded))
+	}
+	if recorded[0].Component != "Disk" || recorded[0].Message != "Write error: input/output error" {
+		t.Errorf("First failure = %+v, expected the disk write error", recorded[0])
+	}
+	if recorded[0].Time.IsZero() {
+		t.Errorf("Failure was recorded without a timestamp")
+	}
+
+	// A nil log still prints but keeps nothing
+	var none *FailureLog
+	none.Record("Disk", "Read error: %v", "EOF")
+}
+
+func TestApplyBurnIn(t *testing.T) {
+	config := applyBurnIn(Config{memoryPercent: 0.9, cpuThreads: 0}, map[string]bool{})
+	if config.memoryPercent != 0.95 || config.cpuThreads != runtime.NumCPU() || !config.memVerify {
+		t.Errorf("applyBurnIn() = %+v, expected 95%% memory, all cores and -mem-verify", config)
+	}
+
+	explicit := map[string]bool{"memory-percent": true, "cpu-threads": true, "mem-verify": true, "disk-direct-io-verify": true}
+	config = applyBurnIn(Config{memoryPercent: 0.5, cpuThreads: 2, chunkSizeMB: 100, diskRWMix: -1, diskRotateFiles: 1}, explicit)
+	if config.memoryPercent != 0.5 || config.cpuThreads != 2 || config.memVerify || config.diskDirectVerify {
+		t.Errorf("applyBurnIn() overrode explicit flags: %+v", config)
+	}
+}
+
+func TestApplyBurnInDiskVerify(t *testing.T) {
+	tests := []struct {
+		config   Config
+		expected bool
+	}{
+		{Config{chunkSizeMB: 100, diskRWMix: -1, diskRotateFiles: 1}, directIOSupported},
+		{Config{chunkSizeMB: 100, diskRWMix: 70, diskRotateFiles: 1}, false},
+		{Config{chunkSizeMB: 100, diskRWMix: -1, diskRotateFiles: 1, diskBlockSize: 1000}, false},
+		{Config{chunkSizeMB: 100, diskRWMix: -1, diskRotateFiles: 1, diskSparse: true}, false},
+	}
+
+	for _, test := range tests {
+		if result := applyBurnIn(test.config, map[string]bool{}).diskDirectVerify; result != test.expected {
+			t.Errorf("applyBurnIn(%+v).diskDirectVerify = %v, expected %v", test.config, result, test.expected)
+		}
+	}
+}
+
+func TestThroughputCollapsed(t *testing.T) {
+	tests := []struct {
+		current, average float64
+		iterations       int
+		expected         bool
+	}{
+		{5, 100, 10, true},
+		{50, 100, 10, false},
+		{5, 100, 2, false}, // Too few iterations for a stable average
+	}
+
+	for _, test := range tests {
+		if collapsed := throughputCollapsed(test.current, test.average, test.iterations); collapsed != test.expected {
+			t.Errorf("throughputCollapsed(%v, %v, %d) = %v, expected %v",
+				test.current, test.average, test.iterations, collapsed, test.expected)
+		}
+	}
+}
+
+func TestPrintBurnInResult(t *testing.T) {
+	if !printBurnInResult(nil, time.Hour, "utc") {
+		t.Errorf("printBurnInResult() without failures should pass")
+	}
+	failures := []Failure{{Time: time.Now(), Component: "Disk", Message: "Write error: input/output error"}}
+	if printBurnInResult(failures, time.Hour, "utc") {
+		t.Errorf("printBurnInResult() with a failure should fail")
+	}
+}
+
+func TestFormatErrorSubsystems(t *testing.T) {
+	failures := []Failure{
+		{Component: "Disk", Message: "Write error"},
+		{Component: "Memory", Message: "1 mismatches while verifying the allocation"},
+		{Component: "Upload", Message: "Error posting results"},
+		{Component: "Disk", Message: "Read error"},
+	}
+	errors := benchmarkErrors(failures)
+	if len(errors) != 3 {
+		t.Fatalf("benchmarkErrors() kept %d failures, expected 3 without the upload", len(errors))
+	}
+	if result := formatErrorSubsystems(errors); result != "Disk (2), Memory (1)" {
+		t.Errorf("formatErrorSubsystems() = %q, expected \"Disk (2), Memory (1)\"", result)
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	// Inject a disk error: the benchmark cannot create its file
+	config := Config{diskPath: filepath.Join(t.TempDir(), "missing"), diskFileSize: 1024 * 1024, diskRWMix: -1,
+		diskMode: "rewrite", diskRotateFiles: 1, diskWorkers: 1, diskPattern: "random", chunkSizeMB: 1, reportInterval: 3600}
+	failures := &FailureLog{}
+	filesystemBenchmark([][]byte{make([]byte, 1024*1024)}, make(chan struct{}), config, &DiskStats{}, &Metrics{}, failures)
+	if len(failures.Failures()) == 0 {
+		t.Fatal("filesystemBenchmark() in a missing directory recorded no failure")
+	}
+
+	tests := []struct {
+		burnIn   bool
+		failures []Failure
+		failed   bool
+		expected int
+	}{
+		{false, failures.Failures(), false, errorExitCode},
+		{false, failures.Failures(), true, errorExitCode},
+		// Burn-in's verdict already covers the errors
+		{true, failures.Failures(), true, 1},
+		{false, []Failure{{Component: "Upload"}}, false, 0},
+		{false, []Failure{{Component: "Upload"}}, true, 1},
+		{false, nil, false, 0},
+	}
+
+	for _, test := range tests {
+		if code := runExitCode(Config{burnIn: test.burnIn}, test.failures, test.failed); code != test.expected {
+			t.Errorf("runExitCode(burnIn=%v, %d failures, failed=%v) = %d, expected %d",
+				test.burnIn, len(test.failures), test.failed, code, test.expected)
+		}
+	}
+}
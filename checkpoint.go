@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// saveCheckpoint replaces path with the summary atomically, so a crash
+// leaves the previous checkpoint rather than a partial one
+func saveCheckpoint(path string, summary Summary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, ".perf_test_checkpoint_*.tmp", func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// checkpointWriter saves the summary of the run so far every interval and
+// once more when stopChan closes
+func checkpointWriter(stopChan <-chan struct{}, interval time.Duration, path string, currentSummary func() Summary) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			if err := saveCheckpoint(path, currentSummary()); err != nil {
+				fmt.Printf("Checkpoint: Error writing %s: %v\n", path, err)
+			}
+			return
+		case <-ticker.C:
+			if err := saveCheckpoint(path, currentSummary()); err != nil {
+				fmt.Printf("Checkpoint: Error writing %s: %v\n", path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckpointWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	var builds atomic.Int64
+	currentSummary := func() Summary {
+		n := builds.Add(1)
+		return Summary{SchemaVersion: CurrentSchemaVersion, Host: "test", Metrics: MetricsSnapshot{CPUPrimesPerSec: float64(n)}}
+	}
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		checkpointWriter(stopChan, 20*time.Millisecond, path, currentSummary)
+		close(done)
+	}()
+	time.Sleep(110 * time.Millisecond)
+	close(stopChan)
+	<-done
+
+	// About 5 ticks plus the final checkpoint, with slack for a busy machine
+	if n := builds.Load(); n < 3 || n > 7 {
+		t.Errorf("checkpointWriter() wrote %d checkpoints in 110ms at a 20ms interval, expected about 6", n)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("checkpoint not written: %v", err)
+	}
+	var summary Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("checkpoint is not valid JSON: %v", err)
+	}
+	if summary.Host != "test" || summary.Metrics.CPUPrimesPerSec != float64(builds.Load()) {
+		t.Errorf("checkpoint = %+v, expected the last of %d summaries", summary, builds.Load())
+	}
+
+	// No temp files are left next to the checkpoint
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("checkpoint directory holds %d files, expected only the checkpoint", len(entries))
+	}
+}
+
+func TestSaveCheckpointMissingDir(t *testing.T) {
+	if err := saveCheckpoint(filepath.Join(t.TempDir(), "missing", "checkpoint.json"), Summary{}); err == nil {
+		t.Error("saveCheckpoint() into a missing directory returned no error")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+// Largest -collatz-range. Sequences of starting numbers below it stay far
+// below the uint64 limit.
+const maxCollatzRange = 1000000000
+
+// collatzSteps returns how many steps the Collatz sequence of n takes to
+// reach 1: halve even numbers, map odd ones to 3n+1
+func collatzSteps(n uint64) int {
+	steps := 0
+	for n > 1 {
+		if n%2 == 0 {
+			n /= 2
+		} else {
+			n = 3*n + 1
+		}
+		steps++
+	}
+	return steps
+}
+
+// newCollatzIteration computes the sequence length of every starting number
+// up to -collatz-range, counting each number as an operation. The loop
+// counts depend on the data, so the branch predictor cannot learn them.
+func newCollatzIteration(threadID int, config Config) func() int {
+	longest := 0
+	return func() int {
+		for n := uint64(1); n <= uint64(config.collatzRange); n++ {
+			// Keeping the longest sequence uses every result
+			if steps := collatzSteps(n); steps > longest {
+				longest = steps
+			}
+		}
+		return config.collatzRange
+	}
+}
+
+func formatCollatzRate(numbersPerSec float64, config Config) string {
+	return fmt.Sprintf("%s numbers/sec", formatWithCommas(numbersPerSec))
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestCollatzSteps(t *testing.T) {
+	tests := []struct {
+		n     uint64
+		steps int
+	}{
+		{1, 0},
+		{2, 1},
+		{6, 8},
+		{7, 16},
+		{27, 111},
+		{97, 118},
+		{871, 178},
+		{837799, 524},
+	}
+	for _, test := range tests {
+		if steps := collatzSteps(test.n); steps != test.steps {
+			t.Errorf("collatzSteps(%d) = %d, expected %d", test.n, steps, test.steps)
+		}
+	}
+}
+
+func TestCollatzIteration(t *testing.T) {
+	if ops := newCollatzIteration(0, Config{collatzRange: 1000})(); ops != 1000 {
+		t.Errorf("collatz iteration counted %d numbers, expected 1000", ops)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Flags that control config files themselves and are never part of one
+var configFileFlags = map[string]bool{"config": true, "dump-config": true}
+
+// cpuThreadsValue is -cpu-threads: a thread count or auto-physical
+type cpuThreadsValue struct {
+	config *Config
+}
+
+func (v cpuThreadsValue) String() string {
+	if v.config == nil {
+		return "0"
+	}
+	if v.config.cpuThreadsPhys {
+		return "auto-physical"
+	}
+	return strconv.Itoa(v.config.cpuThreads)
+}
+
+func (v cpuThreadsValue) Set(value string) error {
+	if value == "auto-physical" {
+		v.config.cpuThreads, v.config.cpuThreadsPhys = 0, true
+		return nil
+	}
+	threads, err := strconv.Atoi(value)
+	if err != nil || threads < 0 {
+		return errors.New("must be a thread count or auto-physical")
+	}
+	v.config.cpuThreads, v.config.cpuThreadsPhys = threads, false
+	return nil
+}
+
+// writeConfig prints the value of every flag as a JSON object keyed by flag
+// name, which loadConfigFile reads back. Values are the flags' own string
+// forms, so they parse exactly like the command line; -tag becomes a list.
+func writeConfig(w io.Writer, flags *flag.FlagSet) error {
+	values := make(map[string]interface{})
+	flags.VisitAll(func(f *flag.Flag) {
+		if configFileFlags[f.Name] {
+			return
+		}
+		if tags, ok := f.Value.(*tagsValue); ok {
+			list := []string{}
+			for _, key := range sortedTagKeys(*tags) {
+				list = append(list, key+"="+(*tags)[key])
+			}
+			values[f.Name] = list
+			return
+		}
+		values[f.Name] = f.Value.String()
+	})
+
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+func loadConfigFile(flags *flag.FlagSet, path string, explicit map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := applyConfig(flags, data, explicit); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// applyConfig sets the flags named in a JSON config. Flags given on the
+// command line are left alone so they override the file. Flags the file
+// sets to something other than their default are added to explicit, so
+// modes like -burn-in leave them alone as they do command line flags. A
+// -dump-config file lists every flag, and its defaults stay unset.
+func applyConfig(flags *flag.FlagSet, data []byte, explicit map[string]bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if configFileFlags[name] || flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		var settings []interface{}
+		if list, ok := values[name].([]interface{}); ok {
+			settings = list
+		} else {
+			settings = []interface{}{values[name]}
+		}
+		for _, setting := range settings {
+			var value string
+			switch setting := setting.(type) {
+			case string:
+				value = setting
+			case json.Number:
+				value = setting.String()
+			case bool:
+				value = strconv.FormatBool(setting)
+			default:
+				return fmt.Errorf("flag %q: unsupported value %v", name, setting)
+			}
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("flag %q: %w", name, err)
+			}
+		}
+		if f := flags.Lookup(name); explicit != nil && f.Value.String() != f.DefValue {
+			explicit[name] = true
+		}
+	}
+	return nil
+}
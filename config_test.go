@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{
+			"-cpu-threads", "auto-physical",
+			"-cpu-range-sweep", "1000:4500:1000",
+			"-memory-percent", "0.5",
+			"-disk-file-size", "512MB",
+			"-duration", "90s",
+			"-tag", "rack=a1",
+			"-tag", "dc=fra",
+			"-full",
+		},
+	}
+
+	for _, args := range tests {
+		var original Config
+		flags := flag.NewFlagSet("perf-test", flag.ContinueOnError)
+		registerFlags(flags, &original)
+		if err := flags.Parse(args); err != nil {
+			t.Fatalf("Parse(%q) failed: %v", args, err)
+		}
+
+		var buf bytes.Buffer
+		if err := writeConfig(&buf, flags); err != nil {
+			t.Fatalf("writeConfig failed: %v", err)
+		}
+
+		var loaded Config
+		loadedFlags := flag.NewFlagSet("perf-test", flag.ContinueOnError)
+		registerFlags(loadedFlags, &loaded)
+		if err := applyConfig(loadedFlags, buf.Bytes(), nil); err != nil {
+			t.Fatalf("applyConfig failed for %q: %v\n%s", args, err, buf.String())
+		}
+
+		if !reflect.DeepEqual(loaded, original) {
+			t.Errorf("reloaded config for %q differs:\n got %+v\nwant %+v", args, loaded, original)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	var config Config
+	flags := flag.NewFlagSet("perf-test", flag.ContinueOnError)
+	registerFlags(flags, &config)
+	if err := flags.Parse([]string{"-report-interval", "2"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Plain JSON numbers and booleans are accepted, and the command line wins
+	data := []byte(`{"report-interval": 10, "prime-range": 5000, "full": true}`)
+	if err := applyConfig(flags, data, map[string]bool{"report-interval": true}); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if config.reportInterval != 2 || config.primeRange != 5000 || !config.full {
+		t.Errorf("applyConfig() = report-interval %d, prime-range %d, full %v; expected 2, 5000, true",
+			config.reportInterval, config.primeRange, config.full)
+	}
+
+	// Values from the file count as set, except defaults
+	explicit := map[string]bool{}
+	data = []byte(`{"memory-percent": 0.5, "duration": "0s"}`)
+	if err := applyConfig(flags, data, explicit); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if !explicit["memory-percent"] || explicit["duration"] {
+		t.Errorf("applyConfig() marked %v as set, expected memory-percent but not duration", explicit)
+	}
+	if result := applyBurnIn(config, explicit); result.memoryPercent != 0.5 {
+		t.Errorf("applyBurnIn() after -config = memory-percent %v, expected the file's 0.5", result.memoryPercent)
+	}
+
+	for _, data := range []string{`{"no-such-flag": "1"}`, `{"dump-config": "true"}`, `{"prime-range": "x"}`, `{"full": null}`, `[]`} {
+		if err := applyConfig(flags, []byte(data), nil); err == nil {
+			t.Errorf("applyConfig(%s) expected error", data)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How often the cooldown samples temperature and frequency
+const cooldownSampleInterval = time.Second
+
+var errNoSensor = errors.New("no sensor found")
+
+func parseSysfsInt(data []byte) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readSysfsInts reads the integer in every file matching pattern, skipping
+// files that cannot be read
+func readSysfsInts(pattern string) []int64 {
+	paths, _ := filepath.Glob(pattern)
+	var values []int64
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if value, err := parseSysfsInt(data); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// readCPUTemperature returns the hottest thermal zone in °C. The zones are
+// in millidegrees and only exist on Linux.
+func readCPUTemperature() (float64, error) {
+	zones := readSysfsInts("/sys/class/thermal/thermal_zone*/temp")
+	if len(zones) == 0 {
+		return 0, errNoSensor
+	}
+	hottest := zones[0]
+	for _, zone := range zones[1:] {
+		if zone > hottest {
+			hottest = zone
+		}
+	}
+	return float64(hottest) / 1000, nil
+}
+
+// readCPUFrequency returns the average current frequency of all cores in MHz.
+// cpufreq reports kHz and only exists on Linux.
+func readCPUFrequency() (float64, error) {
+	cores := readSysfsInts("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	if len(cores) == 0 {
+		return 0, errNoSensor
+	}
+	total := int64(0)
+	for _, core := range cores {
+		total += core
+	}
+	return float64(total) / float64(len(cores)) / 1000, nil
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// formatIdleBaseline prints the average of the samples, or n/a without any
+func formatIdleBaseline(samples []float64, format string) string {
+	if len(samples) == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf(format, average(samples))
+}
+
+// runCooldown idles for -cooldown between sequential phases and reports the
+// temperature and frequency the machine settles at, its noise floor. It
+// returns true if interrupted.
+func runCooldown(sigChan <-chan os.Signal, config Config, metrics *Metrics) bool {
+	fmt.Printf("=== Cooldown: %v ===\n", config.cooldown)
+
+	var temps, freqs []float64
+	sample := func() {
+		if temp, err := readCPUTemperature(); err == nil {
+			temps = append(temps, temp)
+		}
+		if freq, err := readCPUFrequency(); err == nil {
+			freqs = append(freqs, freq)
+		}
+	}
+
+	ticker := time.NewTicker(cooldownSampleInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(config.cooldown)
+	defer deadline.Stop()
+
+	sample()
+	interrupted := false
+wait:
+	for {
+		select {
+		case <-ticker.C:
+			sample()
+		case <-deadline.C:
+			break wait
+		case <-sigChan:
+			interrupted = true
+			break wait
+		}
+	}
+
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.IdleTempCelsius = average(temps)
+		snapshot.IdleFreqMHz = average(freqs)
+	})
+	fmt.Printf("Idle baseline: temp %s, freq %s\n",
+		formatIdleBaseline(temps, "%.1f°C"), formatIdleBaseline(freqs, "%.0f MHz"))
+	return interrupted
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSysfsInt(t *testing.T) {
+	value, err := parseSysfsInt([]byte("45000\n"))
+	if err != nil || value != 45000 {
+		t.Errorf("parseSysfsInt(\"45000\\n\") = %d, %v, expected 45000", value, err)
+	}
+	if _, err := parseSysfsInt([]byte("<unknown>\n")); err == nil {
+		t.Errorf("parseSysfsInt() should fail on non-numeric content")
+	}
+}
+
+func TestReadSysfsInts(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"zone0": "41000\n", "zone1": "52500\n", "zone2": "invalid\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write %s: %v", name, err)
+		}
+	}
+
+	values := readSysfsInts(filepath.Join(dir, "zone*"))
+	if len(values) != 2 || values[0] != 41000 || values[1] != 52500 {
+		t.Errorf("readSysfsInts() = %v, expected [41000 52500] without the invalid file", values)
+	}
+}
+
+func TestFormatIdleBaseline(t *testing.T) {
+	if result := formatIdleBaseline([]float64{40, 42, 44}, "%.1f°C"); result != "42.0°C" {
+		t.Errorf("formatIdleBaseline() = %q, expected \"42.0°C\"", result)
+	}
+	if result := formatIdleBaseline(nil, "%.0f MHz"); result != "n/a" {
+		t.Errorf("formatIdleBaseline() without samples = %q, expected \"n/a\"", result)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+// Bytes each crc iteration checksums. Small enough to stay in the L1 or L2
+// cache, so the rate is bound by the checksum, not by memory.
+const crcBufferSize = 64 * 1024
+
+// crcTables holds the polynomials -crc-poly selects. hash/crc32 uses the
+// SSE4.2 CRC32 instruction for Castagnoli where the CPU has it, so comparing
+// the two shows whether hardware CRC is available.
+var crcTables = map[string]*crc32.Table{
+	"ieee":       crc32.IEEETable,
+	"castagnoli": crc32.MakeTable(crc32.Castagnoli),
+}
+
+// crcWorkload is the state of one thread of the crc workload
+type crcWorkload struct {
+	table  *crc32.Table
+	buffer []byte
+	sum    uint32
+}
+
+func newCRCWorkload(config Config) *crcWorkload {
+	w := &crcWorkload{table: crcTables[config.crcPoly], buffer: make([]byte, crcBufferSize)}
+	fillChunk(w.buffer)
+	return w
+}
+
+// iterate checksums the buffer once and returns the bytes processed.
+// Chaining the checksums keeps each iteration's result in use.
+func (w *crcWorkload) iterate() int {
+	w.sum = crc32.Update(w.sum, w.table, w.buffer)
+	return len(w.buffer)
+}
+
+// newCRCIteration checksums a per-thread buffer of random data and counts
+// the bytes processed
+func newCRCIteration(threadID int, config Config) func() int {
+	return newCRCWorkload(config).iterate
+}
+
+func formatCRCRate(bytesPerSec float64, config Config) string {
+	return fmt.Sprintf("%s checksummed", formatBandwidth(bytesPerSec, config))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestCRCWorkload(t *testing.T) {
+	tests := []struct {
+		poly  string
+		table *crc32.Table
+	}{
+		{"ieee", crc32.IEEETable},
+		{"castagnoli", crc32.MakeTable(crc32.Castagnoli)},
+	}
+
+	for _, test := range tests {
+		workload := newCRCWorkload(Config{crcPoly: test.poly})
+		for i := 0; i < 2; i++ {
+			if bytes := workload.iterate(); bytes != crcBufferSize {
+				t.Errorf("%s iteration counted %d bytes, expected %d", test.poly, bytes, crcBufferSize)
+			}
+		}
+		// Two iterations chain the checksum of the buffer with the -crc-poly table
+		expected := crc32.Update(crc32.Update(0, test.table, workload.buffer), test.table, workload.buffer)
+		if workload.sum != expected {
+			t.Errorf("%s checksum after 2 iterations = %#x, expected %#x", test.poly, workload.sum, expected)
+		}
+	}
+}
+
+func TestCRCWorkloadPolynomials(t *testing.T) {
+	ieee := newCRCWorkload(Config{crcPoly: "ieee"})
+	castagnoli := newCRCWorkload(Config{crcPoly: "castagnoli"})
+	castagnoli.buffer = ieee.buffer
+	ieee.iterate()
+	castagnoli.iterate()
+	if ieee.sum == castagnoli.sum {
+		t.Errorf("ieee and castagnoli checksums are both %#x, expected -crc-poly to select different tables", ieee.sum)
+	}
+}
+
+func TestCRCIteration(t *testing.T) {
+	for poly := range crcTables {
+		if bytes := newCRCIteration(0, Config{crcPoly: poly})(); bytes != crcBufferSize {
+			t.Errorf("%s iteration counted %d bytes, expected %d", poly, bytes, crcBufferSize)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// O_DSYNC makes every write wait for its data, like a database log
+const syncWriteFlag = syscall.O_DSYNC
+
+// O_DIRECT bypasses the page cache for -disk-direct-io-verify
+const directIOFlag = syscall.O_DIRECT
+
+func preallocateFile(file *os.File, size int64) error {
+	return syscall.Fallocate(int(file.Fd()), 0, 0, size)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// O_SYNC is the portable flag for synchronous writes
+const syncWriteFlag = os.O_SYNC
+
+// There is no portable O_DIRECT, so -disk-direct-io-verify is unavailable
+const directIOFlag = 0
+
+// Without fallocate, extending the file is the closest portable equivalent
+func preallocateFile(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPreallocateFile(t *testing.T) {
+	tempFile, err := os.CreateTemp(t.TempDir(), "prealloc_*.tmp")
+	if err != nil {
+		t.Fatalf("Cannot create temp file: %v", err)
+	}
+	defer tempFile.Close()
+
+	size := int64(4 * 1024 * 1024)
+	if err := preallocateFile(tempFile, size); err != nil {
+		t.Skipf("Preallocation not supported on this filesystem: %v", err)
+	}
+
+	info, err := tempFile.Stat()
+	if err != nil {
+		t.Fatalf("Cannot stat temp file: %v", err)
+	}
+	if info.Size() != size {
+		t.Errorf("File size after preallocateFile() = %d, expected %d", info.Size(), size)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// appendOffset returns where the next block of blockSize bytes goes in a file
+// that has grown to size. A block that would grow the file beyond fileSize
+// rolls the file over, starting it again at offset 0.
+func appendOffset(size, blockSize, fileSize int64) (int64, bool) {
+	if size+blockSize > fileSize {
+		return 0, true
+	}
+	return size, false
+}
+
+// appendDiskBenchmark appends blocks to a growing file, like a write-ahead log,
+// and truncates it once it would outgrow fileSize. Each rollover counts as an
+// iteration.
+func appendDiskBenchmark(file *os.File, fileSize int64, memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	blockSize := diskBlockSize(config)
+	if blockSize > int64(len(memoryChunks[0])) {
+		blockSize = int64(len(memoryChunks[0]))
+	}
+	if blockSize > fileSize {
+		blockSize = fileSize
+	}
+	if config.full {
+		fmt.Printf("Disk: Appending %s blocks up to %s\n",
+			formatBytes(blockSize, config.units), formatBytes(fileSize, config.units))
+	}
+
+	var size, writes, syncedWrites, syncs, rollovers, bytesWritten int64
+	writeLatency := newReservoir(config.latencySamples, time.Now().UnixNano())
+
+	start := time.Now()
+	lastReport := start
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+	resetsSeen := diskStats.resets.Load()
+
+	for {
+		// The file keeps growing across a reset, only the statistics restart
+		if diskStats.resetSince(&resetsSeen) {
+			syncs, rollovers, bytesWritten = 0, 0, 0
+			writeLatency.Reset()
+			start = time.Now()
+		}
+
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("Disk: Completed %d appends and %d rollovers\n", writes, rollovers)
+			}
+			return
+		default:
+			if diskStats.remainingBudget(config) == 0 {
+				fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+					formatBytes(config.diskTotalLimit, config.units))
+				return
+			}
+
+			offset, rollover := appendOffset(size, blockSize, fileSize)
+			if rollover {
+				// Flush the full log before it is discarded, unless the
+				// periodic fsyncs already covered it
+				if writes > syncedWrites {
+					if err := file.Sync(); err != nil {
+						failures.Record("Disk", "Error syncing file: %v", err)
+						return
+					}
+					syncs++
+					syncedWrites = writes
+				}
+				if err := file.Truncate(0); err != nil {
+					failures.Record("Disk", "Error truncating file: %v", err)
+					return
+				}
+				size = 0
+				rollovers++
+				diskStats.iterations.Add(1)
+			}
+
+			chunk := memoryChunks[writes%int64(len(memoryChunks))]
+			if int64(len(chunk)) < blockSize {
+				// A short final chunk is skipped for the first one, which
+				// blockSize was capped to
+				chunk = memoryChunks[0]
+			}
+			block := chunk[:blockSize]
+			if budget := diskStats.remainingBudget(config); budget >= 0 && budget < blockSize {
+				block = block[:budget]
+			}
+
+			writeStart := time.Now()
+			n, err := file.WriteAt(block, offset)
+			writeLatency.Add(time.Since(writeStart))
+			diskStats.bytesWritten.Add(int64(n))
+			if err != nil {
+				failures.Record("Disk", "Write error: %v", err)
+				return
+			}
+			size = offset + int64(n)
+			writes++
+			bytesWritten += int64(n)
+
+			if fsyncDue(int(writes-syncedWrites), config) {
+				if err := file.Sync(); err != nil {
+					failures.Record("Disk", "Error syncing file: %v", err)
+					return
+				}
+				syncs++
+				syncedWrites = writes
+			}
+
+			elapsed := time.Since(start)
+			writeMBps := mbPerSecond(bytesWritten, elapsed)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.DiskWriteMBps = writeMBps
+			})
+
+			if benchmarkReports(config) && time.Since(lastReport) >= reportInterval {
+				fmt.Printf("Disk: append %s, file at %s of %s, %d rollovers%s\n", formatMBps(writeMBps, config.units),
+					formatBytes(size, config.units), formatBytes(fileSize, config.units), rollovers, progressSuffix(metrics))
+				fmt.Printf("Disk: write latency p50 %v, p99 %v\n",
+					writeLatency.Percentile(50).Round(time.Microsecond), writeLatency.Percentile(99).Round(time.Microsecond))
+				if config.diskFsyncEvery > 0 {
+					fmt.Printf("Disk: %.1f fsyncs/s\n", perSecond(float64(syncs), elapsed))
+				}
+				lastReport = time.Now()
+				reportInterval = nextReportInterval(reportInterval, config)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestAppendOffset(t *testing.T) {
+	const blockSize, fileSize = 4096, 4096 * 3
+
+	size := int64(0)
+	var offsets []int64
+	rollovers := 0
+	for i := 0; i < 7; i++ {
+		offset, rollover := appendOffset(size, blockSize, fileSize)
+		if rollover {
+			rollovers++
+		}
+		offsets = append(offsets, offset)
+		size = offset + blockSize
+	}
+
+	expected := []int64{0, 4096, 8192, 0, 4096, 8192, 0}
+	for i := range expected {
+		if offsets[i] != expected[i] {
+			t.Fatalf("appendOffset() offsets = %v, expected %v", offsets, expected)
+		}
+	}
+	if rollovers != 2 {
+		t.Errorf("appendOffset() rolled over %d times, expected 2", rollovers)
+	}
+}
+
+func TestAppendOffsetUnevenFileSize(t *testing.T) {
+	// A block that does not fit completely rolls over instead of overshooting
+	if offset, rollover := appendOffset(8192, 4096, 10000); offset != 0 || !rollover {
+		t.Errorf("appendOffset(8192, 4096, 10000) = %d, %v, expected 0, true", offset, rollover)
+	}
+	if offset, rollover := appendOffset(4096, 4096, 10000); offset != 4096 || rollover {
+		t.Errorf("appendOffset(4096, 4096, 10000) = %d, %v, expected 4096, false", offset, rollover)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long -disk-path auto benchmarks each candidate mount
+const diskProbeDuration = time.Second
+
+// How long a candidate may take before it is skipped, so a hung network
+// mount cannot stall the run
+const diskProbeTimeout = 5 * time.Second
+
+// Bytes written per probe iteration
+const diskProbeFileSize = 8 * 1024 * 1024
+
+// Filesystems that are not backed by a disk, or not meant for data
+var pseudoFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "tmpfs": true, "ramfs": true,
+	"cgroup": true, "cgroup2": true, "securityfs": true, "debugfs": true, "tracefs": true,
+	"pstore": true, "bpf": true, "mqueue": true, "hugetlbfs": true, "configfs": true,
+	"fusectl": true, "autofs": true, "binfmt_misc": true, "efivarfs": true, "nsfs": true,
+	"rpc_pipefs": true, "squashfs": true,
+}
+
+// parseMounts returns the mount points in /proc/self/mounts format that
+// could hold the benchmark files: no pseudo filesystems, nothing read-only
+func parseMounts(mounts string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(mounts, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || pseudoFilesystems[fields[2]] {
+			continue
+		}
+		readOnly := false
+		for _, option := range strings.Split(fields[3], ",") {
+			readOnly = readOnly || option == "ro"
+		}
+		path := unescapeMountPath(fields[1])
+		if readOnly || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// unescapeMountPath decodes the octal escapes the kernel uses for spaces,
+// tabs, newlines and backslashes in mount points
+func unescapeMountPath(path string) string {
+	var result strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				result.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		result.WriteByte(path[i])
+	}
+	return result.String()
+}
+
+// diskCandidates lists the mounts -disk-path auto probes. Only Linux can
+// enumerate them; elsewhere the working and temp directories compete.
+func diskCandidates() ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return []string{"./", os.TempDir()}, nil
+	}
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	return parseMounts(string(data)), nil
+}
+
+type diskProbe struct {
+	path      string
+	writeMBps float64
+	err       error
+}
+
+// probeDiskPath runs a short filesystemBenchmark in path and returns its
+// write throughput. It gives up after timeout, leaving a hung probe behind.
+func probeDiskPath(path string, config Config, timeout time.Duration) (float64, error) {
+	config.diskPath = path
+	config.diskTarget = ""
+	config.diskFileSize = diskProbeFileSize
+	config.diskRWMix = -1
+	config.diskMode = "rewrite"
+	config.diskRotateFiles = 1
+	config.diskWorkers = 1
+	config.diskPreallocate = false
+	config.diskPattern = "random"
+	config.diskComparePat = false
+	config.diskTotalLimit = 0
+	config.chunkSizeMB = 1
+	config.full = false
+	config.burnIn = false
+	config.reportInterval = 3600
+	config.reportBackoff = 2
+	config.reportBackoffMax = time.Hour
+
+	chunks := make([][]byte, diskProbeFileSize/(1024*1024))
+	for i := range chunks {
+		chunks[i] = make([]byte, 1024*1024)
+	}
+	metrics := &Metrics{}
+	diskStats := &DiskStats{}
+
+	done := make(chan error, 1)
+	go func() {
+		// Mounts that cannot take a file fail here without a benchmark
+		file, err := os.CreateTemp(path, "perf_test_*.tmp")
+		if err != nil {
+			done <- err
+			return
+		}
+		file.Close()
+		os.Remove(file.Name())
+
+		stopChan := make(chan struct{})
+		time.AfterFunc(diskProbeDuration, func() { close(stopChan) })
+		filesystemBenchmark(chunks, stopChan, config, diskStats, metrics, &FailureLog{})
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return 0, err
+		}
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("timed out after %v", timeout)
+	}
+
+	if diskStats.iterations.Load() == 0 {
+		return 0, errors.New("no probe iteration completed")
+	}
+	return metrics.Snapshot().DiskWriteMBps, nil
+}
+
+// rankDiskProbes sorts successful probes by write throughput, fastest first,
+// followed by the failed ones
+func rankDiskProbes(probes []diskProbe) {
+	sort.SliceStable(probes, func(i, j int) bool {
+		if (probes[i].err == nil) != (probes[j].err == nil) {
+			return probes[i].err == nil
+		}
+		return probes[i].writeMBps > probes[j].writeMBps
+	})
+}
+
+// selectDiskPath probes every candidate mount, prints the ranking and
+// returns the fastest writable one
+func selectDiskPath(config Config) (string, error) {
+	candidates, err := diskCandidates()
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Disk: Probing %d mounts for -disk-path auto\n", len(candidates))
+
+	probes := make([]diskProbe, 0, len(candidates))
+	for _, path := range candidates {
+		writeMBps, err := probeDiskPath(path, config, diskProbeTimeout)
+		probes = append(probes, diskProbe{path: path, writeMBps: writeMBps, err: err})
+	}
+	rankDiskProbes(probes)
+
+	for i, probe := range probes {
+		if probe.err != nil {
+			if config.full {
+				fmt.Printf("Disk:  -  %s skipped: %v\n", probe.path, probe.err)
+			}
+			continue
+		}
+		fmt.Printf("Disk: %2d. %s write %s\n", i+1, probe.path, formatMBps(probe.writeMBps, config.units))
+	}
+	if len(probes) == 0 || probes[0].err != nil {
+		return "", errors.New("no writable mount found")
+	}
+	fmt.Printf("Disk: Using %s\n", probes[0].path)
+	return probes[0].path, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMounts(t *testing.T) {
+	mounts := `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/nvme0n1p2 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev,size=3274600k,mode=755 0 0
+/dev/nvme0n1p1 /boot/efi vfat rw,relatime,fmask=0077 0 0
+/dev/sda1 /mnt/my\040data xfs rw,noatime 0 0
+/dev/sdb1 /mnt/archive ext4 ro,relatime 0 0
+nas:/export /mnt/nas nfs4 rw,relatime,vers=4.2 0 0
+/dev/nvme0n1p2 / ext4 rw,relatime 0 0
+`
+	expected := []string{"/", "/boot/efi", "/mnt/my data", "/mnt/nas"}
+	if result := parseMounts(mounts); !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseMounts() = %q, expected %q", result, expected)
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	tests := map[string]string{
+		`/mnt/plain`:       "/mnt/plain",
+		`/mnt/a\040b`:      "/mnt/a b",
+		`/mnt/tab\011x`:    "/mnt/tab\tx",
+		`/mnt/back\134`:    `/mnt/back\`,
+		`/mnt/not\escaped`: `/mnt/not\escaped`,
+		`/mnt/short\04`:    `/mnt/short\04`,
+	}
+	for path, expected := range tests {
+		if result := unescapeMountPath(path); result != expected {
+			t.Errorf("unescapeMountPath(%q) = %q, expected %q", path, result, expected)
+		}
+	}
+}
+
+func TestRankDiskProbes(t *testing.T) {
+	probes := []diskProbe{
+		{path: "/slow", writeMBps: 100},
+		{path: "/nas", err: errors.New("timed out")},
+		{path: "/fast", writeMBps: 2000},
+		{path: "/mid", writeMBps: 500},
+	}
+	rankDiskProbes(probes)
+
+	var order []string
+	for _, probe := range probes {
+		order = append(order, probe.path)
+	}
+	if expected := []string{"/fast", "/mid", "/slow", "/nas"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("rankDiskProbes() order = %q, expected %q", order, expected)
+	}
+}
+
+func TestProbeDiskPath(t *testing.T) {
+	dir := t.TempDir()
+	writeMBps, err := probeDiskPath(dir, Config{units: "binary"}, time.Minute)
+	if err != nil || writeMBps <= 0 {
+		t.Fatalf("probeDiskPath() = %f, %v; expected a write throughput", writeMBps, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("probeDiskPath() left %d files behind", len(entries))
+	}
+
+	if _, err := probeDiskPath(dir+"/missing", Config{}, time.Minute); err == nil {
+		t.Errorf("probeDiskPath() should fail for a missing directory")
+	}
+}
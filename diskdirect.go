@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"time"
+	"unsafe"
+)
+
+// Direct I/O needs buffers, offsets and lengths aligned to the logical block
+// size of the device. 4 KiB covers both 512-byte and 4K-native drives.
+const directIOAlignment = 4096
+
+// Mismatches per pass that are reported with their offset, the rest are
+// only counted, so a dying drive cannot flood the output
+const directVerifyMaxReported = 10
+
+// Platforms without O_DIRECT define directIOFlag as 0
+const directIOSupported = directIOFlag != 0
+
+var directVerifyTable = crc32.MakeTable(crc32.Castagnoli)
+
+// alignedBuffer returns size bytes starting at a multiple of directIOAlignment
+func alignedBuffer(size int64) []byte {
+	buffer := make([]byte, size+directIOAlignment)
+	offset := int64(0)
+	if misalignment := int64(uintptr(unsafe.Pointer(&buffer[0])) % directIOAlignment); misalignment != 0 {
+		offset = directIOAlignment - misalignment
+	}
+	return buffer[offset : offset+size : offset+size]
+}
+
+// reopenDirectIO replaces every file by one opened with O_DIRECT, so reads
+// and writes bypass the page cache. The originals are closed.
+func reopenDirectIO(files []*os.File) ([]*os.File, error) {
+	return reopenFiles(files, directIOFlag)
+}
+
+// directVerifyBlocks is the number of whole blocks that fit in fileSize,
+// at least one, since direct I/O cannot write a partial block
+func directVerifyBlocks(fileSize, blockSize int64) int64 {
+	if blocks := fileSize / blockSize; blocks > 0 {
+		return blocks
+	}
+	return 1
+}
+
+// fillVerifyBlock fills block with the test pattern and stamps it with its
+// offset and pass, so a block written to the wrong place or left over from
+// an earlier pass fails the checksum as well. It returns the checksum.
+func fillVerifyBlock(block []byte, offset, pass int64, pattern string) (uint32, error) {
+	if err := fillDiskChunk(block, pattern); err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint64(block[0:8], uint64(offset))
+	binary.LittleEndian.PutUint64(block[8:16], uint64(pass))
+	return crc32.Checksum(block, directVerifyTable), nil
+}
+
+// writeVerifyPass writes every block of the file with a fresh stamp,
+// recording its checksum, then syncs so the device cache is flushed as well.
+// It returns the write throughput and reports false once the benchmark
+// should stop.
+func writeVerifyPass(file *os.File, blockSize int64, pass int64, buffer []byte, checksums []uint32, stopChan <-chan struct{}, config Config, diskStats *DiskStats, failures *FailureLog) (float64, bool) {
+	start := time.Now()
+	written := int64(0)
+	for i := range checksums {
+		select {
+		case <-stopChan:
+			return 0, false
+		default:
+		}
+
+		offset := int64(i) * blockSize
+		checksum, err := fillVerifyBlock(buffer, offset, pass, config.diskPattern)
+		if err != nil {
+			failures.Record("Disk", "Error filling block: %v", err)
+			return 0, false
+		}
+		checksums[i] = checksum
+
+		n, err := file.WriteAt(buffer, offset)
+		diskStats.bytesWritten.Add(int64(n))
+		written += int64(n)
+		if err != nil {
+			failures.Record("Disk", "Write error at offset %d: %v", offset, err)
+			return 0, false
+		}
+	}
+	if err := file.Sync(); err != nil {
+		failures.Record("Disk", "Error syncing file: %v", err)
+		return 0, false
+	}
+	return mbPerSecond(written, time.Since(start)), true
+}
+
+// readVerifyPass reads every block back and compares its checksum with the
+// one recorded when it was written. It returns the read throughput and the
+// offsets of the blocks that did not match, and reports false once the
+// benchmark should stop.
+func readVerifyPass(file *os.File, blockSize int64, buffer []byte, checksums []uint32, stopChan <-chan struct{}, diskStats *DiskStats, failures *FailureLog) (float64, []int64, bool) {
+	start := time.Now()
+	read := int64(0)
+	var mismatches []int64
+	for i, expected := range checksums {
+		select {
+		case <-stopChan:
+			return 0, mismatches, false
+		default:
+		}
+
+		offset := int64(i) * blockSize
+		n, err := file.ReadAt(buffer, offset)
+		diskStats.bytesRead.Add(int64(n))
+		read += int64(n)
+		if err != nil {
+			failures.Record("Disk", "Read error at offset %d: %v", offset, err)
+			return 0, mismatches, false
+		}
+		if crc32.Checksum(buffer, directVerifyTable) != expected {
+			mismatches = append(mismatches, offset)
+		}
+	}
+	return mbPerSecond(read, time.Since(start)), mismatches, true
+}
+
+// directVerifyDiskBenchmark rewrites the file block by block with O_DIRECT
+// and reads every block back, checking its checksum. Bypassing the page cache
+// on both sides makes the reads come from the device, so silent corruption
+// that a cached copy would hide is caught. Each pass counts as an iteration.
+func directVerifyDiskBenchmark(file *os.File, fileSize int64, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	blockSize := diskBlockSize(config)
+	blocks := directVerifyBlocks(fileSize, blockSize)
+	if config.full {
+		fmt.Printf("Disk: Writing and verifying %d blocks of %s with direct I/O\n", blocks, formatBytes(blockSize, config.units))
+	}
+
+	buffer := alignedBuffer(blockSize)
+	checksums := make([]uint32, blocks)
+	totalWriteMBps, totalReadMBps := 0.0, 0.0
+	measured := 0
+	lastReport := time.Now()
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+	resetsSeen := diskStats.resets.Load()
+
+	for pass := int64(1); ; pass++ {
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("Disk: Completed %d verify passes\n", pass-1)
+			}
+			return
+		default:
+		}
+		if diskStats.resetSince(&resetsSeen) {
+			measured, totalWriteMBps, totalReadMBps = 0, 0, 0
+		}
+		if budget := diskStats.remainingBudget(config); budget >= 0 && budget < blocks*blockSize {
+			fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+				formatBytes(config.diskTotalLimit, config.units))
+			return
+		}
+
+		writeMBps, ok := writeVerifyPass(file, blockSize, pass, buffer, checksums, stopChan, config, diskStats, failures)
+		if !ok {
+			return
+		}
+		readMBps, mismatches, ok := readVerifyPass(file, blockSize, buffer, checksums, stopChan, diskStats, failures)
+		// Blocks checked before a stop still count
+		for i, offset := range mismatches {
+			if i == directVerifyMaxReported {
+				failures.Record("Disk", "%d more checksum mismatches in pass %d", len(mismatches)-i, pass)
+				break
+			}
+			failures.Record("Disk", "Checksum mismatch in the block at offset %d in pass %d", offset, pass)
+		}
+		diskStats.verifyMismatches.Add(int64(len(mismatches)))
+		if !ok {
+			return
+		}
+		diskStats.verifiedBlocks.Add(blocks)
+
+		// The first -warmup-iterations are verified but not averaged
+		if pass > int64(config.warmupIters) {
+			measured++
+			totalWriteMBps += writeMBps
+			totalReadMBps += readMBps
+			if sampleRates(config) {
+				diskStats.addWriteRate(writeMBps)
+			}
+			avgWriteMBps := totalWriteMBps / float64(measured)
+			avgReadMBps := totalReadMBps / float64(measured)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.DiskWriteMBps = avgWriteMBps
+				snapshot.DiskReadMBps = avgReadMBps
+			})
+			diskStats.iterations.Add(1)
+		}
+
+		if benchmarkReports(config) && time.Since(lastReport) >= reportInterval {
+			snapshot := metrics.Snapshot()
+			fmt.Printf("Disk: direct I/O write %s, read %s, %d blocks verified, %d mismatches%s\n",
+				formatMBps(snapshot.DiskWriteMBps, config.units), formatMBps(snapshot.DiskReadMBps, config.units),
+				diskStats.verifiedBlocks.Load(), diskStats.verifyMismatches.Load(), progressSuffix(metrics))
+			lastReport = time.Now()
+			reportInterval = nextReportInterval(reportInterval, config)
+		}
+	}
+}
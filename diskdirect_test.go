@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, size := range []int64{4096, 65536, 1024 * 1024} {
+		buffer := alignedBuffer(size)
+		if int64(len(buffer)) != size || int64(cap(buffer)) != size {
+			t.Errorf("alignedBuffer(%d) has len %d, cap %d, expected %d", size, len(buffer), cap(buffer), size)
+		}
+		if address := uintptr(unsafe.Pointer(&buffer[0])); address%directIOAlignment != 0 {
+			t.Errorf("alignedBuffer(%d) starts at %#x, expected a multiple of %d", size, address, directIOAlignment)
+		}
+	}
+}
+
+func TestDirectVerifyBlocks(t *testing.T) {
+	tests := []struct {
+		fileSize  int64
+		blockSize int64
+		expected  int64
+	}{
+		{1024 * 1024, 4096, 256},
+		{1024*1024 + 100, 4096, 256},
+		// A file smaller than a block still gets one
+		{1000, 4096, 1},
+	}
+
+	for _, test := range tests {
+		if blocks := directVerifyBlocks(test.fileSize, test.blockSize); blocks != test.expected {
+			t.Errorf("directVerifyBlocks(%d, %d) = %d, expected %d", test.fileSize, test.blockSize, blocks, test.expected)
+		}
+	}
+}
+
+func TestFillVerifyBlockStamps(t *testing.T) {
+	block := make([]byte, 4096)
+	first, err := fillVerifyBlock(block, 0, 1, "zeros")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Identical data in another place or pass must not pass the check
+	if moved, _ := fillVerifyBlock(block, 4096, 1, "zeros"); moved == first {
+		t.Errorf("fillVerifyBlock() checksum does not depend on the offset")
+	}
+	if stale, _ := fillVerifyBlock(block, 0, 2, "zeros"); stale == first {
+		t.Errorf("fillVerifyBlock() checksum does not depend on the pass")
+	}
+}
+
+func TestVerifyPassDetectsCorruption(t *testing.T) {
+	// A regular file stands in for O_DIRECT, which tmpfs does not support
+	files, err := createDiskFiles(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := files[0]
+	defer file.Close()
+
+	const blockSize = 4096
+	config := Config{diskPattern: "random"}
+	diskStats := &DiskStats{}
+	stopChan := make(chan struct{})
+	buffer := alignedBuffer(blockSize)
+	checksums := make([]uint32, 4)
+
+	if _, ok := writeVerifyPass(file, blockSize, 1, buffer, checksums, stopChan, config, diskStats, nil); !ok {
+		t.Fatal("writeVerifyPass() stopped early")
+	}
+	if _, mismatches, ok := readVerifyPass(file, blockSize, buffer, checksums, stopChan, diskStats, nil); !ok || len(mismatches) != 0 {
+		t.Fatalf("readVerifyPass() of intact blocks = %v, %v, expected no mismatches", mismatches, ok)
+	}
+
+	if _, err := file.WriteAt([]byte{0xff, 0x00}, 2*blockSize+100); err != nil {
+		t.Fatal(err)
+	}
+	_, mismatches, ok := readVerifyPass(file, blockSize, buffer, checksums, stopChan, diskStats, nil)
+	if !ok || !reflect.DeepEqual(mismatches, []int64{2 * blockSize}) {
+		t.Errorf("readVerifyPass() after corrupting the third block = %v, %v, expected [%d]", mismatches, ok, 2*blockSize)
+	}
+	if read := diskStats.bytesRead.Load(); read != 2*4*blockSize {
+		t.Errorf("bytesRead = %d, expected %d", read, 2*4*blockSize)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// How many synchronous writes -disk-latency-only times
+const diskLatencyWrites = 1000
+
+// Write size of -disk-latency-only unless -disk-block-size is given
+const diskLatencyBlockSize = 4 * 1024
+
+// measureDiskLatency appends writes blocks of blockSize to a temp file in
+// dir, fsyncing after each, and returns the sorted write-and-sync latencies.
+// The file is removed again.
+func measureDiskLatency(dir string, writes int, blockSize int64) ([]time.Duration, error) {
+	file, err := os.CreateTemp(dir, "perf_test_*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	block := make([]byte, blockSize)
+	fillChunk(block)
+	latencies := make([]time.Duration, 0, writes)
+	for i := 0; i < writes; i++ {
+		start := time.Now()
+		if _, err := file.Write(block); err != nil {
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies, nil
+}
+
+// runDiskLatency is the storage equivalent of a ping: it reports the
+// latency percentiles of small synchronous writes and skips the throughput
+// benchmark entirely.
+func runDiskLatency(config Config) bool {
+	blockSize := int64(diskLatencyBlockSize)
+	if config.diskBlockSize > 0 {
+		blockSize = config.diskBlockSize
+	}
+	dir := diskPaths(config)[0]
+	fmt.Printf("Disk latency: %d writes of %s with fsync in %s\n", diskLatencyWrites, formatBytes(blockSize, config.units), dir)
+
+	latencies, err := measureDiskLatency(dir, diskLatencyWrites, blockSize)
+	if err != nil {
+		fmt.Printf("Disk latency: %v\n", err)
+		return false
+	}
+	fmt.Printf("Disk latency: p50 %v, p99 %v, max %v\n",
+		percentile(latencies, 50).Round(time.Microsecond), percentile(latencies, 99).Round(time.Microsecond),
+		latencies[len(latencies)-1].Round(time.Microsecond))
+	return true
+}
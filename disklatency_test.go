@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMeasureDiskLatency(t *testing.T) {
+	dir := t.TempDir()
+	latencies, err := measureDiskLatency(dir, 20, 4096)
+	if err != nil {
+		t.Fatalf("measureDiskLatency() error: %v", err)
+	}
+
+	if len(latencies) != 20 {
+		t.Fatalf("measureDiskLatency() returned %d latencies, expected 20", len(latencies))
+	}
+	for i := 1; i < len(latencies); i++ {
+		if latencies[i] < latencies[i-1] {
+			t.Fatalf("measureDiskLatency() latencies not sorted: %v", latencies)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("measureDiskLatency() left %d files behind", len(entries))
+	}
+
+	if _, err := measureDiskLatency(filepath.Join(dir, "missing"), 20, 4096); err == nil {
+		t.Errorf("measureDiskLatency() should fail for a missing directory")
+	}
+}
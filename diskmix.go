@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// rwMixer decides for each operation whether it is a read, so that over
+// many operations readPercent percent of them are reads.
+type rwMixer struct {
+	rng         *rand.Rand
+	readPercent int
+}
+
+func newRWMixer(readPercent int, seed int64) *rwMixer {
+	return &rwMixer{rng: rand.New(rand.NewSource(seed)), readPercent: readPercent}
+}
+
+func (m *rwMixer) nextIsRead() bool {
+	return m.rng.Intn(100) < m.readPercent
+}
+
+func diskBlockSize(config Config) int64 {
+	if config.diskBlockSize > 0 {
+		return config.diskBlockSize
+	}
+	return chunkBytes(config)
+}
+
+// diskReadBufferSize is the size of each read when reading the file back
+// sequentially, independent of the chunks the data was written from
+func diskReadBufferSize(config Config) int64 {
+	if config.diskReadBuffer > 0 {
+		return config.diskReadBuffer
+	}
+	return chunkBytes(config)
+}
+
+// fsyncDue reports whether the writer should fsync after blocksSinceSync
+// block writes. With no -disk-fsync-interval it never is; the caller then
+// syncs once per iteration instead.
+func fsyncDue(blocksSinceSync int, config Config) bool {
+	return config.diskFsyncEvery > 0 && blocksSinceSync >= config.diskFsyncEvery
+}
+
+// layoutFile writes the whole file once so mixed reads hit real data
+func layoutFile(file *os.File, fileSize int64, memoryChunks [][]byte, diskStats *DiskStats) error {
+	written := int64(0)
+	for i := 0; written < fileSize; i++ {
+		chunk := memoryChunks[i%len(memoryChunks)]
+		if remaining := fileSize - written; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		n, err := file.WriteAt(chunk, written)
+		diskStats.bytesWritten.Add(int64(n))
+		if err != nil {
+			return err
+		}
+		written += int64(n)
+	}
+	return file.Sync()
+}
+
+// mixedDiskBenchmark issues a random interleaving of block-sized reads and
+// writes at random offsets, config.diskRWMix percent of them being reads.
+func mixedDiskBenchmark(file *os.File, fileSize int64, memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	blockSize := diskBlockSize(config)
+	if blockSize > int64(len(memoryChunks[0])) {
+		blockSize = int64(len(memoryChunks[0]))
+	}
+	if blockSize > fileSize {
+		blockSize = fileSize
+	}
+	blocks := fileSize / blockSize
+
+	if err := layoutFile(file, fileSize, memoryChunks, diskStats); err != nil {
+		failures.Record("Disk", "Error preparing file for mixed I/O: %v", err)
+		return
+	}
+	if config.full {
+		fmt.Printf("Disk: Mixed I/O with %d%% reads, %s blocks over %s\n",
+			config.diskRWMix, formatBytes(blockSize, config.units), formatBytes(fileSize, config.units))
+	}
+
+	mixer := newRWMixer(config.diskRWMix, time.Now().UnixNano())
+	buffer := make([]byte, blockSize)
+	var reads, writes, bytesRead, bytesWritten int64
+	var syncs, syncedWrites int64
+	writeLatency := newReservoir(config.latencySamples, time.Now().UnixNano())
+
+	start := time.Now()
+	lastReport := start
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+	resetsSeen := diskStats.resets.Load()
+
+	for {
+		if diskStats.resetSince(&resetsSeen) {
+			reads, writes, bytesRead, bytesWritten, syncs, syncedWrites = 0, 0, 0, 0, 0, 0
+			writeLatency.Reset()
+			start = time.Now()
+		}
+
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("Disk: Completed %d reads and %d writes\n", reads, writes)
+			}
+			return
+		default:
+			offset := mixer.rng.Int63n(blocks) * blockSize
+
+			if mixer.nextIsRead() {
+				n, err := file.ReadAt(buffer, offset)
+				if err != nil && !errors.Is(err, io.EOF) {
+					failures.Record("Disk", "Read error: %v", err)
+					return
+				}
+				reads++
+				bytesRead += int64(n)
+				diskStats.bytesRead.Add(int64(n))
+			} else {
+				if diskStats.remainingBudget(config) == 0 {
+					fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+						formatBytes(config.diskTotalLimit, config.units))
+					return
+				}
+
+				chunk := memoryChunks[writes%int64(len(memoryChunks))]
+				if int64(len(chunk)) < blockSize {
+					// blockSize is capped at the first chunk, so it always fits
+					chunk = memoryChunks[0]
+				}
+				block := chunk[:blockSize]
+				if budget := diskStats.remainingBudget(config); budget >= 0 && budget < blockSize {
+					block = block[:budget]
+				}
+				writeStart := time.Now()
+				n, err := file.WriteAt(block, offset)
+				writeLatency.Add(time.Since(writeStart))
+				diskStats.bytesWritten.Add(int64(n))
+				if err != nil {
+					failures.Record("Disk", "Write error: %v", err)
+					return
+				}
+				writes++
+				bytesWritten += int64(n)
+
+				// Flush once per file's worth of writes, like the sequential
+				// test, or every -disk-fsync-interval writes if set
+				fileDone := writes%blocks == 0
+				if (fileDone && config.diskFsyncEvery == 0) || fsyncDue(int(writes-syncedWrites), config) {
+					if err := file.Sync(); err != nil {
+						failures.Record("Disk", "Error syncing file: %v", err)
+						return
+					}
+					syncs++
+					syncedWrites = writes
+				}
+				if fileDone {
+					diskStats.iterations.Add(1)
+				}
+			}
+
+			elapsed := time.Since(start)
+			readMBps := mbPerSecond(bytesRead, elapsed)
+			writeMBps := mbPerSecond(bytesWritten, elapsed)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.DiskReadMBps = readMBps
+				snapshot.DiskWriteMBps = writeMBps
+			})
+
+			if benchmarkReports(config) && time.Since(lastReport) >= reportInterval {
+				fmt.Printf("Disk: mixed %d%% reads, read %s, write %s, combined %s%s\n", config.diskRWMix,
+					formatMBps(readMBps, config.units), formatMBps(writeMBps, config.units), formatMBps(readMBps+writeMBps, config.units),
+					progressSuffix(metrics))
+				fmt.Printf("Disk: write latency p50 %v, p99 %v\n",
+					writeLatency.Percentile(50).Round(time.Microsecond), writeLatency.Percentile(99).Round(time.Microsecond))
+				if config.diskFsyncEvery > 0 {
+					fmt.Printf("Disk: %.1f fsyncs/s\n", perSecond(float64(syncs), elapsed))
+				}
+				lastReport = time.Now()
+				reportInterval = nextReportInterval(reportInterval, config)
+			}
+		}
+	}
+}
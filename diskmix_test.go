@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestRWMixerRatio(t *testing.T) {
+	const operations = 100000
+
+	for _, readPercent := range []int{0, 30, 50, 70, 100} {
+		mixer := newRWMixer(readPercent, 42)
+		reads := 0
+		for i := 0; i < operations; i++ {
+			if mixer.nextIsRead() {
+				reads++
+			}
+		}
+
+		achieved := float64(reads) * 100 / operations
+		if diff := achieved - float64(readPercent); diff > 1 || diff < -1 {
+			t.Errorf("rwMixer with %d%% reads achieved %.2f%%, expected within 1%%", readPercent, achieved)
+		}
+	}
+}
+
+func TestDiskBlockSize(t *testing.T) {
+	if size := diskBlockSize(Config{chunkSizeMB: 100}); size != 100*1024*1024 {
+		t.Errorf("diskBlockSize() without block size = %d, expected chunk size %d", size, 100*1024*1024)
+	}
+	if size := diskBlockSize(Config{chunkSizeMB: 100, diskBlockSize: 4096}); size != 4096 {
+		t.Errorf("diskBlockSize() with 4K block size = %d, expected 4096", size)
+	}
+}
+
+// readSizeRecorder records the size of every read it is asked for
+type readSizeRecorder struct {
+	remaining int
+	sizes     []int
+}
+
+func (r *readSizeRecorder) Read(p []byte) (int, error) {
+	r.sizes = append(r.sizes, len(p))
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if n > r.remaining {
+		n = r.remaining
+	}
+	r.remaining -= n
+	return n, nil
+}
+
+func TestDiskReadBufferSize(t *testing.T) {
+	if size := diskReadBufferSize(Config{chunkSizeMB: 100}); size != 100*1024*1024 {
+		t.Errorf("diskReadBufferSize() without read buffer = %d, expected chunk size %d", size, 100*1024*1024)
+	}
+	// The read size is independent of both chunk and write block size
+	config := Config{chunkSizeMB: 100, diskBlockSize: 1024 * 1024, diskReadBuffer: 4096}
+	if size := diskReadBufferSize(config); size != 4096 {
+		t.Errorf("diskReadBufferSize() with 4K read buffer = %d, expected 4096", size)
+	}
+
+	reader := &readSizeRecorder{remaining: 10000}
+	buffer := make([]byte, diskReadBufferSize(config))
+	read, _, err := readToEOF(reader, buffer, make(chan struct{}))
+	if err != nil || read != 10000 {
+		t.Fatalf("readToEOF() = %d, %v; expected 10000 bytes", read, err)
+	}
+	for _, size := range reader.sizes {
+		if size != 4096 {
+			t.Errorf("readToEOF() issued reads of %v bytes, expected 4096 each", reader.sizes)
+			break
+		}
+	}
+}
+
+func TestFsyncDue(t *testing.T) {
+	if fsyncDue(1000, Config{}) {
+		t.Errorf("fsyncDue() without interval should leave syncing to the iteration end")
+	}
+	config := Config{diskFsyncEvery: 4}
+	for blocks, expected := range map[int]bool{1: false, 3: false, 4: true} {
+		if due := fsyncDue(blocks, config); due != expected {
+			t.Errorf("fsyncDue(%d) every 4 blocks = %v, expected %v", blocks, due, expected)
+		}
+	}
+}
+
+func TestLayoutFile(t *testing.T) {
+	tempFile, err := os.CreateTemp(t.TempDir(), "layout_*.tmp")
+	if err != nil {
+		t.Fatalf("Cannot create temp file: %v", err)
+	}
+	defer tempFile.Close()
+
+	chunks := [][]byte{bytes.Repeat([]byte{1}, 1000), bytes.Repeat([]byte{2}, 1000)}
+	diskStats := &DiskStats{}
+	if err := layoutFile(tempFile, 2500, chunks, diskStats); err != nil {
+		t.Fatalf("layoutFile() returned error: %v", err)
+	}
+	if written := diskStats.bytesWritten.Load(); written != 2500 {
+		t.Errorf("layoutFile() counted %d bytes written, expected 2500", written)
+	}
+
+	data, err := os.ReadFile(tempFile.Name())
+	if err != nil {
+		t.Fatalf("Cannot read temp file: %v", err)
+	}
+	if len(data) != 2500 {
+		t.Fatalf("layoutFile() wrote %d bytes, expected 2500", len(data))
+	}
+	if data[999] != 1 || data[1000] != 2 || data[2499] != 1 {
+		t.Errorf("layoutFile() did not cycle through the chunks in order")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// Data the disk benchmark can write. Thin-provisioned or deduplicating
+// storage may skip or unmap zeros instead of storing them.
+var diskTestPatterns = []string{"random", "zeros"}
+
+func validDiskPattern(name string) bool {
+	for _, pattern := range diskTestPatterns {
+		if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// diskIterationPattern returns the data of the pass-th disk iteration,
+// counting from 1: -disk-test-pattern, or every pattern in turn with
+// -disk-compare-patterns
+func diskIterationPattern(pass int, config Config) string {
+	if config.diskComparePat {
+		return diskTestPatterns[(pass-1)%len(diskTestPatterns)]
+	}
+	return config.diskPattern
+}
+
+// fillDiskChunk overwrites chunk with fresh data of pattern before it is
+// written, so neither caches nor deduplication see repeated random data
+func fillDiskChunk(chunk []byte, pattern string) error {
+	if pattern == "zeros" {
+		for i := range chunk {
+			chunk[i] = 0
+		}
+		return nil
+	}
+	_, err := rand.Read(chunk)
+	return err
+}
+
+// patternThroughput averages the write throughput of each pattern for
+// -disk-compare-patterns
+type patternThroughput struct {
+	totalMBps  map[string]float64
+	iterations map[string]int
+}
+
+func newPatternThroughput() *patternThroughput {
+	return &patternThroughput{totalMBps: make(map[string]float64), iterations: make(map[string]int)}
+}
+
+func (p *patternThroughput) Add(pattern string, writeMBps float64) {
+	p.totalMBps[pattern] += writeMBps
+	p.iterations[pattern]++
+}
+
+// Averages returns the average write MiB/s of every pattern written so far
+func (p *patternThroughput) Averages() map[string]float64 {
+	averages := make(map[string]float64, len(p.iterations))
+	for pattern, iterations := range p.iterations {
+		averages[pattern] = p.totalMBps[pattern] / float64(iterations)
+	}
+	return averages
+}
+
+// formatPatternRates lists the average write throughput of each pattern in
+// the order of diskTestPatterns. Random data cannot be optimized away, so
+// the other patterns are also shown relative to it.
+func formatPatternRates(averages map[string]float64, units string) string {
+	var parts []string
+	for _, pattern := range diskTestPatterns {
+		average, ok := averages[pattern]
+		if !ok {
+			continue
+		}
+		part := fmt.Sprintf("%s %s", pattern, formatMBps(average, units))
+		if random := averages["random"]; pattern != "random" && random > 0 {
+			part += fmt.Sprintf(" (%.2fx random)", average/random)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
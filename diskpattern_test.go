@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiskIterationPattern(t *testing.T) {
+	config := Config{diskPattern: "zeros"}
+	for pass := 1; pass <= 3; pass++ {
+		if pattern := diskIterationPattern(pass, config); pattern != "zeros" {
+			t.Errorf("diskIterationPattern(%d) = %s, expected zeros", pass, pattern)
+		}
+	}
+
+	// Comparing alternates back-to-back passes, starting with random data
+	config = Config{diskPattern: "random", diskComparePat: true}
+	expected := []string{"random", "zeros", "random", "zeros"}
+	for i, expectedPattern := range expected {
+		if pattern := diskIterationPattern(i+1, config); pattern != expectedPattern {
+			t.Errorf("diskIterationPattern(%d) with -disk-compare-patterns = %s, expected %s", i+1, pattern, expectedPattern)
+		}
+	}
+}
+
+func TestFillDiskChunk(t *testing.T) {
+	chunk := make([]byte, 4096)
+	fillChunk(chunk)
+	if err := fillDiskChunk(chunk, "zeros"); err != nil || !bytes.Equal(chunk, make([]byte, len(chunk))) {
+		t.Errorf("fillDiskChunk(zeros) = %v, left non-zero bytes", err)
+	}
+
+	if err := fillDiskChunk(chunk, "random"); err != nil || bytes.Equal(chunk, make([]byte, len(chunk))) {
+		t.Errorf("fillDiskChunk(random) = %v, left the chunk zeroed", err)
+	}
+}
+
+func TestPatternThroughput(t *testing.T) {
+	rates := newPatternThroughput()
+	rates.Add("random", 100)
+	rates.Add("random", 200)
+	rates.Add("zeros", 600)
+
+	averages := rates.Averages()
+	if averages["random"] != 150 || averages["zeros"] != 600 {
+		t.Errorf("Averages() = %v, expected random 150 and zeros 600", averages)
+	}
+
+	expected := "random 150.00 MiB/s, zeros 600.00 MiB/s (4.00x random)"
+	if result := formatPatternRates(averages, "binary"); result != expected {
+		t.Errorf("formatPatternRates() = %q, expected %q", result, expected)
+	}
+	// Before the first random pass there is nothing to compare with
+	if result := formatPatternRates(map[string]float64{"zeros": 600}, "binary"); result != "zeros 600.00 MiB/s" {
+		t.Errorf("formatPatternRates() without random = %q, expected zeros alone", result)
+	}
+}
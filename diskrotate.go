@@ -0,0 +1,33 @@
+package main
+
+import "os"
+
+// createDiskFiles creates the n temp files -disk-rotate-files cycles through.
+// If one cannot be created, the ones before it are removed again.
+func createDiskFiles(dir string, n int) ([]*os.File, error) {
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		file, err := os.CreateTemp(dir, "perf_test_*.tmp")
+		if err != nil {
+			for _, created := range files {
+				created.Close()
+			}
+			removeDiskFiles(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// removeDiskFiles deletes every file, even after one of them failed, and
+// returns the first error
+func removeDiskFiles(files []*os.File) error {
+	var firstErr error
+	for _, file := range files {
+		if err := os.Remove(file.Name()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateAndRemoveDiskFiles(t *testing.T) {
+	dir := t.TempDir()
+	files, err := createDiskFiles(dir, 3)
+	if err != nil {
+		t.Fatalf("createDiskFiles() error: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, file := range files {
+		names[file.Name()] = true
+		file.Close()
+	}
+	if len(names) != 3 {
+		t.Fatalf("createDiskFiles(3) created %d distinct files", len(names))
+	}
+
+	if err := removeDiskFiles(files); err != nil {
+		t.Fatalf("removeDiskFiles() error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("removeDiskFiles() left %d files behind", len(entries))
+	}
+}
+
+func TestRemoveDiskFilesContinuesAfterError(t *testing.T) {
+	dir := t.TempDir()
+	files, err := createDiskFiles(dir, 3)
+	if err != nil {
+		t.Fatalf("createDiskFiles() error: %v", err)
+	}
+	for _, file := range files {
+		file.Close()
+	}
+
+	// A file deleted behind the tool's back must not keep the others around
+	os.Remove(files[0].Name())
+	if err := removeDiskFiles(files); err == nil {
+		t.Errorf("removeDiskFiles() should report the missing file")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("removeDiskFiles() left %d files behind", len(entries))
+	}
+}
+
+func TestCreateDiskFilesMissingDirectory(t *testing.T) {
+	if _, err := createDiskFiles(filepath.Join(t.TempDir(), "missing"), 2); err == nil {
+		t.Errorf("createDiskFiles() should fail for a missing directory")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// -disk-sparse writes one block out of every sparseStride, leaving holes in
+// between, so about an eighth of the logical size is allocated
+const sparseStride = 8
+
+// sparseOffsets returns the offsets of the blocks a sparse pass writes: the
+// first of every sparseStride blocks of the file, in a shuffled order so the
+// writes jump back and forth instead of running front to back
+func sparseOffsets(fileSize, blockSize int64, seed int64) []int64 {
+	slots := fileSize / blockSize
+	if slots < 1 {
+		slots = 1
+	}
+	offsets := make([]int64, 0, (slots+sparseStride-1)/sparseStride)
+	for slot := int64(0); slot < slots; slot += sparseStride {
+		offsets = append(offsets, slot*blockSize)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(offsets), func(i, j int) {
+		offsets[i], offsets[j] = offsets[j], offsets[i]
+	})
+	return offsets
+}
+
+// layoutSparseFile empties the file, extends it to fileSize without writing
+// anything, and writes block at every offset. The file keeps its logical
+// size while only the written blocks are allocated. It returns the bytes
+// written.
+func layoutSparseFile(file *os.File, fileSize int64, offsets []int64, block []byte) (int64, error) {
+	if err := file.Truncate(0); err != nil {
+		return 0, err
+	}
+	if err := file.Truncate(fileSize); err != nil {
+		return 0, err
+	}
+	written := int64(0)
+	for _, offset := range offsets {
+		n, err := file.WriteAt(block, offset)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// formatSparseSize describes the logical and allocated size of the sparse
+// file. A negative allocated size means the platform cannot tell.
+func formatSparseSize(logical, allocated int64, units string) string {
+	if allocated < 0 {
+		return fmt.Sprintf("%s logical, allocation unknown", formatBytes(logical, units))
+	}
+	percent := 0.0
+	if logical > 0 {
+		percent = float64(allocated) * 100 / float64(logical)
+	}
+	return fmt.Sprintf("%s logical, %s allocated (%.1f%%)", formatBytes(logical, units), formatBytes(allocated, units), percent)
+}
+
+// sparseDiskBenchmark recreates the file as a sparse file every pass, writing
+// blocks at scattered offsets of an empty file extended to fileSize, syncs it
+// and reads the blocks back. Each pass counts as an iteration. After the
+// sync the allocated size is taken from the file's stat blocks, which shows
+// how the filesystem allocates around the holes.
+func sparseDiskBenchmark(file *os.File, fileSize int64, memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	blockSize := diskBlockSize(config)
+	if blockSize > int64(len(memoryChunks[0])) {
+		blockSize = int64(len(memoryChunks[0]))
+	}
+	if blockSize > fileSize {
+		blockSize = fileSize
+	}
+	block := memoryChunks[0][:blockSize]
+	buffer := make([]byte, blockSize)
+	if config.full {
+		fmt.Printf("Disk: Writing %s blocks to a sparse file of %s, one block in %d\n",
+			formatBytes(blockSize, config.units), formatBytes(fileSize, config.units), sparseStride)
+	}
+
+	totalWriteMBps, totalReadMBps := 0.0, 0.0
+	measured := 0
+	lastReport := time.Now()
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+	resetsSeen := diskStats.resets.Load()
+	allocationReported := false
+	// A stop between passes and one while reading back end the same way
+	stopped := func(completed int64) {
+		if config.full {
+			fmt.Printf("Disk: Completed %d sparse passes\n", completed)
+		}
+	}
+
+	for pass := int64(1); ; pass++ {
+		select {
+		case <-stopChan:
+			stopped(pass - 1)
+			return
+		default:
+		}
+		if diskStats.resetSince(&resetsSeen) {
+			measured, totalWriteMBps, totalReadMBps = 0, 0, 0
+		}
+
+		offsets := sparseOffsets(fileSize, blockSize, pass)
+		if budget := diskStats.remainingBudget(config); budget >= 0 && budget < int64(len(offsets))*blockSize {
+			fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+				formatBytes(config.diskTotalLimit, config.units))
+			return
+		}
+
+		writeStart := time.Now()
+		written, err := layoutSparseFile(file, fileSize, offsets, block)
+		diskStats.bytesWritten.Add(written)
+		if err != nil {
+			failures.Record("Disk", "Error writing sparse file: %v", err)
+			return
+		}
+		if err := file.Sync(); err != nil {
+			failures.Record("Disk", "Error syncing file: %v", err)
+			return
+		}
+		writeMBps := mbPerSecond(written, time.Since(writeStart))
+
+		allocated, err := allocatedSize(file)
+		if err != nil {
+			if config.full && !allocationReported {
+				fmt.Printf("Disk: Allocated size unavailable: %v\n", err)
+			}
+			allocationReported = true
+			allocated = -1
+		}
+		diskStats.sparseLogical.Store(fileSize)
+		diskStats.sparseAllocated.Store(allocated)
+
+		readStart := time.Now()
+		read := int64(0)
+		for _, offset := range offsets {
+			select {
+			case <-stopChan:
+				stopped(pass - 1)
+				return
+			default:
+			}
+			n, err := file.ReadAt(buffer, offset)
+			diskStats.bytesRead.Add(int64(n))
+			read += int64(n)
+			if err != nil {
+				failures.Record("Disk", "Read error at offset %d: %v", offset, err)
+				return
+			}
+		}
+		readMBps := mbPerSecond(read, time.Since(readStart))
+
+		// The first -warmup-iterations run but are not averaged
+		if pass > int64(config.warmupIters) {
+			measured++
+			totalWriteMBps += writeMBps
+			totalReadMBps += readMBps
+			if sampleRates(config) {
+				diskStats.addWriteRate(writeMBps)
+			}
+			avgWriteMBps := totalWriteMBps / float64(measured)
+			avgReadMBps := totalReadMBps / float64(measured)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.DiskWriteMBps = avgWriteMBps
+				snapshot.DiskReadMBps = avgReadMBps
+			})
+			diskStats.iterations.Add(1)
+		}
+
+		if benchmarkReports(config) && time.Since(lastReport) >= reportInterval {
+			snapshot := metrics.Snapshot()
+			fmt.Printf("Disk: sparse write %s, read %s, %s%s\n",
+				formatMBps(snapshot.DiskWriteMBps, config.units), formatMBps(snapshot.DiskReadMBps, config.units),
+				formatSparseSize(fileSize, allocated, config.units), progressSuffix(metrics))
+			lastReport = time.Now()
+			reportInterval = nextReportInterval(reportInterval, config)
+		}
+	}
+}
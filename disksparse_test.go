@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestSparseOffsets(t *testing.T) {
+	tests := []struct {
+		fileSize  int64
+		blockSize int64
+		expected  []int64
+	}{
+		{64 * 4096, 4096, []int64{0, 8 * 4096, 16 * 4096, 24 * 4096, 32 * 4096, 40 * 4096, 48 * 4096, 56 * 4096}},
+		{10 * 4096, 4096, []int64{0, 8 * 4096}},
+		{4096, 4096, []int64{0}},
+		// A file smaller than a block still gets one
+		{100, 4096, []int64{0}},
+	}
+
+	for _, test := range tests {
+		offsets := sparseOffsets(test.fileSize, test.blockSize, 1)
+		sorted := append([]int64(nil), offsets...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		if len(sorted) != len(test.expected) {
+			t.Errorf("sparseOffsets(%d, %d) = %v, expected %v in any order", test.fileSize, test.blockSize, offsets, test.expected)
+			continue
+		}
+		for i := range sorted {
+			if sorted[i] != test.expected[i] {
+				t.Errorf("sparseOffsets(%d, %d) = %v, expected %v in any order", test.fileSize, test.blockSize, offsets, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestSparseOffsetsShuffled(t *testing.T) {
+	offsets := sparseOffsets(1024*4096, 4096, 1)
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return
+		}
+	}
+	t.Errorf("sparseOffsets() wrote %d blocks front to back, expected a scattered order", len(offsets))
+}
+
+func TestFormatSparseSize(t *testing.T) {
+	tests := []struct {
+		logical   int64
+		allocated int64
+		expected  string
+	}{
+		{64 * 1024 * 1024, 8 * 1024 * 1024, "64.00 MiB logical, 8.00 MiB allocated (12.5%)"},
+		{64 * 1024 * 1024, -1, "64.00 MiB logical, allocation unknown"},
+	}
+
+	for _, test := range tests {
+		if formatted := formatSparseSize(test.logical, test.allocated, "binary"); formatted != test.expected {
+			t.Errorf("formatSparseSize(%d, %d) = %q, expected %q", test.logical, test.allocated, formatted, test.expected)
+		}
+	}
+}
+
+func TestLayoutSparseFileAllocatesLess(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	const fileSize, blockSize = 8 * 1024 * 1024, 64 * 1024
+	offsets := sparseOffsets(fileSize, blockSize, 1)
+	written, err := layoutSparseFile(file, fileSize, offsets, make([]byte, blockSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := int64(len(offsets)) * blockSize; written != expected {
+		t.Errorf("layoutSparseFile() wrote %d bytes, expected %d", written, expected)
+	}
+	if err := file.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != fileSize {
+		t.Errorf("sparse file is %d bytes, expected %d", info.Size(), fileSize)
+	}
+	allocated, err := allocatedSize(file)
+	if err != nil {
+		t.Skipf("allocated size unavailable: %v", err)
+	}
+	if allocated >= fileSize {
+		t.Errorf("sparse file allocates %d bytes, expected fewer than its %d logical bytes", allocated, fileSize)
+	}
+}
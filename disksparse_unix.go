@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocatedSize is the space the file takes up on disk. Stat counts blocks
+// of 512 bytes whatever the filesystem block size is.
+func allocatedSize(file *os.File) (int64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(int(file.Fd()), &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Blocks) * 512, nil
+}
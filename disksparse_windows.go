@@ -0,0 +1,11 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Windows has no stat blocks, so the allocated size is unknown
+func allocatedSize(file *os.File) (int64, error) {
+	return 0, errors.New("allocated size is not available on Windows")
+}
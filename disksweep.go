@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bytes written per -disk-bs-sweep iteration when -disk-file-size is not set
+const diskSweepFileSize = 64 * 1024 * 1024
+
+type BlockSizeSweepResult struct {
+	BlockSize int64   `json:"block_size"`
+	WriteMBps float64 `json:"write_mbps"`
+	ReadMBps  float64 `json:"read_mbps"`
+	WriteIOPS float64 `json:"write_iops"`
+	ReadIOPS  float64 `json:"read_iops"`
+}
+
+// parseBlockSizeSweep expands "start:end:step" into the block sizes to test,
+// including end when it falls on a step. A step of "xN" multiplies the size
+// by N each time, any other step is a size added each time.
+func parseBlockSizeSweep(spec string) ([]int64, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("sweep %q must have the form start:end:step", spec)
+	}
+
+	var bounds [2]int64
+	for i, part := range parts[:2] {
+		value, err := parseSize(part)
+		if err != nil {
+			return nil, fmt.Errorf("sweep %q: %v", spec, err)
+		}
+		bounds[i] = value
+	}
+	start, end := bounds[0], bounds[1]
+	if start <= 0 {
+		return nil, fmt.Errorf("sweep %q: start must be positive", spec)
+	}
+	if end < start {
+		return nil, fmt.Errorf("sweep %q: end must not be below start", spec)
+	}
+
+	step := strings.TrimSpace(parts[2])
+	var sizes []int64
+	if strings.HasPrefix(strings.ToLower(step), "x") {
+		factor := step[1:]
+		multiplier, err := strconv.ParseInt(factor, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sweep %q: %q is not a number", spec, factor)
+		}
+		if multiplier < 2 {
+			return nil, fmt.Errorf("sweep %q: factor must be at least 2", spec)
+		}
+		// Comparing against end/multiplier keeps the product from overflowing
+		for size := start; ; size *= multiplier {
+			sizes = append(sizes, size)
+			if size > end/multiplier {
+				break
+			}
+		}
+		return sizes, nil
+	}
+
+	increment, err := parseSize(step)
+	if err != nil {
+		return nil, fmt.Errorf("sweep %q: %v", spec, err)
+	}
+	if increment <= 0 {
+		return nil, fmt.Errorf("sweep %q: step must be positive", spec)
+	}
+	for size := start; size <= end; size += increment {
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// runBlockSizeSweep runs the filesystem benchmark once per block size with
+// fresh metrics, printing a row per step as it completes. Reads use the block
+// size as well, so both directions are measured per operation size. The
+// steps share diskStats, so the summary totals and -disk-total-limit cover
+// the whole sweep.
+func runBlockSizeSweep(sigChan <-chan os.Signal, config Config, diskStats *DiskStats, failures *FailureLog) []BlockSizeSweepResult {
+	stepDuration := config.duration
+	if stepDuration == 0 {
+		stepDuration = defaultSweepStepDuration
+	}
+
+	// Every chunk must hold the largest block, or the writes are cut short
+	chunkSize := int64(1024 * 1024)
+	if largest := config.diskBSSweep[len(config.diskBSSweep)-1]; largest > chunkSize {
+		chunkSize = largest
+	}
+	fileSize := diskFileSizeFor(config, diskPaths(config)[0])
+	if fileSize == 0 {
+		fileSize = diskSweepFileSize
+	}
+	count := fileSize / chunkSize
+	if count < 1 {
+		count = 1
+	}
+	chunks := make([][]byte, count)
+	for i := range chunks {
+		chunks[i] = make([]byte, chunkSize)
+	}
+
+	fmt.Printf("=== Disk block size sweep: %d steps of %v ===\n", len(config.diskBSSweep), stepDuration)
+	fmt.Printf("%10s  %14s  %14s  %12s  %12s\n", "Block", "Write", "Read", "Write IOPS", "Read IOPS")
+
+	var results []BlockSizeSweepResult
+	for _, blockSize := range config.diskBSSweep {
+		stepConfig := config
+		stepConfig.diskBlockSize = blockSize
+		stepConfig.diskReadBuffer = blockSize
+		stepConfig.diskFileSize = fileSize
+		// Interval reports would interleave with the table, so only the row is printed
+		stepConfig.full = false
+		stepConfig.reportInterval = 3600
+		stepConfig.reportBackoff = 2
+		stepConfig.reportBackoffMax = time.Hour
+		stepMetrics := &Metrics{}
+
+		stepStop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			filesystemBenchmark(chunks, stepStop, stepConfig, diskStats, stepMetrics, failures)
+		}()
+		interrupted := waitForStop(sigChan, stepDuration)
+		close(stepStop)
+		<-done
+
+		snapshot := stepMetrics.Snapshot()
+		result := BlockSizeSweepResult{
+			BlockSize: blockSize,
+			WriteMBps: snapshot.DiskWriteMBps,
+			ReadMBps:  snapshot.DiskReadMBps,
+			WriteIOPS: snapshot.DiskWriteMBps * 1024 * 1024 / float64(blockSize),
+			ReadIOPS:  snapshot.DiskReadMBps * 1024 * 1024 / float64(blockSize),
+		}
+		results = append(results, result)
+		fmt.Printf("%10s  %14s  %14s  %12s  %12s\n", formatBytes(blockSize, config.units),
+			formatMBps(result.WriteMBps, config.units), formatMBps(result.ReadMBps, config.units),
+			formatWithCommas(result.WriteIOPS), formatWithCommas(result.ReadIOPS))
+
+		if interrupted {
+			if config.full {
+				fmt.Println("\nReceived interrupt signal, stopping sweep...")
+			}
+			break
+		}
+	}
+	return results
+}
+
+// blockSizeSweepValue is -disk-bs-sweep. String rebuilds a spec that expands
+// to the same sizes, so the flag round-trips through -dump-config.
+type blockSizeSweepValue []int64
+
+func (v *blockSizeSweepValue) String() string {
+	if v == nil || len(*v) == 0 {
+		return ""
+	}
+	sizes := *v
+	first, last := sizes[0], sizes[len(sizes)-1]
+	if len(sizes) == 1 {
+		return fmt.Sprintf("%d:%d:%d", first, last, first)
+	}
+	if len(sizes) > 2 && sizes[1]-sizes[0] != sizes[2]-sizes[1] {
+		return fmt.Sprintf("%d:%d:x%d", first, last, sizes[1]/sizes[0])
+	}
+	return fmt.Sprintf("%d:%d:%d", first, last, sizes[1]-sizes[0])
+}
+
+func (v *blockSizeSweepValue) Set(spec string) error {
+	if spec == "" {
+		*v = nil
+		return nil
+	}
+	sizes, err := parseBlockSizeSweep(spec)
+	*v = sizes
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBlockSizeSweep(t *testing.T) {
+	tests := []struct {
+		spec       string
+		expected   []int64
+		shouldFail bool
+	}{
+		{"4K:1M:x2", []int64{4096, 8192, 16384, 32768, 65536, 131072, 262144, 524288, 1048576}, false},
+		{"4K:100K:x4", []int64{4096, 16384, 65536}, false},
+		{"4K:16K:X2", []int64{4096, 8192, 16384}, false},
+		{"4K:16K:4K", []int64{4096, 8192, 12288, 16384}, false},
+		{"4K:15K:4K", []int64{4096, 8192, 12288}, false},
+		{"512:512:1", []int64{512}, false},
+		{" 4K : 8K : x2 ", []int64{4096, 8192}, false},
+		{"4K:1M", nil, true},
+		{"4K:1M:x2:1", nil, true},
+		{"a:1M:x2", nil, true},
+		{"0:1M:x2", nil, true},
+		{"1M:4K:x2", nil, true},
+		{"4K:1M:x1", nil, true},
+		{"4K:1M:xa", nil, true},
+		{"4K:1M:0", nil, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseBlockSizeSweep(test.spec)
+		if (err != nil) != test.shouldFail {
+			t.Errorf("parseBlockSizeSweep(%q) error = %v, expected shouldFail=%v", test.spec, err, test.shouldFail)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseBlockSizeSweep(%q) = %v, expected %v", test.spec, result, test.expected)
+		}
+	}
+}
+
+func TestBlockSizeSweepValueRoundTrip(t *testing.T) {
+	for _, spec := range []string{"4K:1M:x2", "4K:16K:4K", "4K:8K:x2", "512:512:1"} {
+		var value, roundTrip blockSizeSweepValue
+		if err := value.Set(spec); err != nil {
+			t.Fatalf("Set(%q) error = %v", spec, err)
+		}
+		if err := roundTrip.Set(value.String()); err != nil {
+			t.Fatalf("Set(%q) error = %v", value.String(), err)
+		}
+		if !reflect.DeepEqual(roundTrip, value) {
+			t.Errorf("%q round-trips to %v, expected %v", spec, roundTrip, value)
+		}
+	}
+}
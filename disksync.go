@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// reopenSyncWrites replaces every file by one opened for synchronous writes,
+// so each write returns only once its data is durable, for
+// -disk-o-sync-every-write. The originals are closed.
+func reopenSyncWrites(files []*os.File) ([]*os.File, error) {
+	return reopenFiles(files, syncWriteFlag)
+}
+
+// reopenFiles replaces every file by one opened read-write with the extra
+// open flag. The originals are closed once all are reopened.
+func reopenFiles(files []*os.File, flag int) ([]*os.File, error) {
+	reopened := make([]*os.File, 0, len(files))
+	for _, file := range files {
+		reopenedFile, err := os.OpenFile(file.Name(), os.O_RDWR|flag, 0)
+		if err != nil {
+			for _, opened := range reopened {
+				opened.Close()
+			}
+			return nil, err
+		}
+		reopened = append(reopened, reopenedFile)
+	}
+	for _, file := range files {
+		file.Close()
+	}
+	return reopened, nil
+}
+
+// formatSyncWrites reports the latency of durable block writes and the
+// write rate that follows from the average throughput
+func formatSyncWrites(latency *Reservoir, avgWriteMBps float64, blockSize int64) string {
+	writesPerSec := avgWriteMBps * 1024 * 1024 / float64(blockSize)
+	return fmt.Sprintf("Disk: durable write latency p50 %v, p90 %v, p99 %v, %.0f writes/s",
+		latency.Percentile(50).Round(time.Microsecond), latency.Percentile(90).Round(time.Microsecond),
+		latency.Percentile(99).Round(time.Microsecond), writesPerSec)
+}
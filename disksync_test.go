@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReopenSyncWrites(t *testing.T) {
+	files, err := createDiskFiles(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncFiles, err := reopenSyncWrites(files)
+	if err != nil {
+		t.Fatalf("reopenSyncWrites() returned error: %v", err)
+	}
+	defer removeDiskFiles(syncFiles)
+	for i, file := range syncFiles {
+		defer file.Close()
+		if file.Name() != files[i].Name() {
+			t.Errorf("reopenSyncWrites() opened %s for %s", file.Name(), files[i].Name())
+		}
+		if _, err := files[i].Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("original file still open after reopenSyncWrites(): %v", err)
+		}
+		if _, err := file.Write([]byte("durable")); err != nil {
+			t.Errorf("write to the reopened file failed: %v", err)
+		}
+		if data, _ := os.ReadFile(file.Name()); string(data) != "durable" {
+			t.Errorf("reopened file holds %q, expected \"durable\"", data)
+		}
+	}
+}
+
+func TestFormatSyncWrites(t *testing.T) {
+	latency := newReservoir(100, 1)
+	for i := 1; i <= 100; i++ {
+		latency.Add(time.Duration(i) * time.Millisecond)
+	}
+	// 4 MiB/s of 4 KiB blocks is 1,024 writes per second
+	result := formatSyncWrites(latency, 4, 4096)
+	if !strings.HasPrefix(result, "Disk: durable write latency p50 50ms") || !strings.HasSuffix(result, ", 1024 writes/s") {
+		t.Errorf("formatSyncWrites() = %q", result)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+func isBlockDevice(info os.FileInfo) bool {
+	return info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0
+}
+
+// checkDiskTarget makes sure -disk-target can be written, creating it if it
+// does not exist yet. It reports whether the target is a block device.
+func checkDiskTarget(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		return false, errors.New("is a directory, use -disk-path for directories")
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	if err := file.Close(); err != nil {
+		return false, err
+	}
+	return info != nil && isBlockDevice(info), nil
+}
+
+// openDiskTarget opens -disk-target for the benchmark. Devices cannot grow,
+// so for a block device it also returns its size to cap the file size.
+func openDiskTarget(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	if !isBlockDevice(info) {
+		return file, 0, nil
+	}
+
+	// The size of a block device is where seeking to its end lands
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, size, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDiskTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "target.dat")
+	blockDevice, err := checkDiskTarget(path)
+	if err != nil || blockDevice {
+		t.Fatalf("checkDiskTarget(new file) = %v, %v, expected a writable regular file", blockDevice, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("checkDiskTarget() did not create the missing target: %v", err)
+	}
+
+	if _, err := checkDiskTarget(dir); err == nil {
+		t.Errorf("checkDiskTarget(directory) expected an error")
+	}
+	if _, err := checkDiskTarget(filepath.Join(dir, "missing", "target.dat")); err == nil {
+		t.Errorf("checkDiskTarget() in a missing directory expected an error")
+	}
+}
+
+func TestIsBlockDevice(t *testing.T) {
+	info, err := os.Stat(os.DevNull)
+	if err != nil {
+		t.Skipf("Cannot stat %s: %v", os.DevNull, err)
+	}
+	if isBlockDevice(info) {
+		t.Errorf("isBlockDevice(%s) = true, expected false for a character device", os.DevNull)
+	}
+}
+
+func TestOpenDiskTargetKeepsData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "target.dat")
+	if err := os.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatalf("Cannot create target: %v", err)
+	}
+
+	file, deviceSize, err := openDiskTarget(path)
+	if err != nil {
+		t.Fatalf("openDiskTarget() returned error: %v", err)
+	}
+	defer file.Close()
+
+	if deviceSize != 0 {
+		t.Errorf("openDiskTarget() of a regular file returned device size %d, expected 0", deviceSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "existing" {
+		t.Errorf("openDiskTarget() changed the target to %q, %v", data, err)
+	}
+}
+
+func TestFilesystemBenchmarkKeepsTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "target.dat")
+	config := Config{diskTarget: path, chunkSizeMB: 1, diskFileSize: 1024 * 1024, diskRWMix: -1, reportInterval: 3600, reportBackoff: 1}
+	chunks := [][]byte{make([]byte, 1024*1024)}
+
+	stopChan := make(chan struct{})
+	close(stopChan)
+	filesystemBenchmark(chunks, stopChan, config, &DiskStats{}, &Metrics{}, nil)
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Disk target was removed after the benchmark: %v", err)
+	}
+}
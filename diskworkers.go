@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskPathEntry is one entry of -disk-path with the file size written
+// there, or 0 to use -disk-file-size
+type diskPathEntry struct {
+	path string
+	size int64
+}
+
+// parseDiskPathEntry splits "path:size" into its path and file size. An
+// entry without a size keeps size 0. The colon of a Windows drive letter
+// belongs to the path, and a trailing colon with no size is dropped, so a
+// path that contains a colon can be given as "/mnt/a:b:".
+func parseDiskPathEntry(entry string) (diskPathEntry, error) {
+	index := strings.LastIndex(entry, ":")
+	if index <= 1 {
+		return diskPathEntry{path: entry}, nil
+	}
+	if strings.TrimSpace(entry[index+1:]) == "" {
+		return diskPathEntry{path: strings.TrimSpace(entry[:index])}, nil
+	}
+	size, err := parseSize(entry[index+1:])
+	if err != nil {
+		return diskPathEntry{}, fmt.Errorf("disk path %q: %v", entry, err)
+	}
+	if size <= 0 {
+		return diskPathEntry{}, fmt.Errorf("disk path %q: size must be positive", entry)
+	}
+	return diskPathEntry{path: strings.TrimSpace(entry[:index]), size: size}, nil
+}
+
+// parseDiskPathList splits the comma-separated -disk-path list into its
+// entries, dropping repeats of a path, so its first size wins. It returns
+// the entries before the first invalid one together with the error.
+func parseDiskPathList(spec string) ([]diskPathEntry, error) {
+	var entries []diskPathEntry
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		entry, err := parseDiskPathEntry(field)
+		if err != nil {
+			return entries, err
+		}
+		if !seen[entry.path] {
+			entries = append(entries, entry)
+			seen[entry.path] = true
+		}
+	}
+	return entries, nil
+}
+
+// diskPaths returns the paths of the -disk-path list without their sizes.
+// An empty list keeps the single empty path, the system temp directory.
+func diskPaths(config Config) []string {
+	entries, _ := parseDiskPathList(config.diskPath)
+	if len(entries) == 0 {
+		return []string{""}
+	}
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.path
+	}
+	return paths
+}
+
+// diskFileSizeFor returns the bytes written per iteration in path: the
+// size given with it in -disk-path, else -disk-file-size
+func diskFileSizeFor(config Config, path string) int64 {
+	entries, _ := parseDiskPathList(config.diskPath)
+	for _, entry := range entries {
+		if entry.path == path && entry.size > 0 {
+			return entry.size
+		}
+	}
+	return config.diskFileSize
+}
+
+// parallelDisk reports whether the disk test runs several workers at once
+// instead of the single sequential benchmark
+func parallelDisk(config Config) bool {
+	return len(diskPaths(config)) > 1 || config.diskWorkers > 1
+}
+
+// diskWorker is one concurrent disk benchmark: its path, its index among
+// the workers of that path, and the memory chunks it writes from
+type diskWorker struct {
+	path   string
+	index  int
+	chunks [][]byte
+}
+
+// assignDiskWorkers creates perPath workers for every path and deals the
+// memory chunks out round-robin, so no two workers write from the same
+// chunk. Every worker needs at least one chunk.
+func assignDiskWorkers(paths []string, perPath int, memoryChunks [][]byte) ([]diskWorker, error) {
+	count := len(paths) * perPath
+	if len(memoryChunks) < count {
+		return nil, fmt.Errorf("%d disk workers need at least as many memory chunks, have %d", count, len(memoryChunks))
+	}
+
+	workers := make([]diskWorker, 0, count)
+	for _, path := range paths {
+		for i := 0; i < perPath; i++ {
+			workers = append(workers, diskWorker{path: path, index: i})
+		}
+	}
+	for i, chunk := range memoryChunks {
+		workers[i%count].chunks = append(workers[i%count].chunks, chunk)
+	}
+	return workers, nil
+}
+
+// diskPathStats sums the average throughput of the workers on one path
+type diskPathStats struct {
+	path string
+
+	mu        sync.Mutex
+	writeMBps []float64
+	readMBps  []float64
+}
+
+func newDiskPathStats(path string, workers int) *diskPathStats {
+	return &diskPathStats{path: path, writeMBps: make([]float64, workers), readMBps: make([]float64, workers)}
+}
+
+func (s *diskPathStats) Update(worker int, writeMBps, readMBps float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeMBps[worker] = writeMBps
+	s.readMBps[worker] = readMBps
+}
+
+func (s *diskPathStats) Total() (writeMBps, readMBps float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.writeMBps {
+		writeMBps += s.writeMBps[i]
+		readMBps += s.readMBps[i]
+	}
+	return writeMBps, readMBps
+}
+
+// parallelDiskBenchmark runs -disk-workers-per-path workers on every path of
+// -disk-path, each rewriting its own temp file, and reports the throughput
+// per path and in total.
+func parallelDiskBenchmark(memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	paths := diskPaths(config)
+	workers, err := assignDiskWorkers(paths, config.diskWorkers, memoryChunks)
+	if err != nil {
+		failures.Record("Disk", "%v", err)
+		return
+	}
+	if config.full {
+		fmt.Printf("Disk: Starting %d workers on each of %s\n", config.diskWorkers, strings.Join(paths, ", "))
+	}
+
+	pathStats := make(map[string]*diskPathStats)
+	for _, path := range paths {
+		pathStats[path] = newDiskPathStats(path, config.diskWorkers)
+	}
+
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker diskWorker) {
+			defer wg.Done()
+			runDiskWorker(worker, stopChan, config, pathStats[worker.path], diskStats, failures)
+		}(worker)
+	}
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
+
+	interval := time.Duration(config.reportInterval) * time.Second
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-workersDone:
+			updateDiskPathMetrics(paths, pathStats, metrics)
+			if diskStats.remainingBudget(config) == 0 {
+				fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+					formatBytes(config.diskTotalLimit, config.units))
+			}
+			return
+		case <-timer.C:
+		}
+
+		totalWrite, totalRead := updateDiskPathMetrics(paths, pathStats, metrics)
+		if benchmarkReports(config) {
+			for _, path := range paths {
+				writeMBps, readMBps := pathStats[path].Total()
+				fmt.Printf("Disk: %s write %s, read %s (%d workers)\n", path,
+					formatMBps(writeMBps, config.units), formatMBps(readMBps, config.units), config.diskWorkers)
+			}
+			fmt.Printf("Disk: total write %s, read %s%s\n", formatMBps(totalWrite, config.units), formatMBps(totalRead, config.units),
+				progressSuffix(metrics))
+		}
+
+		interval = nextReportInterval(interval, config)
+		timer.Reset(interval)
+	}
+}
+
+// updateDiskPathMetrics publishes the throughput summed over all paths
+func updateDiskPathMetrics(paths []string, pathStats map[string]*diskPathStats, metrics *Metrics) (writeMBps, readMBps float64) {
+	for _, path := range paths {
+		pathWrite, pathRead := pathStats[path].Total()
+		writeMBps += pathWrite
+		readMBps += pathRead
+	}
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.DiskWriteMBps = writeMBps
+		snapshot.DiskReadMBps = readMBps
+	})
+	return writeMBps, readMBps
+}
+
+// runDiskWorker writes the worker's chunks to its own temp file and reads
+// them back until stopChan closes, recording its average throughput
+func runDiskWorker(worker diskWorker, stopChan <-chan struct{}, config Config, stats *diskPathStats, diskStats *DiskStats, failures *FailureLog) {
+	files, err := createDiskFiles(worker.path, 1)
+	if err != nil {
+		failures.Record("Disk", "Error creating temp file in %s: %v", worker.path, err)
+		return
+	}
+	file := files[0]
+	defer func() {
+		if err := file.Close(); err != nil {
+			failures.Record("Disk", "Error closing temp file: %v", err)
+		}
+		if err := removeDiskFiles(files); err != nil {
+			failures.Record("Disk", "Error removing temp file: %v", err)
+		}
+	}()
+
+	// Without a file size each worker writes its share of the allocation
+	fileSize := diskFileSizeFor(config, worker.path)
+	if fileSize == 0 {
+		for _, chunk := range worker.chunks {
+			fileSize += int64(len(chunk))
+		}
+	}
+
+	blockSize := diskBlockSize(config)
+	buffer := make([]byte, diskReadBufferSize(config))
+	totalWriteMBps, totalReadMBps := 0.0, 0.0
+	measured := 0
+	resetsSeen := diskStats.resets.Load()
+	for iteration := 1; ; iteration++ {
+		select {
+		case <-stopChan:
+			return
+		default:
+		}
+		if diskStats.resetSince(&resetsSeen) {
+			measured, totalWriteMBps, totalReadMBps = 0, 0, 0
+		}
+
+		written, ok := writeWorkerFile(file, fileSize, blockSize, worker.chunks, stopChan, config, diskStats, failures)
+		if !ok {
+			return
+		}
+
+		if _, err := file.Seek(0, 0); err != nil {
+			failures.Record("Disk", "Error seeking file: %v", err)
+			return
+		}
+		readStart := time.Now()
+		read, stopped, err := readToEOF(file, buffer, stopChan)
+		diskStats.bytesRead.Add(read)
+		if stopped {
+			return
+		}
+		if err != nil {
+			failures.Record("Disk", "Read error: %v", err)
+		}
+		readDuration := time.Since(readStart)
+
+		// The first -warmup-iterations only count toward the write limit
+		if iteration > config.warmupIters {
+			measured++
+			totalWriteMBps += written
+			if sampleRates(config) {
+				diskStats.addWriteRate(written)
+			}
+			totalReadMBps += mbPerSecond(read, readDuration)
+			stats.Update(worker.index, totalWriteMBps/float64(measured), totalReadMBps/float64(measured))
+			diskStats.iterations.Add(1)
+		}
+		if diskStats.remainingBudget(config) == 0 {
+			return
+		}
+	}
+}
+
+// writeWorkerFile rewrites file with fileSize bytes of random data and
+// returns the write throughput in MB/s. It reports false once the worker
+// should stop.
+func writeWorkerFile(file *os.File, fileSize, blockSize int64, chunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, failures *FailureLog) (float64, bool) {
+	if _, err := file.Seek(0, 0); err != nil {
+		failures.Record("Disk", "Error seeking file: %v", err)
+		return 0, false
+	}
+	if err := file.Truncate(0); err != nil {
+		failures.Record("Disk", "Error truncating file: %v", err)
+		return 0, false
+	}
+
+	writeStart := time.Now()
+	written := int64(0)
+	for chunkIndex := 0; written < fileSize; chunkIndex++ {
+		select {
+		case <-stopChan:
+			return 0, false
+		default:
+		}
+
+		chunk := chunks[chunkIndex%len(chunks)]
+		if remaining := fileSize - written; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if err := fillDiskChunk(chunk, config.diskPattern); err != nil {
+			return 0, false
+		}
+
+		for len(chunk) > 0 {
+			block := chunk
+			if int64(len(block)) > blockSize {
+				block = block[:blockSize]
+			}
+			if budget := diskStats.remainingBudget(config); budget >= 0 && budget < int64(len(block)) {
+				block = block[:budget]
+			}
+			if len(block) == 0 {
+				return 0, false
+			}
+
+			n, err := file.Write(block)
+			diskStats.bytesWritten.Add(int64(n))
+			if err != nil {
+				failures.Record("Disk", "Write error: %v", err)
+				return 0, false
+			}
+			written += int64(n)
+			chunk = chunk[n:]
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		failures.Record("Disk", "Error syncing file: %v", err)
+		return 0, false
+	}
+	return mbPerSecond(written, time.Since(writeStart)), true
+}
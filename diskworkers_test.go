@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiskPaths(t *testing.T) {
+	tests := []struct {
+		diskPath string
+		expected []string
+	}{
+		{"./", []string{"./"}},
+		{"/mnt/a,/mnt/b", []string{"/mnt/a", "/mnt/b"}},
+		{" /mnt/a , /mnt/b ,", []string{"/mnt/a", "/mnt/b"}},
+		{"/mnt/a,/mnt/a", []string{"/mnt/a"}},
+		{"", []string{""}},
+		{"/mnt/fast:10GB,/mnt/slow:1GB", []string{"/mnt/fast", "/mnt/slow"}},
+		{"/mnt/a:1GB,/mnt/a", []string{"/mnt/a"}},
+	}
+
+	for _, test := range tests {
+		if result := diskPaths(Config{diskPath: test.diskPath}); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("diskPaths(%q) = %q, expected %q", test.diskPath, result, test.expected)
+		}
+	}
+}
+
+func TestParseDiskPathList(t *testing.T) {
+	tests := []struct {
+		spec       string
+		expected   []diskPathEntry
+		shouldFail bool
+	}{
+		{"/mnt/fast:10GB,/mnt/slow:1GB", []diskPathEntry{{"/mnt/fast", 10 << 30}, {"/mnt/slow", 1 << 30}}, false},
+		{"/mnt/fast:10GB,/mnt/slow", []diskPathEntry{{"/mnt/fast", 10 << 30}, {"/mnt/slow", 0}}, false},
+		{" /mnt/a : 512MB , ./ ", []diskPathEntry{{"/mnt/a", 512 << 20}, {"./", 0}}, false},
+		// The first size of a repeated path wins
+		{"/mnt/a:1GB,/mnt/a:2GB", []diskPathEntry{{"/mnt/a", 1 << 30}}, false},
+		// Windows drive letters are part of the path
+		{`C:\data,D:\bench:4GB`, []diskPathEntry{{`C:\data`, 0}, {`D:\bench`, 4 << 30}}, false},
+		{"C:", []diskPathEntry{{"C:", 0}}, false},
+		{"", nil, false},
+		{"/mnt/a:lots", nil, true},
+		// A trailing colon keeps a colon inside the path
+		{"/mnt/a:b:", []diskPathEntry{{"/mnt/a:b", 0}}, false},
+		{"/mnt/a:b:1GB", []diskPathEntry{{"/mnt/a:b", 1 << 30}}, false},
+		{"/mnt/a:b", nil, true},
+		{"/mnt/a:0", nil, true},
+		{"/mnt/a:1GB,/mnt/b:-1GB", []diskPathEntry{{"/mnt/a", 1 << 30}}, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseDiskPathList(test.spec)
+		if (err != nil) != test.shouldFail {
+			t.Errorf("parseDiskPathList(%q) error = %v, expected shouldFail=%v", test.spec, err, test.shouldFail)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseDiskPathList(%q) = %+v, expected %+v", test.spec, result, test.expected)
+		}
+	}
+}
+
+func TestDiskFileSizeFor(t *testing.T) {
+	config := Config{diskPath: "/mnt/fast:10GB,/mnt/slow", diskFileSize: 1 << 30}
+	tests := []struct {
+		path     string
+		expected int64
+	}{
+		{"/mnt/fast", 10 << 30},
+		// Paths without a size fall back to -disk-file-size
+		{"/mnt/slow", 1 << 30},
+	}
+
+	for _, test := range tests {
+		if size := diskFileSizeFor(config, test.path); size != test.expected {
+			t.Errorf("diskFileSizeFor(%q) = %d, expected %d", test.path, size, test.expected)
+		}
+	}
+}
+
+func TestAssignDiskWorkers(t *testing.T) {
+	chunks := make([][]byte, 5)
+	for i := range chunks {
+		chunks[i] = make([]byte, i+1)
+	}
+
+	workers, err := assignDiskWorkers([]string{"a", "b"}, 2, chunks)
+	if err != nil {
+		t.Fatalf("assignDiskWorkers() error: %v", err)
+	}
+
+	expected := []struct {
+		path       string
+		index      int
+		chunkSizes []int
+	}{
+		{"a", 0, []int{1, 5}},
+		{"a", 1, []int{2}},
+		{"b", 0, []int{3}},
+		{"b", 1, []int{4}},
+	}
+	if len(workers) != len(expected) {
+		t.Fatalf("assignDiskWorkers() created %d workers, expected %d", len(workers), len(expected))
+	}
+	for i, worker := range workers {
+		var sizes []int
+		for _, chunk := range worker.chunks {
+			sizes = append(sizes, len(chunk))
+		}
+		if worker.path != expected[i].path || worker.index != expected[i].index || !reflect.DeepEqual(sizes, expected[i].chunkSizes) {
+			t.Errorf("worker %d = %s/%d with chunks %v, expected %s/%d with %v",
+				i, worker.path, worker.index, sizes, expected[i].path, expected[i].index, expected[i].chunkSizes)
+		}
+	}
+
+	if _, err := assignDiskWorkers([]string{"a", "b"}, 3, chunks); err == nil {
+		t.Errorf("assignDiskWorkers() should fail with fewer chunks than workers")
+	}
+}
+
+func TestParallelDiskBenchmark(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	config := Config{diskPath: dirs[0] + "," + dirs[1], diskWorkers: 2, chunkSizeMB: 1, diskRWMix: -1, reportInterval: 3600, reportBackoff: 1}
+	chunks := make([][]byte, 4)
+	for i := range chunks {
+		chunks[i] = make([]byte, 64*1024)
+	}
+
+	stopChan := make(chan struct{})
+	time.AfterFunc(200*time.Millisecond, func() { close(stopChan) })
+	diskStats := &DiskStats{}
+	metrics := &Metrics{}
+	parallelDiskBenchmark(chunks, stopChan, config, diskStats, metrics, nil)
+
+	// Every worker completes at least one iteration in that time
+	if iterations := diskStats.iterations.Load(); iterations < 4 {
+		t.Errorf("parallelDiskBenchmark() completed %d iterations, expected at least one per worker", iterations)
+	}
+	if snapshot := metrics.Snapshot(); snapshot.DiskWriteMBps <= 0 || snapshot.DiskReadMBps <= 0 {
+		t.Errorf("parallelDiskBenchmark() reported write %.1f, read %.1f MB/s", snapshot.DiskWriteMBps, snapshot.DiskReadMBps)
+	}
+	for _, dir := range dirs {
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("parallelDiskBenchmark() left %d files in %s", len(entries), dir)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// execFailures counts the -cpu-exec runs of all threads that exited non-zero
+// or could not be started
+var execFailures atomic.Int64
+
+// execCommand splits -cpu-exec into the program and its arguments at
+// spaces outside single or double quotes. There is no shell, so pipes and
+// redirects need an explicit sh -c.
+func execCommand(config Config) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	quote := rune(0)
+	for _, c := range config.cpuExec {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// newExecIteration runs the -cpu-exec command once per iteration, discarding
+// its output. Each run is one operation, so the rate is runs per second.
+func newExecIteration(threadID int, config Config) func() int {
+	// main has checked the command already
+	args, _ := execCommand(config)
+	return func() int {
+		if err := exec.Command(args[0], args[1:]...).Run(); err != nil {
+			execFailures.Add(1)
+		}
+		return 1
+	}
+}
+
+// formatExecRate adds the average wall-clock time of a run. The threads run
+// concurrently, so a run takes as many seconds as threads per run/sec.
+func formatExecRate(runsPerSec float64, config Config) string {
+	average := time.Duration(0)
+	if runsPerSec > 0 {
+		average = time.Duration(float64(config.cpuThreads) / runsPerSec * float64(time.Second))
+	}
+	return fmt.Sprintf("%.2f runs/sec, avg %v, %d failed", runsPerSec, average.Round(time.Microsecond), execFailures.Load())
+}
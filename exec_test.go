@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecIteration(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true is not available")
+	}
+
+	before := execFailures.Load()
+	run := newExecIteration(0, Config{cpuExec: "true"})
+	for i := 0; i < 3; i++ {
+		if ops := run(); ops != 1 {
+			t.Errorf("exec iteration of true = %d ops, expected 1", ops)
+		}
+	}
+	if failures := execFailures.Load() - before; failures != 0 {
+		t.Errorf("true counted %d failures, expected 0", failures)
+	}
+
+	// Non-zero exits and missing programs count as failed runs
+	newExecIteration(0, Config{cpuExec: "false"})()
+	newExecIteration(0, Config{cpuExec: "perf-test-no-such-command --flag"})()
+	if failures := execFailures.Load() - before; failures != 2 {
+		t.Errorf("false and a missing command counted %d failures, expected 2", failures)
+	}
+}
+
+func TestStartCPUThreadsExec(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true is not available")
+	}
+
+	config := Config{cpuExec: "true", cpuWorkload: "exec", cpuThreads: 2, reportInterval: 3600, reportBackoff: 1}
+	cpuStats := newCPUStats(time.Hour)
+	metrics := &Metrics{}
+	stopChan := make(chan struct{})
+	var wg sync.WaitGroup
+	startCPUThreads(stopChan, config, cpuStats, metrics, &wg)
+	time.Sleep(200 * time.Millisecond)
+	close(stopChan)
+	wg.Wait()
+
+	snapshot := metrics.Snapshot()
+	if snapshot.CPUOpsPerSec <= 0 || snapshot.CPUPrimesPerSec != 0 {
+		t.Errorf("exec run published %+v, expected runs/sec as ops and no primes", snapshot)
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"true", []string{"true"}},
+		{"  gzip  -kf\tdata.bin ", []string{"gzip", "-kf", "data.bin"}},
+		{`sh -c 'seq 1000 | sort -r > /dev/null'`, []string{"sh", "-c", "seq 1000 | sort -r > /dev/null"}},
+		{`grep "a b"c ''`, []string{"grep", "a bc", ""}},
+		{"   ", nil},
+	}
+	for _, test := range tests {
+		args, err := execCommand(Config{cpuExec: test.command})
+		if err != nil || !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("execCommand(%q) = %q, %v; expected %q", test.command, args, err, test.expected)
+		}
+	}
+
+	if _, err := execCommand(Config{cpuExec: `sh -c 'exit 1`}); err == nil {
+		t.Error("execCommand() with an unterminated quote expected error")
+	}
+}
+
+func TestFormatExecRate(t *testing.T) {
+	// 4 threads at 8 runs/sec take half a second per run
+	rate := formatExecRate(8, Config{cpuThreads: 4})
+	if !strings.HasPrefix(rate, "8.00 runs/sec, avg 500ms, ") {
+		t.Errorf("formatExecRate(8) = %q, expected 8 runs/sec averaging 500ms", rate)
+	}
+	if rate := formatExecRate(0, Config{cpuThreads: 4}); !strings.HasPrefix(rate, "0.00 runs/sec, avg 0s, ") {
+		t.Errorf("formatExecRate(0) = %q, expected no average", rate)
+	}
+}
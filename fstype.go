@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Linux filesystem magic numbers, as in statfs(2) f_type
+var fsMagicNames = map[uint32]string{
+	0x01021994: "tmpfs",
+	0x858458f6: "ramfs",
+	0x958458f6: "hugetlbfs",
+	0x0000ef53: "ext4",
+	0x58465342: "xfs",
+	0x9123683e: "btrfs",
+	0x2fc12fc1: "zfs",
+	0xf2f52010: "f2fs",
+	0x794c7630: "overlayfs",
+	0x00006969: "nfs",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x00004d44: "vfat",
+	0x2011bab0: "exfat",
+	0x5346544e: "ntfs",
+	0x73717368: "squashfs",
+}
+
+// fsMagicName names a filesystem magic number, or prints it in hex if unknown
+func fsMagicName(magic uint32) string {
+	if name, ok := fsMagicNames[magic]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", magic)
+}
+
+// ramBackedFS reports whether files on the filesystem live in memory
+func ramBackedFS(fsType string) bool {
+	return fsType == "tmpfs" || fsType == "ramfs"
+}
+
+// diskPathName is the directory a -disk-path entry writes to, with the empty
+// path standing for the system temp directory
+func diskPathName(path string) string {
+	if path == "" {
+		return os.TempDir()
+	}
+	return path
+}
+
+// detectFSType returns the filesystem type of path, or an empty string if
+// it cannot be determined on this platform
+func detectFSType(path string) string {
+	fsType, err := statfsType(diskPathName(path))
+	if err != nil {
+		return ""
+	}
+	return fsType
+}
+
+// checkDiskFilesystems detects the filesystem of every disk path and warns
+// about RAM-backed ones, whose "disk" numbers are really memory numbers
+func checkDiskFilesystems(config Config) map[string]string {
+	filesystems := make(map[string]string)
+	for _, path := range diskPaths(config) {
+		fsType := detectFSType(path)
+		if fsType == "" {
+			continue
+		}
+		filesystems[diskPathName(path)] = fsType
+		if ramBackedFS(fsType) {
+			fmt.Printf("WARNING: Disk: %s is on %s, the disk test measures memory, not a disk\n", diskPathName(path), fsType)
+		} else if config.full {
+			fmt.Printf("Disk: %s is on %s\n", diskPathName(path), fsType)
+		}
+	}
+	return filesystems
+}
+
+// printDiskFilesystems lists the filesystem of every disk path in the
+// summary, repeating the warning for RAM-backed ones
+func printDiskFilesystems(filesystems map[string]string) {
+	paths := make([]string, 0, len(filesystems))
+	for path := range filesystems {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("Disk: %s filesystem %s\n", path, filesystems[path])
+		if ramBackedFS(filesystems[path]) {
+			fmt.Printf("WARNING: Disk: %s is RAM-backed, its disk results measure memory\n", path)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "syscall"
+
+// macOS reports the filesystem name itself instead of a magic number
+func statfsType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name), nil
+}
@@ -0,0 +1,12 @@
+package main
+
+import "syscall"
+
+func statfsType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+	// f_type is a signed word on 32-bit platforms, the magic numbers fit in 32 bits
+	return fsMagicName(uint32(stat.Type)), nil
+}
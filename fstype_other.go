@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func statfsType(path string) (string, error) {
+	return "", errors.New("filesystem type detection is not supported on this OS")
+}
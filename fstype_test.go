@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestFSMagicName(t *testing.T) {
+	tests := []struct {
+		magic    uint32
+		expected string
+	}{
+		{0x01021994, "tmpfs"},
+		{0x858458f6, "ramfs"},
+		{0xef53, "ext4"},
+		{0x58465342, "xfs"},
+		{0x9123683e, "btrfs"},
+		{0x12345678, "0x12345678"},
+	}
+
+	for _, test := range tests {
+		if name := fsMagicName(test.magic); name != test.expected {
+			t.Errorf("fsMagicName(0x%x) = %q, expected %q", test.magic, name, test.expected)
+		}
+	}
+}
+
+func TestRAMBackedFS(t *testing.T) {
+	tests := []struct {
+		fsType   string
+		expected bool
+	}{
+		{"tmpfs", true},
+		{"ramfs", true},
+		{"ext4", false},
+		{"apfs", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if ram := ramBackedFS(test.fsType); ram != test.expected {
+			t.Errorf("ramBackedFS(%q) = %v, expected %v", test.fsType, ram, test.expected)
+		}
+	}
+}
+
+func TestDetectFSType(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("filesystem type detection is only supported on Linux and macOS")
+	}
+	if fsType := detectFSType(t.TempDir()); fsType == "" {
+		t.Error("detectFSType() of the test directory returned no type")
+	}
+	if fsType := detectFSType("/nonexistent/perf-test"); fsType != "" {
+		t.Errorf("detectFSType() of a missing path = %q, expected none", fsType)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Width in characters of the longest histogram bar
+const histogramWidth = 40
+
+// histogram renders samples as an ASCII histogram of buckets equally wide
+// bars between the smallest and the largest sample, one line per bucket
+// with its range, bar and count. Equal samples share a single bucket.
+func histogram(samples []float64, buckets int) string {
+	if len(samples) == 0 || buckets < 1 {
+		return ""
+	}
+
+	low, high := samples[0], samples[0]
+	for _, sample := range samples {
+		low = math.Min(low, sample)
+		high = math.Max(high, sample)
+	}
+	if low == high {
+		buckets = 1
+	}
+	width := (high - low) / float64(buckets)
+
+	counts := make([]int, buckets)
+	maxCount := 0
+	for _, sample := range samples {
+		bucket := buckets - 1
+		if width > 0 && sample < high {
+			bucket = int((sample - low) / width)
+		}
+		counts[bucket]++
+		if counts[bucket] > maxCount {
+			maxCount = counts[bucket]
+		}
+	}
+
+	// Whole numbers unless the buckets are too narrow to tell apart
+	formatBound := formatWithCommas
+	if width > 0 && width < 10 {
+		formatBound = func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) }
+	}
+	lower := make([]string, buckets)
+	upper := make([]string, buckets)
+	labelWidth := 0
+	for i := range counts {
+		lower[i] = formatBound(low + float64(i)*width)
+		upper[i] = formatBound(low + float64(i+1)*width)
+		if len(lower[i]) > labelWidth {
+			labelWidth = len(lower[i])
+		}
+		if len(upper[i]) > labelWidth {
+			labelWidth = len(upper[i])
+		}
+	}
+
+	var result strings.Builder
+	for i, count := range counts {
+		bar := strings.Repeat("#", count*histogramWidth/maxCount)
+		fmt.Fprintf(&result, "%*s - %*s | %-*s %d\n", labelWidth, lower[i], labelWidth, upper[i], histogramWidth, bar, count)
+	}
+	return result.String()
+}
+
+// printHistograms prints the -histogram of every rate sampled during the run
+func printHistograms(config Config, cpuStats *CPUStats, diskStats *DiskStats) {
+	if rates := cpuStats.IntervalRates(); len(rates) > 0 {
+		unit := "primes/sec"
+		if config.cpuWorkload != "prime" {
+			unit = config.cpuWorkload + " ops/sec"
+		}
+		fmt.Printf("CPU: %s per report interval, %d samples\n%s", unit, len(rates), histogram(rates, config.histBuckets))
+	}
+	if rates := diskStats.WriteRates(); len(rates) > 0 {
+		unit := "MiB/s"
+		if config.units == "decimal" {
+			unit = "MB/s"
+			for i := range rates {
+				rates[i] *= 1024 * 1024 / 1e6
+			}
+		}
+		fmt.Printf("Disk: write %s per iteration, %d samples\n%s", unit, len(rates), histogram(rates, config.histBuckets))
+	}
+}
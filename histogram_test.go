@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	// Bimodal like throttling on and off: 4 samples near 100, 6 near 200
+	samples := []float64{100, 105, 110, 100, 200, 195, 190, 200, 200, 200}
+	bar := func(n int) string { return strings.Repeat("#", n) + strings.Repeat(" ", histogramWidth-n) }
+	expected := "100 - 125 | " + bar(26) + " 4\n" +
+		"125 - 150 | " + bar(0) + " 0\n" +
+		"150 - 175 | " + bar(0) + " 0\n" +
+		"175 - 200 | " + bar(40) + " 6\n"
+	if result := histogram(samples, 4); result != expected {
+		t.Errorf("histogram() =\n%s\nexpected\n%s", result, expected)
+	}
+
+	// Every sample lands in exactly one bucket
+	uniform := make([]float64, 1000)
+	for i := range uniform {
+		uniform[i] = float64(i)
+	}
+	total := 0
+	for _, line := range strings.Split(strings.TrimSuffix(histogram(uniform, 7), "\n"), "\n") {
+		fields := strings.Fields(line)
+		count, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			t.Fatalf("histogram() line %q does not end in a count", line)
+		}
+		total += count
+	}
+	if total != 1000 {
+		t.Errorf("histogram() of 1000 samples counts %d", total)
+	}
+}
+
+func TestHistogramEdgeCases(t *testing.T) {
+	if result := histogram(nil, 10); result != "" {
+		t.Errorf("histogram() without samples = %q, expected nothing", result)
+	}
+	if result := histogram([]float64{1, 2}, 0); result != "" {
+		t.Errorf("histogram() without buckets = %q, expected nothing", result)
+	}
+
+	// Equal samples have no range to split
+	result := histogram([]float64{3, 3, 3}, 10)
+	if expected := "3 - 3 | " + strings.Repeat("#", histogramWidth) + " 3\n"; result != expected {
+		t.Errorf("histogram() of equal samples = %q, expected %q", result, expected)
+	}
+
+	// Narrow buckets keep two decimals
+	if result := histogram([]float64{1.5, 2.5}, 2); !strings.HasPrefix(result, "1.50 - 2.00 |") {
+		t.Errorf("histogram() of narrow buckets = %q, expected decimal bounds", result)
+	}
+}
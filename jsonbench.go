@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// jsonOrder is the payload of the json workload, shaped like a typical API
+// response: nested objects, an array of records, strings, numbers and flags
+type jsonOrder struct {
+	ID        int64        `json:"id"`
+	CreatedAt string       `json:"created_at"`
+	Customer  jsonCustomer `json:"customer"`
+	Items     []jsonItem   `json:"items"`
+	Paid      bool         `json:"paid"`
+	Notes     string       `json:"notes,omitempty"`
+}
+
+type jsonCustomer struct {
+	Name    string      `json:"name"`
+	Email   string      `json:"email"`
+	Address jsonAddress `json:"address"`
+}
+
+type jsonAddress struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type jsonItem struct {
+	SKU      string            `json:"sku"`
+	Name     string            `json:"name"`
+	Quantity int               `json:"quantity"`
+	Price    float64           `json:"price"`
+	Tags     []string          `json:"tags"`
+	Attrs    map[string]string `json:"attributes"`
+}
+
+// jsonPayloadBytes is the encoded size of the payload, the same for every
+// thread, for the round trips/sec in the report
+var jsonPayloadBytes atomic.Int64
+
+func jsonPayloadItem(i int) jsonItem {
+	return jsonItem{
+		SKU:      fmt.Sprintf("SKU-%06d", i),
+		Name:     fmt.Sprintf("Stainless steel water bottle, %d ml", 250+i%4*250),
+		Quantity: 1 + i%5,
+		Price:    float64(1999+i*37%5000) / 100,
+		Tags:     []string{"outdoor", "kitchen", fmt.Sprintf("batch-%d", i%16)},
+		Attrs:    map[string]string{"color": []string{"red", "green", "blue"}[i%3], "warehouse": fmt.Sprintf("WH-%d", i%7)},
+	}
+}
+
+// jsonPayload builds an order with as many items as make its encoding about
+// size bytes, but at least one
+func jsonPayload(size int64) jsonOrder {
+	order := jsonOrder{
+		ID:        4711,
+		CreatedAt: "2026-10-16T10:00:00Z",
+		Customer: jsonCustomer{
+			Name:    "Alice Example",
+			Email:   "alice@example.com",
+			Address: jsonAddress{Street: "1 Main Street", City: "Springfield", Country: "US"},
+		},
+		Paid:  true,
+		Notes: "Leave at the door \"if nobody answers\"",
+		Items: []jsonItem{jsonPayloadItem(0)},
+	}
+	for {
+		data, err := json.Marshal(order)
+		if err != nil || int64(len(data)) >= size {
+			return order
+		}
+		order.Items = append(order.Items, jsonPayloadItem(len(order.Items)))
+	}
+}
+
+// jsonRoundTrip encodes order and decodes it into a fresh value, returning
+// the decoded order and the encoded size
+func jsonRoundTrip(order jsonOrder) (jsonOrder, int, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return jsonOrder{}, 0, err
+	}
+	var decoded jsonOrder
+	err = json.Unmarshal(data, &decoded)
+	return decoded, len(data), err
+}
+
+// newJSONIteration marshals and unmarshals a payload of -json-size per
+// iteration. Each byte encoded and decoded counts as an operation, so the
+// rate is the bandwidth processed.
+func newJSONIteration(threadID int, config Config) func() int {
+	order := jsonPayload(config.jsonSize)
+	return func() int {
+		_, size, err := jsonRoundTrip(order)
+		if err != nil {
+			// The payload types always encode, so this is a bug
+			panic(err)
+		}
+		jsonPayloadBytes.Store(int64(size))
+		return 2 * size
+	}
+}
+
+func formatJSONRate(bytesPerSec float64, config Config) string {
+	roundTrips := 0.0
+	if size := jsonPayloadBytes.Load(); size > 0 {
+		roundTrips = bytesPerSec / float64(2*size)
+	}
+	return fmt.Sprintf("%s processed, %s round trips/sec", formatBandwidth(bytesPerSec, config), formatWithCommas(roundTrips))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	order := jsonPayload(8 * 1024)
+	decoded, size, err := jsonRoundTrip(order)
+	if err != nil {
+		t.Fatalf("jsonRoundTrip() error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, order) {
+		t.Errorf("Decoded order differs from the original:\n%+v\n%+v", decoded, order)
+	}
+	if size < 8*1024 || size > 9*1024 {
+		t.Errorf("Encoded payload is %d bytes, expected about 8 KiB", size)
+	}
+}
+
+func TestJSONPayloadSmall(t *testing.T) {
+	// Even a tiny size keeps one item, so every iteration has work to do
+	order := jsonPayload(1)
+	if len(order.Items) != 1 {
+		t.Errorf("jsonPayload(1) has %d items, expected 1", len(order.Items))
+	}
+	if data, _ := json.Marshal(order); len(data) == 0 {
+		t.Error("jsonPayload(1) encodes to nothing")
+	}
+}
+
+func TestJSONIteration(t *testing.T) {
+	config := Config{jsonSize: 4096}
+	ops := newJSONIteration(0, config)()
+	if ops != 2*int(jsonPayloadBytes.Load()) || ops < 2*4096 {
+		t.Errorf("JSON iteration reported %d bytes, expected twice the %d byte payload", ops, jsonPayloadBytes.Load())
+	}
+
+	jsonPayloadBytes.Store(1024)
+	if rate := formatJSONRate(2*1024*1024, config); rate != "2.00 MiB/s processed, 1,024 round trips/sec" {
+		t.Errorf("formatJSONRate(2 MiB) = %q, expected 1,024 round trips/sec", rate)
+	}
+}
@@ -1,37 +1,407 @@
 package main
 
 import (
-	"crypto/rand"
+	"errors"
 	"flag"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 )
 
+// Assumed available memory when the OS cannot be queried
+const fallbackMemory = 8 * 1024 * 1024 * 1024 // 8GB
+
 type Config struct {
-	primeRange     int
-	memoryPercent  float64
-	chunkSizeMB    int
-	reportInterval int
-	cpuThreads     int
-	full           bool
-	disableCPU     bool
-	disableDisk    bool
-	diskPath       string
+	primeRange       int
+	memoryPercent    float64
+	chunkSizeMB      int
+	reportInterval   int
+	cpuThreads       int
+	full             bool
+	disableCPU       bool
+	disableDisk      bool
+	diskPath         string
+	duration         time.Duration
+	sequential       bool
+	openMetricsFile  string
+	diskFileSize     int64
+	diskPreallocate  bool
+	reportBackoff    float64
+	reportBackoffMax time.Duration
+	selfTest         bool
+	format           string
+	diskBlockSize    int64
+	diskRWMix        int
+	hostLabel        string
+	units            string
+	cpuWorkload      string
+	diskTotalLimit   int64
+	cpuRangeSweep    []int
+	memVerify        bool
+	diskFsyncEvery   int
+	burnIn           bool
+	quickCPU         bool
+	branchySorted    bool
+	tags             map[string]string
+	latencySamples   int
+	offHeap          bool
+	cpuThreadsPhys   bool
+	resumeFile       string
+	diskTarget       string
+	tui              bool
+	outputFile       string
+	memcpyBuffer     int64
+	cpuRangeStagger  int
+	cooldown         time.Duration
+	diskRotateFiles  int
+	diskWorkers      int
+	diskLatencyOnly  bool
+	seed             int64
+	diskMode         string
+	pprofDir         string
+	pprofHTTP        string
+	regexCorpusSize  int64
+	shutdownTimeout  time.Duration
+	memScrub         time.Duration
+	warmupIters      int
+	cpuExec          string
+	histogram        bool
+	histBuckets      int
+	diskPattern      string
+	diskComparePat   bool
+	runtimeMetrics   bool
+	diskReadBuffer   int64
+	postResults      string
+	postRequired     bool
+	sortSize         int
+	memoryBasis      string
+	staggerStart     time.Duration
+	timestampTZ      string
+	timestamps       bool
+	diskSyncLatency  bool
+	affinityStride   int
+	outputMaxSize    int64
+	outputMaxFiles   int
+	jsonSize         int64
+	minRuntime       time.Duration
+	strict           bool
+	diskThinkTime    time.Duration
+	crcPoly          string
+	allocateUpfront  bool
+	table            bool
+	primeStart       int
+	diskOSync        bool
+	checkpointEvery  time.Duration
+	checkpointFile   string
+	collatzRange     int
+	mandelbrotSize   int
+	diskBSSweep      []int64
+	diskDirectVerify bool
+	reportTemplate   string
+	diskSparse       bool
 }
 
+// CPUStats aggregates primes across threads, or the operations of an
+// opsWorkload in their place
 type CPUStats struct {
-	mu               sync.RWMutex
-	totalPrimesFound int
-	totalTime        time.Duration
-	lastReport       time.Time
+	// Updated by every thread each iteration, so kept lock-free
+	totalPrimesFound atomic.Int64
+	totalTimeNanos   atomic.Int64
+
+	// Primes found before the last Reset, so the exported counter keeps
+	// growing across resets. Guarded by mu together with the move from
+	// totalPrimesFound.
+	primesBeforeReset int64
+
+	// Delay of reportCPU's first report
+	reportInterval time.Duration
+
+	mu     sync.Mutex
+	linked []*CPUStats
+	// Per-thread totals, handed out by Thread
+	threads map[int]*ThreadStats
+
+	// Aggregate rate of every report interval for -histogram and -table,
+	// and the totals at the end of the previous interval
+	intervalRates []float64
+	lastPrimes    int64
+	lastNanos     int64
+}
+
+func newCPUStats(reportInterval time.Duration) *CPUStats {
+	return &CPUStats{reportInterval: reportInterval}
+}
+
+func (s *CPUStats) Add(primes int, duration time.Duration) {
+	s.totalPrimesFound.Add(int64(primes))
+	s.totalTimeNanos.Add(int64(duration))
+}
+
+// Link makes Reset reset other as well, for stats kept per workload
+func (s *CPUStats) Link(other *CPUStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linked = append(s.linked, other)
+}
+
+// Reset zeroes the totals, so the aggregate rate starts over
+func (s *CPUStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.primesBeforeReset += s.totalPrimesFound.Swap(0)
+	s.totalTimeNanos.Store(0)
+	s.intervalRates = nil
+	s.lastPrimes, s.lastNanos = 0, 0
+	for _, thread := range s.threads {
+		thread.iterations.Store(0)
+		thread.count.Store(0)
+		thread.nanos.Store(0)
+	}
+	for _, other := range s.linked {
+		other.Reset()
+	}
+}
+
+// LifetimePrimes returns the primes found over the whole run, including
+// those before a Reset. Unlike the totals it never goes down.
+func (s *CPUStats) LifetimePrimes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.primesBeforeReset + s.totalPrimesFound.Load()
+}
+
+// sampleInterval records the aggregate rate since the previous call, scaled
+// to all threads like TotalPrimesPerSec
+func (s *CPUStats) sampleInterval(threads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	primes, nanos := s.totalPrimesFound.Load(), s.totalTimeNanos.Load()
+	if nanos > s.lastNanos {
+		rate := perSecond(float64(primes-s.lastPrimes), time.Duration(nanos-s.lastNanos)) * float64(threads)
+		s.intervalRates = append(s.intervalRates, rate)
+	}
+	s.lastPrimes, s.lastNanos = primes, nanos
+}
+
+func (s *CPUStats) IntervalRates() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]float64(nil), s.intervalRates...)
+}
+
+// ThreadStats is one thread's share of CPUStats. Only that thread adds to
+// it, so it is kept lock-free like the totals.
+type ThreadStats struct {
+	iterations atomic.Int64
+	count      atomic.Int64
+	nanos      atomic.Int64
+}
+
+// Thread returns the stats of a thread, which it fetches once before its
+// first iteration
+func (s *CPUStats) Thread(threadID int) *ThreadStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.threads == nil {
+		s.threads = make(map[int]*ThreadStats)
+	}
+	if s.threads[threadID] == nil {
+		s.threads[threadID] = &ThreadStats{}
+	}
+	return s.threads[threadID]
+}
+
+// ThreadSummaries returns every thread's iterations and average rate,
+// ordered by thread ID
+func (s *CPUStats) ThreadSummaries() []ThreadSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := make([]ThreadSummary, 0, len(s.threads))
+	for threadID, thread := range s.threads {
+		summary := ThreadSummary{Thread: threadID, Iterations: thread.iterations.Load()}
+		summary.PerSec = perSecond(float64(thread.count.Load()), time.Duration(thread.nanos.Load()))
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Thread < summaries[j].Thread })
+	return summaries
+}
+
+// TotalPrimesPerSec multiplies the per-thread average rate by the thread count
+func (s *CPUStats) TotalPrimesPerSec(threads int) float64 {
+	return perSecond(float64(s.totalPrimesFound.Load()), time.Duration(s.totalTimeNanos.Load())) * float64(threads)
+}
+
+// updateCPUMetrics publishes the aggregate rate of the prime or ops workload
+// and returns it
+func updateCPUMetrics(config Config, cpuStats *CPUStats, metrics *Metrics) float64 {
+	perSec := cpuStats.TotalPrimesPerSec(config.cpuThreads)
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		if config.cpuWorkload == "prime" {
+			snapshot.CPUPrimesPerSec = perSec
+		} else {
+			snapshot.CPUOpsPerSec = perSec
+		}
+		if config.cpuWorkload == "exec" {
+			snapshot.CPUExecFailures = execFailures.Load()
+		}
+	})
+	return perSec
+}
+
+// reportCPU publishes the aggregate CPU rate at every report interval, backing
+// off like the other reports, until stopChan closes. Being the only reporter
+// keeps the reports evenly spaced while the threads just accumulate.
+func reportCPU(stopChan <-chan struct{}, config Config, cpuStats *CPUStats, metrics *Metrics) {
+	interval := cpuStats.reportInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-timer.C:
+		}
+
+		interval = nextReportInterval(interval, config)
+		timer.Reset(interval)
+		// Nothing to report before the first iteration completed
+		if cpuStats.totalTimeNanos.Load() == 0 {
+			continue
+		}
+
+		perSec := updateCPUMetrics(config, cpuStats, metrics)
+		if sampleRates(config) {
+			cpuStats.sampleInterval(config.cpuThreads)
+		}
+		if !config.full && benchmarkReports(config) {
+			if config.cpuWorkload == "prime" {
+				fmt.Printf("CPU: %s total primes/sec%s\n", formatWithCommas(perSec), progressSuffix(metrics))
+			} else {
+				fmt.Printf("CPU: %s total %s%s\n", config.cpuWorkload, opsWorkloads[config.cpuWorkload].rate(perSec, config), progressSuffix(metrics))
+			}
+		}
+	}
+}
+
+type DiskStats struct {
+	bytesWritten atomic.Int64
+	bytesRead    atomic.Int64
+	iterations   atomic.Int64
+
+	// Blocks read back and checked by -disk-direct-io-verify, and how many
+	// of them did not match. Reset leaves the mismatches alone, since
+	// corruption found before a reset must still show in the summary.
+	verifiedBlocks   atomic.Int64
+	verifyMismatches atomic.Int64
+
+	// Logical and allocated size of the -disk-sparse file after the last
+	// pass, the allocated size being -1 where the platform cannot tell
+	sparseLogical   atomic.Int64
+	sparseAllocated atomic.Int64
+
+	// Bytes written and read before the last Reset. Written bytes still
+	// count toward -disk-total-limit, and both keep the exported counters
+	// growing. Reset moves the totals here under resetMu, so a reader
+	// holding it never sees a counter go down.
+	resetMu              sync.Mutex
+	bytesBeforeReset     atomic.Int64
+	bytesReadBeforeReset atomic.Int64
+	resets               atomic.Int64
+
+	// Write throughput of every iteration for -histogram and -table
+	mu         sync.Mutex
+	writeRates []float64
+}
+
+// Reset zeroes the totals. The running disk benchmark notices it through
+// resetSince and starts its averages over. The lifetime totals are kept.
+func (s *DiskStats) Reset() {
+	s.resetMu.Lock()
+	s.bytesBeforeReset.Add(s.bytesWritten.Swap(0))
+	s.bytesReadBeforeReset.Add(s.bytesRead.Swap(0))
+	s.resetMu.Unlock()
+	s.iterations.Store(0)
+	s.verifiedBlocks.Store(0)
+	s.mu.Lock()
+	s.writeRates = nil
+	s.mu.Unlock()
+	s.resets.Add(1)
+}
+
+// LifetimeBytes returns the bytes written and read over the whole run,
+// including those before a Reset. Unlike the totals they never go down.
+func (s *DiskStats) LifetimeBytes() (written, read int64) {
+	s.resetMu.Lock()
+	defer s.resetMu.Unlock()
+	return s.bytesBeforeReset.Load() + s.bytesWritten.Load(), s.bytesReadBeforeReset.Load() + s.bytesRead.Load()
+}
+
+func (s *DiskStats) addWriteRate(writeMBps float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeRates = append(s.writeRates, writeMBps)
+}
+
+func (s *DiskStats) WriteRates() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]float64(nil), s.writeRates...)
+}
+
+// resetSince reports whether Reset was called since the caller last saw
+// the reset count in *seen, and updates it
+func (s *DiskStats) resetSince(seen *int64) bool {
+	resets := s.resets.Load()
+	if resets == *seen {
+		return false
+	}
+	*seen = resets
+	return true
+}
+
+// resetOnSignal resets the CPU and disk stats on every SIGUSR2 until
+// stopChan closes, so a run can be measured again after a change to the
+// system without restarting it
+func resetOnSignal(stopChan <-chan struct{}, cpuStats *CPUStats, diskStats *DiskStats) {
+	reset := make(chan os.Signal, 1)
+	notifyReset(reset)
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-reset:
+			cpuStats.Reset()
+			diskStats.Reset()
+			fmt.Println("Stats reset")
+		}
+	}
+}
+
+// remainingBudget returns how many more bytes may be written under
+// -disk-total-limit, or -1 when there is no limit.
+func (s *DiskStats) remainingBudget(config Config) int64 {
+	if config.diskTotalLimit == 0 {
+		return -1
+	}
+	remaining := config.diskTotalLimit - s.bytesWritten.Load() - s.bytesBeforeReset.Load()
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
 }
 
 func formatWithCommas(n float64) string {
@@ -50,42 +420,370 @@ func formatWithCommas(n float64) string {
 	return result
 }
 
+// registerFlags binds every benchmark option to config. -config and
+// -dump-config are registered by main, since they are not options themselves.
+func registerFlags(flags *flag.FlagSet, config *Config) {
+	flags.StringVar(&config.cpuExec, "cpu-exec", "", "Instead of a CPU workload, run this command repeatedly and report runs/sec, e.g. \"gzip -kf data.bin\"")
+	flags.IntVar(&config.primeRange, "prime-range", 10000000, "Range for prime number testing (default: 10M)")
+	flags.IntVar(&config.affinityStride, "affinity-stride", 0, "Pin CPU thread i to logical core i times this modulo the core count (0 = no pinning)")
+	flags.IntVar(&config.primeStart, "cpu-prime-start", 0, "First number the prime workload tests, so iterations cover -cpu-prime-start up to -prime-range")
+	flags.IntVar(&config.cpuRangeStagger, "cpu-range-stagger", 0, "Extend each thread's prime range by thread ID times this, so threads work on different data (0 = same range)")
+	flags.Float64Var(&config.memoryPercent, "memory-percent", 0.9, "Percentage of memory to allocate (0.1-0.95)")
+	flags.StringVar(&config.memoryBasis, "memory-basis", "available", "Memory -memory-percent applies to: available or total")
+	flags.IntVar(&config.chunkSizeMB, "chunk-size", 100, "Memory chunk size in MiB")
+	flags.BoolVar(&config.offHeap, "offheap", false, "Allocate memory chunks with mmap outside the Go heap (Linux only)")
+	flags.DurationVar(&config.memScrub, "mem-scrub", 0, "Instead of the disk test, rescan the allocation for bit flips at this interval (0 = off)")
+	flags.BoolVar(&config.allocateUpfront, "allocate-upfront", false, "Allocate the whole memory target before starting any benchmark and exit with an error if it cannot be obtained")
+	flags.BoolVar(&config.memVerify, "mem-verify", false, "Read back the allocation after filling it and count pattern mismatches")
+	flags.IntVar(&config.reportInterval, "report-interval", 5, "Seconds between benchmark reports (at least 1)")
+	flags.Var(cpuThreadsValue{config}, "cpu-threads", "Number of CPU threads (0 = auto: cores-1, auto-physical = physical cores-1)")
+	flags.StringVar(&config.cpuWorkload, "cpu-workload", "prime", "CPU workload: "+strings.Join(cpuWorkloads, ", ")+"; several separated by commas rotate per iteration")
+	config.memcpyBuffer = 64 * 1024 * 1024
+	flags.Var((*sizeValue)(&config.memcpyBuffer), "memcpy-buffer", "Size of each thread's source and destination buffer for the memcpy workload")
+	config.regexCorpusSize = 1024 * 1024
+	flags.Var((*sizeValue)(&config.regexCorpusSize), "regex-corpus-size", "Size of the log corpus each thread scans in the regex workload")
+	config.jsonSize = 16 * 1024
+	flags.Var((*sizeValue)(&config.jsonSize), "json-size", "Encoded size of the payload each thread marshals and unmarshals in the json workload")
+	flags.StringVar(&config.crcPoly, "crc-poly", "ieee", "CRC-32 polynomial of the crc workload: ieee or castagnoli")
+	flags.IntVar(&config.mandelbrotSize, "mandelbrot-size", 512, "Width and height in pixels of the grid each thread computes per iteration in the mandelbrot workload")
+	flags.IntVar(&config.collatzRange, "collatz-range", 1000000, "Starting numbers, from 1 up to this, whose Collatz sequence each thread follows per iteration in the collatz workload")
+	flags.IntVar(&config.sortSize, "sort-size", 1000000, "Number of integers each thread shuffles and sorts per iteration in the sort workload")
+	flags.Int64Var(&config.seed, "seed", 0, "Seed for the random data of the pi, branchy and sort workloads, for reproducible runs (0 = random)")
+	flags.BoolVar(&config.branchySorted, "branchy-sorted", false, "Sort the branchy workload's data so its branch becomes predictable")
+	flags.Var((*rangeSweepValue)(&config.cpuRangeSweep), "cpu-range-sweep", "Sweep the prime range as start:end:step and print primes/sec per range")
+	flags.Float64Var(&config.reportBackoff, "report-backoff", 1, "Multiply the report interval by this factor after each report (1 = fixed interval)")
+	flags.DurationVar(&config.reportBackoffMax, "report-backoff-max", 5*time.Minute, "Upper bound for the report interval when backing off")
+	flags.StringVar(&config.hostLabel, "host-label", "", "Host identity attached to structured outputs (default: hostname)")
+	flags.Var((*tagsValue)(&config.tags), "tag", "Metadata key=value attached to structured outputs and the summary, repeatable")
+	flags.StringVar(&config.units, "units", "binary", "Byte units for output: binary (MiB, GiB) or decimal (MB, GB)")
+	flags.StringVar(&config.format, "format", "text", "Summary format printed on shutdown: text, json, or none to print nothing but failures to stderr")
+	flags.StringVar(&config.reportTemplate, "report-template", "", "Go text/template for the interval reports, replacing the default lines, e.g. \"{{.CPUPrimesPerSec}} {{.DiskWriteMBps}}\"")
+	flags.BoolVar(&config.tui, "tui", false, "Show a live dashboard that updates in place (only on a terminal)")
+	flags.BoolVar(&config.full, "full", false, "Show full output with detailed information")
+	flags.BoolVar(&config.disableCPU, "disable-cpu", false, "Disable CPU testing")
+	flags.BoolVar(&config.disableDisk, "disable-disk", false, "Disable disk testing")
+	flags.StringVar(&config.diskPath, "disk-path", "./", "Path for disk benchmark files, a comma-separated list of paths benchmarked in parallel, each optionally with its own file size as path:size, or auto for the fastest writable mount (end a path that contains a colon with another colon)")
+	flags.IntVar(&config.diskWorkers, "disk-workers-per-path", 1, "Concurrent disk workers on each path of -disk-path, each with its own file")
+	flags.StringVar(&config.diskTarget, "disk-target", "", "Benchmark this exact file or block device instead of a temp file in -disk-path; it is not deleted")
+	flags.Var((*sizeValue)(&config.diskFileSize), "disk-file-size", "Bytes written per disk iteration, e.g. 512MB (0 = size of the memory allocation)")
+	flags.Var((*sizeValue)(&config.diskBlockSize), "disk-block-size", "Size of each disk write and mixed I/O operation, e.g. 4K (0 = chunk size)")
+	flags.Var((*sizeValue)(&config.diskReadBuffer), "disk-read-buffer", "Size of each read of the sequential read benchmark, e.g. 64K (0 = chunk size)")
+	flags.StringVar(&config.diskMode, "disk-mode", "rewrite", "Sequential disk pattern: rewrite the file each iteration, or append to a growing log that rolls over at -disk-file-size")
+	flags.IntVar(&config.diskRWMix, "disk-rw-mix", -1, "Random mixed I/O with this percentage of reads, e.g. 70 (-1 = sequential write then read)")
+	flags.IntVar(&config.diskFsyncEvery, "disk-fsync-interval", 0, "Fsync after every N block writes, like a journal or WAL (0 = once per iteration)")
+	flags.DurationVar(&config.diskThinkTime, "disk-think-time", 0, "Pause this long after every block write, like an application working between I/Os (0 = no pause)")
+	flags.Var((*blockSizeSweepValue)(&config.diskBSSweep), "disk-bs-sweep", "Sweep the disk block size as start:end:step, where a step of xN multiplies, and print throughput and IOPS per size")
+	flags.BoolVar(&config.diskDirectVerify, "disk-direct-io-verify", false, "Write and read the disk test file with O_DIRECT and verify a checksum of every block (Linux only)")
+	flags.BoolVar(&config.diskSparse, "disk-sparse", false, "Write blocks at scattered offsets of a sparse file and report its logical and allocated size")
+	flags.BoolVar(&config.diskOSync, "disk-o-sync-every-write", false, "Open the disk test files with O_DSYNC, so every block write waits until it is durable")
+	flags.BoolVar(&config.diskSyncLatency, "disk-sync-latency", false, "Time each fsync separately and leave it out of the write throughput")
+	flags.IntVar(&config.latencySamples, "latency-samples", 10000, "Latency samples kept for disk write percentiles")
+	flags.Var((*sizeValue)(&config.diskTotalLimit), "disk-total-limit", "Stop the disk test after writing this many bytes in total, e.g. 500GB (0 = unlimited)")
+	flags.IntVar(&config.diskRotateFiles, "disk-rotate-files", 1, "Cycle the disk iterations round-robin through this many temp files")
+	flags.BoolVar(&config.diskLatencyOnly, "disk-latency-only", false, "Time small synchronous writes with fsync, print their latency percentiles and exit")
+	flags.StringVar(&config.diskPattern, "disk-test-pattern", "random", "Data the disk test writes: "+strings.Join(diskTestPatterns, ", ")+"; zeros may be skipped by thin-provisioned or deduplicating storage")
+	flags.BoolVar(&config.diskComparePat, "disk-compare-patterns", false, "Alternate the disk iterations between all -disk-test-pattern values and report the write throughput of each")
+	flags.BoolVar(&config.diskPreallocate, "disk-preallocate", false, "Preallocate the disk benchmark file and overwrite it in place")
+	flags.BoolVar(&config.burnIn, "burn-in", false, "Hardware qualification: use all cores, 95% memory and -mem-verify, fail on any error")
+	flags.BoolVar(&config.quickCPU, "quick-cpu", false, "Run only the prime benchmark for 10 seconds (or -duration) and print nothing but the total primes/sec")
+	flags.BoolVar(&config.selfTest, "self-test", false, "Briefly check that each subsystem works, print PASS/FAIL and exit")
+	flags.DurationVar(&config.duration, "duration", 0, "Stop after this long, or per phase with -sequential (0 = until interrupted)")
+	flags.DurationVar(&config.minRuntime, "min-runtime", 0, "Warn when -duration is shorter than this, since such runs are dominated by warmup, e.g. 30s (0 = never warn)")
+	flags.BoolVar(&config.strict, "strict", false, "Refuse to run instead of warning when -duration is below -min-runtime")
+	flags.IntVar(&config.warmupIters, "warmup-iterations", 0, "Leave each CPU thread's and the disk test's first N iterations out of the results")
+	flags.DurationVar(&config.staggerStart, "stagger-start", 0, "Start the CPU threads this far apart instead of all at once (0 = all at once)")
+	flags.DurationVar(&config.shutdownTimeout, "shutdown-timeout", 2*time.Second, "How long to wait for the benchmarks to stop after a signal or -duration before exiting anyway")
+	flags.BoolVar(&config.sequential, "sequential", false, "Run CPU, memory and disk one after another instead of concurrently")
+	flags.DurationVar(&config.cooldown, "cooldown", 0, "With -sequential, idle this long between phases and report the idle temperature and frequency")
+	flags.DurationVar(&config.checkpointEvery, "checkpoint-interval", 0, "Save the summary of the run so far as JSON to -checkpoint-file this often, so it survives a crash (0 = off)")
+	flags.StringVar(&config.checkpointFile, "checkpoint-file", "perf-test-checkpoint.json", "File -checkpoint-interval writes to")
+	flags.StringVar(&config.resumeFile, "resume", "", "Load accumulated stats from this file at startup and save them on shutdown")
+	flags.BoolVar(&config.histogram, "histogram", false, "Print histograms of the CPU rate per report interval and the disk write throughput per iteration in the summary")
+	flags.BoolVar(&config.table, "table", false, "End the summary with a table of every subsystem's average, peak and sample count")
+	flags.IntVar(&config.histBuckets, "histogram-buckets", 10, "Number of buckets of the -histogram")
+	flags.StringVar(&config.outputFile, "output-file", "", "Also append all output to this file, reopened on SIGUSR1 for log rotation")
+	flags.Var((*sizeValue)(&config.outputMaxSize), "output-max-size", "Roll -output-file over to file.1, file.2, ... once it would grow past this size (0 = never)")
+	flags.IntVar(&config.outputMaxFiles, "output-max-files", 5, "Rolled output files kept by -output-max-size")
+	flags.BoolVar(&config.timestamps, "timestamps", false, "Prefix every printed line with its time")
+	flags.StringVar(&config.timestampTZ, "timestamp-tz", "utc", "Time zone of timestamps in the output and the JSON summary: utc or local")
+	flags.StringVar(&config.postResults, "post-results", "", "POST the JSON summary to this URL on completion, retrying on errors")
+	flags.BoolVar(&config.postRequired, "post-results-required", false, "Exit non-zero if -post-results fails")
+	flags.StringVar(&config.openMetricsFile, "openmetrics-file", "", "Write metrics in OpenMetrics format to this file every report interval")
+	flags.BoolVar(&config.runtimeMetrics, "runtime-metrics", false, "Add the Go runtime's scheduling latency, GC pauses and heap from runtime/metrics to the summary")
+	flags.StringVar(&config.pprofDir, "pprof", "", "Write cpu.prof and mem.prof of the tool itself to this directory")
+	flags.StringVar(&config.pprofHTTP, "pprof-http", "", "Serve net/http/pprof on this address, e.g. localhost:6060")
+}
+
+// fatalf reports a startup error on stderr, which -format none and
+// redirected output leave alone, and exits with status 1
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
 func main() {
 	var config Config
 
 	// Parse command line arguments
-	flag.IntVar(&config.primeRange, "prime-range", 10000000, "Range for prime number testing (default: 10M)")
-	flag.Float64Var(&config.memoryPercent, "memory-percent", 0.9, "Percentage of memory to allocate (0.1-0.95)")
-	flag.IntVar(&config.chunkSizeMB, "chunk-size", 100, "Memory chunk size in MB")
-	flag.IntVar(&config.reportInterval, "report-interval", 5, "Seconds between benchmark reports")
-	flag.IntVar(&config.cpuThreads, "cpu-threads", 0, "Number of CPU threads (0 = auto: cores-1)")
-	flag.BoolVar(&config.full, "full", false, "Show full output with detailed information")
-	flag.BoolVar(&config.disableCPU, "disable-cpu", false, "Disable CPU testing")
-	flag.BoolVar(&config.disableDisk, "disable-disk", false, "Disable disk testing")
-	flag.StringVar(&config.diskPath, "disk-path", "./", "Path for disk benchmark files")
+	var configFile string
+	var dumpConfig bool
+	var merge bool
+	var mergeRank string
+	registerFlags(flag.CommandLine, &config)
+	flag.StringVar(&configFile, "config", "", "Load flag values from this JSON file, as written by -dump-config; command line flags take precedence")
+	flag.BoolVar(&dumpConfig, "dump-config", false, "Print the effective flag values as JSON for -config and exit")
+	flag.BoolVar(&merge, "merge", false, "Instead of benchmarking, aggregate the JSON summaries given as arguments, files or directories, and exit")
+	flag.StringVar(&mergeRank, "merge-rank", "cpu_primes_per_sec", "Metric by which -merge ranks the hosts")
+	flag.Usage = func() {
+		printUsage(flag.CommandLine.Output(), flag.CommandLine)
+	}
 	flag.Parse()
 
-	// Validate parameters
-	if config.memoryPercent < 0.1 || config.memoryPercent > 0.95 {
-		fmt.Println("Memory percent must be between 0.1 and 0.95")
-		os.Exit(1)
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	if configFile != "" {
+		if err := loadConfigFile(flag.CommandLine, configFile, explicit); err != nil {
+			fatalf("Cannot load -config: %v", err)
+		}
+	}
+	if dumpConfig {
+		if err := writeConfig(os.Stdout, flag.CommandLine); err != nil {
+			fatalf("Cannot dump the config: %v", err)
+		}
+		os.Exit(0)
+	}
+	if merge {
+		if err := runMerge(flag.Args(), mergeRank); err != nil {
+			fatalf("Cannot merge summaries: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if config.burnIn {
+		config = applyBurnIn(config, explicit)
+	}
+
+	if config.quickCPU {
+		config = applyQuickCPU(config, explicit)
+	}
+	// The sweeps only exercise one side
+	if len(config.cpuRangeSweep) > 0 {
+		config.disableDisk = true
+	}
+	if len(config.diskBSSweep) > 0 {
+		config.disableCPU = true
+	}
+
+	if err := validateConfig(config, explicit); err != nil {
+		fatalf("%v", err)
+	}
+
+	if config.hostLabel == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		config.hostLabel = hostname
+	}
+
+	if config.cpuExec != "" {
+		args, err := execCommand(config)
+		if err == nil {
+			_, err = exec.LookPath(args[0])
+		}
+		if err != nil {
+			fatalf("CPU exec command cannot be run: %v", err)
+		}
+		config.cpuWorkload = "exec"
+	}
+
+	if config.diskTarget != "" && !config.disableDisk {
+		blockDevice, err := checkDiskTarget(config.diskTarget)
+		if err != nil {
+			fatalf("Disk target %s is not writable: %v", config.diskTarget, err)
+		}
+		if blockDevice {
+			fmt.Printf("WARNING: Disk target %s is a block device, all data on it will be overwritten\n", config.diskTarget)
+		}
+	}
+
+	var reportTemplate *template.Template
+	if config.reportTemplate != "" {
+		tmpl, err := parseReportTemplate(config.reportTemplate)
+		if err != nil {
+			fatalf("Invalid -report-template: %v", err)
+		}
+		reportTemplate = tmpl
+	}
+
+	if runtimeTooShort(config) {
+		fmt.Printf("WARNING: %s\n", runtimeTooShortMessage(config))
 	}
 
 	cpuCores := runtime.NumCPU()
+	// A multiple of the core count would put every thread on the first core
+	if allowed := len(affinityCPUs()); config.affinityStride > 1 && config.affinityStride >= allowed {
+		fatalf("Affinity stride must be below the %d logical cores", allowed)
+	}
+
+	physicalCores, physicalErr := 0, errors.New("not detected")
+	if config.cpuThreadsPhys || config.full {
+		physicalCores, physicalErr = getPhysicalCores()
+	}
 	if config.cpuThreads == 0 {
-		config.cpuThreads = cpuCores - 1
+		autoCores := cpuCores
+		if config.cpuThreadsPhys {
+			if physicalErr == nil {
+				autoCores = physicalCores
+			} else {
+				fmt.Printf("Cannot detect physical cores, counting logical cores: %v\n", physicalErr)
+			}
+		}
+		config.cpuThreads = autoCores - 1
 		if config.cpuThreads < 1 {
 			config.cpuThreads = 1
 		}
 	}
 
+	// -quick-cpu prints its score to the real stdout
+	scoreOutput := os.Stdout
+
+	// With -format none only the exit code and failures on stderr remain.
+	// An -output-file still receives everything. Errors before an exit go
+	// to stderr so they survive either way.
+	if config.format == "none" {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			fatalf("Cannot open %s: %v", os.DevNull, err)
+		}
+		os.Stdout = devNull
+	}
+
+	// Route the output through the dashboard and the output file
+	useDashboard := config.tui && isTerminal(os.Stdout)
+	var output *Output
+	if useDashboard || config.outputFile != "" || config.timestamps {
+		o, err := startOutput()
+		if err != nil {
+			fatalf("Cannot redirect the output: %v", err)
+		}
+		output = o
+	}
+	if config.timestamps {
+		output.SetTimestamps(config.timestampTZ)
+	}
+	if config.outputFile != "" {
+		output.SetMaxSize(config.outputMaxSize, config.outputMaxFiles)
+		if err := output.OpenFile(config.outputFile); err != nil {
+			output.Close()
+			fatalf("Cannot open -output-file: %v", err)
+		}
+	}
+	closeOutput := func() {
+		if output != nil {
+			output.Close()
+		}
+	}
+	if config.tui && !useDashboard {
+		fmt.Println("Standard output is not a terminal, -tui falls back to normal output")
+	}
+
+	environment := detectEnvironment()
+
 	if config.full {
+		fmt.Printf("Architecture: %s/%s (%d-bit, %s-endian)\n",
+			environment.GOOS, environment.GOARCH, environment.WordSize, environment.Endianness)
+		fmt.Printf("Go version: %s\n", environment.GoVersion)
+		fmt.Printf("Virtualization: %s\n", environment.Hypervisor)
 		fmt.Printf("CPU cores detected: %d\n", cpuCores)
+		if physicalErr == nil {
+			fmt.Printf("Physical cores detected: %d\n", physicalCores)
+		}
+		if runtime.GOOS == "darwin" {
+			if info, err := getDarwinCPUInfo(); err == nil {
+				fmt.Printf("Performance cores: %d, efficiency cores: %d\n", info.PerformanceCores, info.EfficiencyCores)
+			}
+		}
 		fmt.Printf("Using %d threads for CPU benchmarking\n", config.cpuThreads)
-		fmt.Printf("Prime range: %d\n", config.primeRange)
-		fmt.Printf("Memory allocation: %.0f%%\n", config.memoryPercent*100)
-		fmt.Printf("Chunk size: %d MB\n", config.chunkSizeMB)
+		if ratio, over := oversubscription(config.cpuThreads, cpuCores); over && !config.disableCPU {
+			fmt.Printf("WARNING: %d threads on %d logical cores, %.1fx oversubscribed, CPU results include context switching overhead\n",
+				config.cpuThreads, cpuCores, ratio)
+		}
+		if config.cpuExec != "" {
+			fmt.Printf("CPU command: %s\n", config.cpuExec)
+		} else {
+			fmt.Printf("CPU workload: %s\n", config.cpuWorkload)
+			fmt.Printf("Prime range: %s\n", formatPrimeRange(config.primeStart, config.primeRange))
+		}
+		if config.cpuRangeStagger > 0 {
+			fmt.Printf("Prime range stagger: %d per thread\n", config.cpuRangeStagger)
+		}
+		fmt.Printf("Memory allocation: %.0f%% of %s memory\n", config.memoryPercent*100, config.memoryBasis)
+		fmt.Printf("Chunk size: %s\n", formatBytes(chunkBytes(config), config.units))
 		fmt.Printf("Report interval: %d seconds\n", config.reportInterval)
+		if config.reportBackoff > 1 {
+			fmt.Printf("Report backoff: x%.2f up to %v\n", config.reportBackoff, config.reportBackoffMax)
+		}
+	}
+
+	if config.affinityStride > 0 && !config.disableCPU {
+		fmt.Printf("CPU affinity: thread→core %s\n", formatCoreAssignment(config.cpuThreads, config.affinityStride, affinityCPUs()))
+	}
+
+	// Pick the disk path before anything uses it
+	if config.diskPath == "auto" && !config.disableDisk {
+		path, err := selectDiskPath(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Disk: Cannot pick a path automatically: %v\n", err)
+			closeOutput()
+			os.Exit(1)
+		}
+		config.diskPath = path
+	}
+
+	// A target is a device or file the user chose, only paths get checked
+	var diskFilesystems map[string]string
+	if !config.disableDisk && config.diskTarget == "" {
+		diskFilesystems = checkDiskFilesystems(config)
+	}
+
+	if config.selfTest {
+		passed := runSelfTest(config)
+		closeOutput()
+		if passed {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	if config.diskLatencyOnly {
+		passed := runDiskLatency(config)
+		closeOutput()
+		if passed {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	// Profile the tool's own overhead
+	stopProfiling := func() {}
+	if config.pprofDir != "" {
+		stop, err := startProfiling(config.pprofDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Profile: Cannot start profiling: %v\n", err)
+			closeOutput()
+			os.Exit(1)
+		}
+		stopProfiling = func() {
+			if err := stop(); err != nil {
+				fmt.Printf("Profile: Error writing profiles: %v\n", err)
+			}
+		}
+	}
+	if config.pprofHTTP != "" {
+		go servePprof(config.pprofHTTP)
 	}
 
 	// Set up signal handling for graceful shutdown
@@ -94,46 +792,556 @@ func main() {
 
 	stopChan := make(chan struct{})
 
-	// Create shared CPU stats for quiet mode
-	cpuStats := &CPUStats{lastReport: time.Now()}
+	// Snapshot runtime stats so GC activity during the run can be reported
+	var memStatsStart runtime.MemStats
+	runtime.ReadMemStats(&memStatsStart)
+	runtimeStart := readRuntimeMetrics()
+
+	// Create shared CPU and disk stats and the latest-metrics snapshot
+	cpuStats := newCPUStats(time.Duration(config.reportInterval) * time.Second)
+	diskStats := &DiskStats{}
+	metrics := &Metrics{}
+	failures := &FailureLog{stderr: config.format == "none"}
+	runStart := time.Now()
+
+	// Continue the statistics of an earlier, interrupted run
+	var resumed ResumeState
+	if config.resumeFile != "" {
+		state, err := loadResumeState(config.resumeFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf("Resume: No saved stats in %s yet, starting fresh\n", config.resumeFile)
+		case err != nil:
+			fmt.Printf("Resume: Error loading %s, starting fresh: %v\n", config.resumeFile, err)
+		default:
+			state.apply(cpuStats, diskStats, metrics)
+			resumed = state
+			fmt.Printf("Resume: Continuing after %v of earlier runs\n", state.Elapsed.Round(time.Second))
+		}
+	}
+
+	// With -allocate-upfront a failed allocation aborts before anything runs
+	var upfrontAllocator *chunkAllocator
+	var upfrontChunks [][]byte
+	if config.allocateUpfront {
+		upfrontAllocator = newChunkAllocator(config)
+		// Nothing waits for signals before the run starts, so an interrupt
+		// during a long allocation is passed to it here
+		allocationStop := make(chan struct{})
+		allocationDone := make(chan struct{})
+		go func() {
+			select {
+			case <-sigChan:
+				close(allocationStop)
+			case <-allocationDone:
+			}
+		}()
+		chunks, ok := allocateMemory(allocationStop, config, upfrontAllocator, metrics, failures)
+		close(allocationDone)
+		select {
+		case <-allocationStop:
+			// Interrupted just as the allocation completed
+			ok = false
+		default:
+		}
+		if !ok {
+			upfrontAllocator.release()
+			fmt.Fprintln(os.Stderr, "Aborting: -allocate-upfront could not obtain the memory target")
+			closeOutput()
+			os.Exit(1)
+		}
+		upfrontChunks = chunks
+	}
+
+	// Exporters and other helpers that must finish before the process exits
+	var background sync.WaitGroup
+	if config.openMetricsFile != "" {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			openMetricsWriter(stopChan, config, metrics, cpuStats, diskStats)
+		}()
+	}
+	if useDashboard {
+		dashboard := startDashboard(output.terminal)
+		output.SetDashboard(dashboard)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dashboard.Run(stopChan, config, metrics, runStart)
+		}()
+	}
+	if reportTemplate != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			templateReporter(stopChan, config, reportTemplate, metrics)
+		}()
+	}
+	if config.outputFile != "" {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			output.reopenOnSignal(stopChan)
+		}()
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		resetOnSignal(stopChan, cpuStats, diskStats)
+	}()
+	swapMonitor := newSwapMonitor()
+	if swapMonitor != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			swapMonitorLoop(stopChan, config, swapMonitor)
+		}()
+	}
+	if stealMonitor := newStealMonitor(); stealMonitor != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			stealMonitorLoop(stopChan, config, stealMonitor, metrics)
+		}()
+	}
+
+	// The summary of the run so far, for checkpoints and the end of the run
+	currentSummary := func() Summary {
+		var memStatsNow runtime.MemStats
+		runtime.ReadMemStats(&memStatsNow)
+		summary := Summary{
+			SchemaVersion: CurrentSchemaVersion,
+			Host:          config.hostLabel,
+			Timestamp:     formatTimestamp(time.Now(), config.timestampTZ),
+			Tags:          config.tags,
+			Environment:   environment,
+			Metrics:       withCounters(metrics.Snapshot(), config, cpuStats, diskStats),
+			GC:            gcStatsBetween(&memStatsStart, &memStatsNow),
+			Swapping:      swapMonitor.Swapping(),
+			Threads:       cpuStats.ThreadSummaries(),
+		}
+		if config.runtimeMetrics {
+			runtimeStats := runtimeStatsBetween(runtimeStart, readRuntimeMetrics())
+			summary.Runtime = &runtimeStats
+		}
+		if !config.disableDisk && config.memScrub == 0 {
+			summary.Disk = &DiskSummary{
+				BytesWritten:   diskStats.bytesWritten.Load(),
+				BytesRead:      diskStats.bytesRead.Load(),
+				Iterations:     diskStats.iterations.Load(),
+				VerifiedBlocks: diskStats.verifiedBlocks.Load(),
+				Mismatches:     diskStats.verifyMismatches.Load(),
+				SparseLogical:  diskStats.sparseLogical.Load(),
+				SparseAlloc:    diskStats.sparseAllocated.Load(),
+				Filesystems:    diskFilesystems,
+			}
+		}
+		return summary
+	}
+	if config.checkpointEvery > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			checkpointWriter(stopChan, config.checkpointEvery, config.checkpointFile, currentSummary)
+		}()
+	}
+
+	var sweepResults []SweepResult
+	var blockSizeResults []BlockSizeSweepResult
+	if len(config.cpuRangeSweep) > 0 {
+		sweepResults = runRangeSweep(sigChan, config, metrics)
+		close(stopChan)
+	} else if len(config.diskBSSweep) > 0 {
+		blockSizeResults = runBlockSizeSweep(sigChan, config, diskStats, failures)
+		close(stopChan)
+	} else if config.sequential {
+		runSequential(sigChan, config, cpuStats, diskStats, metrics, failures)
+		close(stopChan)
+	} else {
+		// Start CPU benchmarking threads
+		var wg sync.WaitGroup
+		if !config.disableCPU {
+			startCPUThreads(stopChan, config, cpuStats, metrics, &wg)
+		}
+
+		// Memory allocation and filesystem benchmarking
+		if !config.disableDisk {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if config.allocateUpfront {
+					benchmarkAllocation(upfrontChunks, stopChan, config, diskStats, metrics, failures)
+					return
+				}
+				memoryAndFilesystemBenchmark(stopChan, config, diskStats, metrics, failures)
+			}()
+		}
+
+		// Wait for interrupt signal or the end of the configured duration
+		metrics.StartClock(config.duration)
+		interrupted := waitForStop(sigChan, config.duration)
+		if config.full {
+			if interrupted {
+				fmt.Println("\nReceived interrupt signal, shutting down...")
+			} else {
+				fmt.Println("Duration elapsed, shutting down...")
+			}
+		}
+		close(stopChan)
+
+		// Let the benchmarks finish their current operation, but not longer
+		// than an orchestrator's grace period allows
+		if !waitTimeout(&wg, config.shutdownTimeout) {
+			fmt.Printf("Benchmarks did not stop within %v, exiting anyway\n", config.shutdownTimeout)
+		} else if upfrontAllocator != nil {
+			upfrontAllocator.release()
+		}
+	}
+
+	background.Wait()
+	if output != nil {
+		output.StopDashboard()
+	}
+
+	summary := currentSummary()
+	summary.Sweep = sweepResults
+	summary.DiskSweep = blockSizeResults
+	printSummary(summary, config)
+	if config.table {
+		printSummaryTable(os.Stdout, summaryTableRows(summary, config, cpuStats.IntervalRates(), diskStats.WriteRates()))
+	}
+	if config.histogram {
+		printHistograms(config, cpuStats, diskStats)
+	}
+
+	if config.resumeFile != "" {
+		state := captureResumeState(resumed.Elapsed+time.Since(runStart), cpuStats, diskStats, metrics)
+		if err := saveResumeState(config.resumeFile, state); err != nil {
+			fmt.Printf("Resume: Error saving %s: %v\n", config.resumeFile, err)
+		}
+	}
+
+	burnInFailed := config.burnIn && !printBurnInResult(failures.Failures(), time.Since(runStart), config.timestampTZ)
+	uploadFailed := false
+	if config.postResults != "" {
+		err := postResults(config.postResults, summary, postResultsAttempts, postResultsTimeout, postResultsBackoff)
+		if err != nil {
+			failures.Record("Upload", "Error posting results to %s: %v", config.postResults, err)
+			uploadFailed = config.postRequired
+		} else if config.full {
+			fmt.Printf("Upload: Posted results to %s\n", config.postResults)
+		}
+	}
+	scoreFailed := false
+	if config.quickCPU {
+		score := summary.Metrics.CPUPrimesPerSec
+		scoreFailed = score == 0
+		if scoreFailed {
+			fmt.Fprintln(os.Stderr, "CPU: No iteration completed in time, lower -prime-range or raise -duration")
+		} else {
+			fmt.Fprintln(scoreOutput, formatQuickScore(score))
+		}
+	}
+
+	stopProfiling()
+	if config.full {
+		fmt.Println("Performance test completed")
+	}
+	// Without -burn-in, whose verdict lists them, errors get a summary
+	runErrors := benchmarkErrors(failures.Failures())
+	if !config.burnIn && len(runErrors) > 0 {
+		first := runErrors[0]
+		message := fmt.Sprintf("Errors: %d in %s, first: %s: %s\n", len(runErrors), formatErrorSubsystems(runErrors), first.Component, first.Message)
+		if config.format == "none" {
+			fmt.Fprint(os.Stderr, message)
+		} else {
+			fmt.Print(message)
+		}
+	}
+	closeOutput()
+	if code := runExitCode(config, failures.Failures(), burnInFailed || scoreFailed || uploadFailed); code != 0 {
+		os.Exit(code)
+	}
+}
+
+func startCPUThreads(stopChan <-chan struct{}, config Config, cpuStats *CPUStats, metrics *Metrics, wg *sync.WaitGroup) {
+	jitterStats := newJitterStats(time.Duration(config.reportInterval) * time.Second)
+
+	if rotatingWorkloads(config) {
+		startRotationThreads(stopChan, config, cpuStats, metrics, wg)
+		return
+	}
+
+	var workers sync.WaitGroup
+	launchThreads(stopChan, config, wg, &workers, func(threadID int) {
+		switch config.cpuWorkload {
+		case "prime":
+			benchmarkPrimality(threadID, stopChan, config, cpuStats)
+		case "idle-spin":
+			benchmarkIdleSpin(threadID, stopChan, config, jitterStats, metrics)
+		default:
+			benchmarkOps(threadID, stopChan, config, opsWorkloads[config.cpuWorkload], cpuStats)
+		}
+	})
+
+	// Idle-spin reports its jitter windows from the threads themselves
+	if config.cpuWorkload == "idle-spin" {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reportCPU(stopChan, config, cpuStats, metrics)
+		// Leave the final aggregate, including the last iterations, for the summary
+		workers.Wait()
+		updateCPUMetrics(config, cpuStats, metrics)
+	}()
+}
+
+// oversubscription returns how many threads share each logical core, and
+// whether that is more than one
+func oversubscription(threads, cores int) (float64, bool) {
+	if cores < 1 {
+		return 0, false
+	}
+	return float64(threads) / float64(cores), threads > cores
+}
+
+// launchThreads starts config.cpuThreads goroutines running run. With
+// -stagger-start they start that far apart from a background goroutine, and
+// once all of them run that is reported. Both wait groups count every
+// thread up front, so waiting on them is safe while threads still launch.
+func launchThreads(stopChan <-chan struct{}, config Config, wg, workers *sync.WaitGroup, run func(threadID int)) {
+	wg.Add(config.cpuThreads)
+	workers.Add(config.cpuThreads)
+	var cpus []int
+	if config.affinityStride > 0 {
+		cpus = affinityCPUs()
+	}
+	launch := func(threadID int) {
+		go func() {
+			defer wg.Done()
+			defer workers.Done()
+			if config.affinityStride > 0 {
+				core := affinityCore(threadID, config.affinityStride, cpus)
+				if err := pinThread(core); err != nil {
+					fmt.Printf("CPU Thread %d: Cannot pin to core %d: %v\n", threadID, core, err)
+				}
+			}
+			run(threadID)
+		}()
+	}
+
+	if config.staggerStart == 0 {
+		for i := 0; i < config.cpuThreads; i++ {
+			launch(i)
+		}
+		return
+	}
+
+	go func() {
+		start := time.Now()
+		for i := 0; i < config.cpuThreads; i++ {
+			if i > 0 {
+				select {
+				case <-stopChan:
+					// Release the threads that never started
+					for ; i < config.cpuThreads; i++ {
+						wg.Done()
+						workers.Done()
+					}
+					return
+				case <-time.After(config.staggerStart):
+				}
+			}
+			launch(i)
+		}
+		fmt.Printf("CPU: All %d threads active after %v\n", config.cpuThreads, time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// waitForStop blocks until a signal arrives or the duration elapses (0 waits
+// for a signal only) and reports whether it was interrupted by a signal.
+func waitForStop(sigChan <-chan os.Signal, duration time.Duration) bool {
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timeout = time.After(duration)
+	}
+
+	select {
+	case <-sigChan:
+		return true
+	case <-timeout:
+		return false
+	}
+}
+
+// waitTimeout waits for wg for at most timeout and reports whether it finished
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// nextReportInterval grows a report interval by the backoff factor, capped at
+// the configured maximum but never shrinking below the current interval.
+func nextReportInterval(current time.Duration, config Config) time.Duration {
+	next := time.Duration(float64(current) * config.reportBackoff)
+	if next > config.reportBackoffMax {
+		next = config.reportBackoffMax
+	}
+	if next < current {
+		return current
+	}
+	return next
+}
+
+// runtimeTooShort reports whether a -duration is set below -min-runtime.
+// -quick-cpu is short by design, so it is left alone, and so are the sweeps,
+// where -duration is the length of each step rather than of the run.
+func runtimeTooShort(config Config) bool {
+	sweep := len(config.cpuRangeSweep) > 0 || len(config.diskBSSweep) > 0
+	return config.duration > 0 && config.duration < config.minRuntime && !config.quickCPU && !sweep
+}
+
+// runtimeTooShortMessage explains a runtimeTooShort run, as a warning or with
+// -strict as the error
+func runtimeTooShortMessage(config Config) string {
+	return fmt.Sprintf("-duration %v is shorter than -min-runtime %v, so warmup dominates and the results may be unreliable", config.duration, config.minRuntime)
+}
+
+// runSequential runs each enabled subsystem on its own for config.duration so
+// that no phase has to share the machine with another one.
+func runSequential(sigChan <-chan os.Signal, config Config, cpuStats *CPUStats, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	var phases []string
+	if !config.disableCPU {
+		phases = append(phases, "CPU")
+	}
+	if !config.disableDisk {
+		phases = append(phases, "Memory", "Disk")
+	}
+
+	var memoryChunks [][]byte
+	allocator := newChunkAllocator(config)
+	defer allocator.release()
+	for i, phase := range phases {
+		if i > 0 && config.cooldown > 0 && runCooldown(sigChan, config, metrics) {
+			if config.full {
+				fmt.Println("\nReceived interrupt signal, skipping remaining phases...")
+			}
+			return
+		}
+		fmt.Printf("=== Phase %d/%d: %s ===\n", i+1, len(phases), phase)
+
+		phaseStop := make(chan struct{})
+		done := make(chan struct{})
+		interrupted := false
+
+		switch phase {
+		case "CPU":
+			var wg sync.WaitGroup
+			startCPUThreads(phaseStop, config, cpuStats, metrics, &wg)
+			interrupted = waitForStop(sigChan, config.duration)
+			close(phaseStop)
+			wg.Wait()
+		case "Memory":
+			// The allocation runs to completion, then refilling it is timed
+			go func() {
+				memoryChunks, _ = allocateMemory(phaseStop, config, allocator, metrics, failures)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-sigChan:
+				interrupted = true
+				close(phaseStop)
+				<-done
+			}
+			if interrupted {
+				break
+			}
+			filled := make(chan struct{})
+			go func() {
+				benchmarkMemoryFill(memoryChunks, phaseStop, config, metrics)
+				close(filled)
+			}()
+			interrupted = waitForStop(sigChan, config.duration)
+			close(phaseStop)
+			<-filled
+		case "Disk":
+			go func() {
+				filesystemBenchmark(memoryChunks, phaseStop, config, diskStats, metrics, failures)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(config.duration):
+			case <-sigChan:
+				interrupted = true
+			}
+			close(phaseStop)
+			<-done
+		}
 
-	// Start CPU benchmarking threads
-	if !config.disableCPU {
-		for i := 0; i < config.cpuThreads; i++ {
-			go func(threadID int) {
-				benchmarkPrimality(threadID, stopChan, config, cpuStats)
-			}(i)
+		if interrupted {
+			if config.full {
+				fmt.Println("\nReceived interrupt signal, skipping remaining phases...")
+			}
+			return
 		}
 	}
+}
 
-	// Memory allocation and filesystem benchmarking
-	if !config.disableDisk {
-		go func() {
-			memoryAndFilesystemBenchmark(stopChan, config)
-		}()
-	}
+// threadPrimeRange staggers the prime range by thread with
+// -cpu-range-stagger, so the threads do not share a warm working set
+func threadPrimeRange(threadID int, config Config) int {
+	return config.primeRange + threadID*config.cpuRangeStagger
+}
 
-	// Wait for interrupt signal
-	<-sigChan
-	if config.full {
-		fmt.Println("\nReceived interrupt signal, shutting down...")
+// normalizePrimeCount scales a prime count from a staggered range to the base
+// range, so the aggregate stays comparable to runs without a stagger
+func normalizePrimeCount(primeCount int, primeRange int, config Config) int {
+	if primeRange == config.primeRange {
+		return primeCount
 	}
-	close(stopChan)
+	return int(float64(primeCount) * float64(config.primeRange-config.primeStart) / float64(primeRange-config.primeStart))
+}
 
-	// Give goroutines time to finish current operations
-	time.Sleep(2 * time.Second)
-	if config.full {
-		fmt.Println("Performance test completed")
+// formatPrimeRange shows the numbers an iteration tests, only naming the
+// start when -cpu-prime-start moved it
+func formatPrimeRange(start, end int) string {
+	if start == 0 {
+		return strconv.Itoa(end)
 	}
+	return fmt.Sprintf("%d to %d", start, end)
 }
 
 func benchmarkPrimality(threadID int, stopChan <-chan struct{}, config Config, cpuStats *CPUStats) {
+	primeRange := threadPrimeRange(threadID, config)
 	if config.full {
-		fmt.Printf("CPU Thread %d: Starting\n", threadID)
+		if primeRange != config.primeRange {
+			fmt.Printf("CPU Thread %d: Starting, prime range %s\n", threadID, formatPrimeRange(config.primeStart, primeRange))
+		} else {
+			fmt.Printf("CPU Thread %d: Starting\n", threadID)
+		}
 	}
 
+	thread := cpuStats.Thread(threadID)
 	iteration := 0
 	lastReport := time.Now()
+	reportInterval := time.Duration(config.reportInterval) * time.Second
 	totalTime := time.Duration(0)
 
 	for {
@@ -145,49 +1353,56 @@ func benchmarkPrimality(threadID int, stopChan <-chan struct{}, config Config, c
 			return
 		default:
 			start := time.Now()
-			primeCount := 0
-
-			for i := 2; i < config.primeRange; i++ {
-				if isPrime(i) {
-					primeCount++
-				}
-			}
+			primeCount := countPrimes(primeRange, config)
 
 			duration := time.Since(start)
 			iteration++
+			if !recordIteration(iteration, primeCount, duration, config, cpuStats, thread) {
+				continue
+			}
 			totalTime += duration
 
-			// Update shared stats for default (quiet) mode
-			if !config.full {
-				cpuStats.mu.Lock()
-				cpuStats.totalTime += duration
-				cpuStats.totalPrimesFound += primeCount
-				shouldReport := time.Since(cpuStats.lastReport) >= time.Duration(config.reportInterval)*time.Second
-				if shouldReport {
-					// Calculate total primes/sec by multiplying average by number of threads
-					avgPrimesPerSec := float64(cpuStats.totalPrimesFound) / cpuStats.totalTime.Seconds()
-					totalPrimesPerSec := avgPrimesPerSec * float64(config.cpuThreads)
-					cpuStats.lastReport = time.Now()
-					cpuStats.mu.Unlock()
-
-					fmt.Printf("CPU: %s total primes/sec\n", formatWithCommas(totalPrimesPerSec))
-				} else {
-					cpuStats.mu.Unlock()
-				}
-			} else {
-				// Report at intervals for full mode
-				if time.Since(lastReport) >= time.Duration(config.reportInterval)*time.Second {
-					avgTime := totalTime / time.Duration(iteration)
-					primesPerSec := float64(primeCount) / duration.Seconds()
-					fmt.Printf("CPU Thread %d: %d iterations, avg %.2fms/iter, %s primes/sec\n",
-						threadID, iteration, avgTime.Seconds()*1000, formatWithCommas(primesPerSec))
-					lastReport = time.Now()
-				}
+			// Report per thread at intervals for full mode
+			if config.full && time.Since(lastReport) >= reportInterval {
+				avgTime := totalTime / time.Duration(iteration-config.warmupIters)
+				primesPerSec := perSecond(float64(primeCount), duration)
+				fmt.Printf("CPU Thread %d: %d iterations, avg %.2fms/iter, %s primes/sec\n",
+					threadID, iteration, avgTime.Seconds()*1000, formatWithCommas(primesPerSec))
+				lastReport = time.Now()
+				reportInterval = nextReportInterval(reportInterval, config)
 			}
 		}
 	}
 }
 
+// recordIteration adds a thread's iteration to cpuStats, and to the thread's
+// own stats unless thread is nil, unless it is one of its first
+// -warmup-iterations, and reports whether it counted. iteration counts the
+// thread's iterations from 1.
+func recordIteration(iteration, count int, duration time.Duration, config Config, cpuStats *CPUStats, thread *ThreadStats) bool {
+	if iteration <= config.warmupIters {
+		return false
+	}
+	cpuStats.Add(count, duration)
+	if thread != nil {
+		thread.iterations.Add(1)
+		thread.count.Add(int64(count))
+		thread.nanos.Add(int64(duration))
+	}
+	return true
+}
+
+// countPrimes runs one prime iteration from -cpu-prime-start up to primeRange
+func countPrimes(primeRange int, config Config) int {
+	primeCount := 0
+	for i := config.primeStart; i < primeRange; i++ {
+		if isPrime(i) {
+			primeCount++
+		}
+	}
+	return normalizePrimeCount(primeCount, primeRange, config)
+}
+
 func isPrime(n int) bool {
 	if n < 2 {
 		return false
@@ -206,47 +1421,109 @@ func isPrime(n int) bool {
 	return true
 }
 
-func memoryAndFilesystemBenchmark(stopChan <-chan struct{}, config Config) {
+func memoryAndFilesystemBenchmark(stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
 	if config.full {
 		fmt.Println("Memory: Starting allocation and filesystem benchmark")
 	}
 
-	// Allocate memory
-	targetMemory := int64(float64(getAvailableMemory(config)) * config.memoryPercent)
-	if config.full {
-		fmt.Printf("Memory: Target allocation: %d MB\n", targetMemory/(1024*1024))
+	allocator := newChunkAllocator(config)
+	defer allocator.release()
+	memoryChunks, ok := allocateMemory(stopChan, config, allocator, metrics, failures)
+	if !ok {
+		return
 	}
+	benchmarkAllocation(memoryChunks, stopChan, config, diskStats, metrics, failures)
+}
 
-	var memoryChunks [][]byte
-	chunkSize := config.chunkSizeMB * 1024 * 1024
-	allocated := int64(0)
+// benchmarkAllocation runs the disk test, or -mem-scrub, on the allocated chunks
+func benchmarkAllocation(memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
+	// The disk test overwrites the chunks, so scrubbing replaces it
+	if config.memScrub > 0 {
+		scrubMemory(memoryChunks, stopChan, config, metrics, failures)
+		return
+	}
+
+	// Now benchmark filesystem using the allocated memory (continuous loop)
+	filesystemBenchmark(memoryChunks, stopChan, config, diskStats, metrics, failures)
+}
+
+func allocateMemory(stopChan <-chan struct{}, config Config, allocator *chunkAllocator, metrics *Metrics, failures *FailureLog) ([][]byte, bool) {
+	// Allocate memory
+	availableMemory := getAvailableMemory(config)
+	basisMemory := availableMemory
+	if config.memoryBasis == "total" {
+		basisMemory = getTotalMemory(config)
+	}
+	targetMemory := int64(float64(basisMemory) * config.memoryPercent)
+	fmt.Printf("Memory: Target allocation: %s (%.0f%% of %s %s memory)\n", formatBytes(targetMemory, config.units),
+		config.memoryPercent*100, formatBytes(basisMemory, config.units), config.memoryBasis)
+	if targetMemory > availableMemory && !config.allocateUpfront {
+		fmt.Printf("Memory: Target exceeds the %s of available memory, the system may swap or run out of memory\n",
+			formatBytes(availableMemory, config.units))
+	}
 
 	start := time.Now()
-	for allocated < targetMemory {
-		select {
-		case <-stopChan:
-			if config.full {
-				fmt.Printf("Memory: Stopping allocation at %d MB\n", allocated/(1024*1024))
-			}
-			return
-		default:
-			chunk := make([]byte, chunkSize)
-			// Fill with random data to ensure actual allocation
-			for i := range chunk {
-				chunk[i] = byte(i % 256)
-			}
-			memoryChunks = append(memoryChunks, chunk)
-			allocated += int64(chunkSize)
+	guard := newMemoryPressureGuard(config, availableMemory)
+	var memoryChunks [][]byte
+	var allocated int64
+	if config.allocateUpfront {
+		var err error
+		if targetMemory > availableMemory {
+			err = fmt.Errorf("target exceeds the %s of available memory", formatBytes(availableMemory, config.units))
+		} else {
+			memoryChunks, allocated, err = allocateFully(stopChan, config, targetMemory, allocator, guard)
+		}
+		if err != nil {
+			fmt.Printf("Memory: Cannot allocate the target upfront: %v\n", err)
+			return nil, false
+		}
+	} else {
+		var ok bool
+		memoryChunks, allocated, ok = allocateChunks(stopChan, config, targetMemory, allocator, guard)
+		if !ok {
+			return nil, false
 		}
 	}
 
 	allocationDuration := time.Since(start)
+	fillMBps := mbPerSecond(allocated, allocationDuration)
+	// Re-read what is left to show whether the system is now under pressure
+	availableAfter := getAvailableMemory(config)
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.MemoryFillMBps = fillMBps
+		snapshot.MemoryRequested = targetMemory
+		snapshot.MemoryAllocated = allocated
+		snapshot.MemoryAvailable = &availableAfter
+	})
+	location := ""
+	if allocator.offHeap {
+		location = ", off-heap"
+	}
 	if config.full {
-		fmt.Printf("Memory: Allocated %d MB in %v\n", allocated/(1024*1024), allocationDuration)
+		fmt.Printf("Memory: Allocated %s in %v (%s%s)\n",
+			formatBytes(allocated, config.units), allocationDuration, formatMBps(fillMBps, config.units), location)
+	} else {
+		fmt.Printf("Memory: fill %s%s\n", formatMBps(fillMBps, config.units), location)
 	}
 
-	// Now benchmark filesystem using the allocated memory (continuous loop)
-	filesystemBenchmark(memoryChunks, stopChan, config)
+	fmt.Printf("Memory: %s\n", formatAllocation(targetMemory, allocated, availableAfter, config.units))
+
+	if config.memVerify {
+		if mismatches := verifyMemory(memoryChunks, config, metrics); mismatches > 0 {
+			failures.Record("Memory", "%d mismatches while verifying the allocation", mismatches)
+		}
+	}
+
+	return memoryChunks, true
+}
+
+// nextChunkSize shrinks the final chunk to what is left of the target, so
+// small targets are not overshot by up to a whole chunk
+func nextChunkSize(remaining, chunkSize int64) int {
+	if remaining < chunkSize {
+		return int(remaining)
+	}
+	return int(chunkSize)
 }
 
 func getAvailableMemory(config Config) int64 {
@@ -258,69 +1535,96 @@ func getAvailableMemory(config Config) int64 {
 
 	fmt.Println("Unsupported OS, using 8GB memory")
 	// Fallback for other systems
-	return 8 * 1024 * 1024 * 1024 // 8GB default
+	return fallbackMemory
 }
 
 func getLinuxMemory(config Config) int64 {
 	// Read /proc/meminfo to get actual available memory
 	data, err := os.ReadFile("/proc/meminfo")
 	if err != nil {
+		// Hardened sandboxes may hide /proc, but the syscall still answers
+		if _, free, sysErr := sysinfoMemory(); sysErr == nil && free > 0 {
+			if config.full {
+				fmt.Println("Found available memory via sysinfo, /proc/meminfo is unreadable:", free)
+			}
+			return free
+		}
 		fmt.Println("Error reading /proc/meminfo", err)
-		return 8 * 1024 * 1024 * 1024 // 8GB default
+		return fallbackMemory
 	}
 
-	lines := strings.Split(string(data), "\n")
-	var memAvailable int64
+	memAvailable := parseLinuxAvailableMemory(string(data), readMinFreeBytes())
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, "MemAvailable:") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				kb, err := strconv.ParseInt(fields[1], 10, 64)
-				if err == nil {
-					memAvailable = kb * 1024 // Convert KB to bytes
-					break
-				}
-			}
-		}
+	// If still 0 or negative, use default
+	if memAvailable <= 0 {
+		fmt.Println("Failed to find available memory, using 8GB memory")
+		return fallbackMemory
 	}
 
-	// If MemAvailable is not found or is 0, fall back to MemFree + Buffers + Cached
-	if memAvailable == 0 {
-		var memFree, buffers, cached int64
+	if config.full {
+		fmt.Println("Found available memory in /proc/meminfo:", memAvailable)
+	}
+	return memAvailable
+}
 
-		for _, line := range lines {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				kb, err := strconv.ParseInt(fields[1], 10, 64)
-				if err != nil {
-					continue
-				}
+// readMinFreeBytes returns the kernel's min_free_kbytes watermark in bytes, or
+// 0 if it cannot be read
+func readMinFreeBytes() int64 {
+	data, err := os.ReadFile("/proc/sys/vm/min_free_kbytes")
+	if err != nil {
+		return 0
+	}
+	kb, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kb * 1024
+}
 
-				switch {
-				case strings.HasPrefix(line, "MemFree:"):
-					memFree = kb * 1024
-				case strings.HasPrefix(line, "Buffers:"):
-					buffers = kb * 1024
-				case strings.HasPrefix(line, "Cached:"):
-					cached = kb * 1024
-				}
-			}
+// parseLinuxAvailableMemory returns MemAvailable from meminfo. Kernels before
+// 3.14 lack it, and MemFree + Buffers + Cached overestimates what can be
+// allocated without OOM, so the fallback keeps the low watermark free and
+// counts only half of the page cache and reclaimable slab.
+func parseLinuxAvailableMemory(meminfo string, minFreeBytes int64) int64 {
+	var memAvailable, memFree, buffers, cached, reclaimable int64
+	haveAvailable := false
+
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
 		}
 
-		memAvailable = memFree + buffers + cached
+		switch fields[0] {
+		case "MemAvailable:":
+			memAvailable = kb * 1024 // Convert KB to bytes
+			haveAvailable = true
+		case "MemFree:":
+			memFree = kb * 1024
+		case "Buffers:":
+			buffers = kb * 1024
+		case "Cached:":
+			cached = kb * 1024
+		case "SReclaimable:":
+			reclaimable = kb * 1024
+		}
 	}
 
-	// If still 0 or negative, use default
-	if memAvailable <= 0 {
-		fmt.Println("Failed to find available memory, using 8GB memory")
-		return 8 * 1024 * 1024 * 1024 // 8GB default
+	if haveAvailable && memAvailable > 0 {
+		return memAvailable
 	}
 
-	if config.full {
-		fmt.Println("Found available memory:", memAvailable)
+	// The kernel's low watermark is min_free_kbytes plus a quarter
+	lowWatermark := minFreeBytes + minFreeBytes/4
+	estimate := memFree - lowWatermark + (buffers+cached)/2 + reclaimable/2
+	if estimate < 0 {
+		return 0
 	}
-	return memAvailable
+	return estimate
 }
 
 func getDarwinMemory(config Config) int64 {
@@ -329,7 +1633,7 @@ func getDarwinMemory(config Config) int64 {
 	output, err := cmd.Output()
 	if err != nil {
 		fmt.Println("Error running vm_stat:", err)
-		return 8 * 1024 * 1024 * 1024 // 8GB default
+		return fallbackMemory
 	}
 
 	lines := strings.Split(string(output), "\n")
@@ -380,7 +1684,19 @@ func getDarwinMemory(config Config) int64 {
 	// If calculation failed, use default
 	if availableMemory <= 0 {
 		fmt.Println("Failed to find available memory, using 8GB memory")
-		return 8 * 1024 * 1024 * 1024 // 8GB default
+		return fallbackMemory
+	}
+
+	// Free and inactive pages miss the memory pressure jetsam acts on
+	if output, err := exec.Command("sysctl", "kern.memorystatus_level").Output(); err == nil {
+		if level, err := parseMemorystatusLevel(string(output)); err == nil {
+			if total, err := readTotalMemory(); err == nil {
+				availableMemory = darwinPressureLimit(availableMemory, total, level)
+			}
+			if config.full {
+				fmt.Printf("Memory pressure level: %d%% free\n", level)
+			}
+		}
 	}
 
 	if config.full {
@@ -389,9 +1705,90 @@ func getDarwinMemory(config Config) int64 {
 	return availableMemory
 }
 
-func filesystemBenchmark(memoryChunks [][]byte, stopChan <-chan struct{}, config Config) {
+// parseMemorystatusLevel parses kern.memorystatus_level as printed by
+// sysctl(8), the percentage of memory macOS considers free. The lower it
+// is, the closer jetsam is to killing processes.
+func parseMemorystatusLevel(output string) (int, error) {
+	value, ok := parseSysctl(output)["kern.memorystatus_level"]
+	if !ok {
+		return 0, errors.New("no kern.memorystatus_level")
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parsing kern.memorystatus_level: %w", err)
+	}
+	if level < 0 || level > 100 {
+		return 0, fmt.Errorf("kern.memorystatus_level %d is not a percentage", level)
+	}
+	return level, nil
+}
+
+// darwinPressureLimit caps the available memory at the share of the total
+// memory that the memory status level reports free
+func darwinPressureLimit(available, total int64, level int) int64 {
+	if limit := int64(float64(total) * float64(level) / 100); limit < available {
+		return limit
+	}
+	return available
+}
+
+// getTotalMemory returns the installed memory for -memory-basis total
+func getTotalMemory(config Config) int64 {
+	total, err := readTotalMemory()
+	if err != nil || total <= 0 {
+		fmt.Println("Failed to find total memory, using 8GB memory:", err)
+		return fallbackMemory
+	}
+	if config.full {
+		fmt.Println("Found total memory:", total)
+	}
+	return total
+}
+
+func readTotalMemory() (int64, error) {
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/meminfo")
+		if err != nil {
+			if total, _, sysErr := sysinfoMemory(); sysErr == nil && total > 0 {
+				return total, nil
+			}
+			return 0, err
+		}
+		return parseLinuxTotalMemory(string(data))
+	case "darwin":
+		output, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	}
+	return physicalMemory()
+}
+
+// parseLinuxTotalMemory returns MemTotal from meminfo in bytes
+func parseLinuxTotalMemory(meminfo string) (int64, error) {
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	return 0, errors.New("no MemTotal in /proc/meminfo")
+}
+
+func filesystemBenchmark(memoryChunks [][]byte, stopChan <-chan struct{}, config Config, diskStats *DiskStats, metrics *Metrics, failures *FailureLog) {
 	if config.full {
-		fmt.Printf("Disk: Starting filesystem benchmark in path: %s\n", config.diskPath)
+		if config.diskTarget != "" {
+			fmt.Printf("Disk: Starting filesystem benchmark on target: %s\n", config.diskTarget)
+		} else {
+			fmt.Printf("Disk: Starting filesystem benchmark in path: %s\n", diskPaths(config)[0])
+		}
 	}
 
 	if len(memoryChunks) == 0 {
@@ -399,31 +1796,138 @@ func filesystemBenchmark(memoryChunks [][]byte, stopChan <-chan struct{}, config
 		return
 	}
 
-	// Create temporary file for benchmarking
-	tempFile, err := os.CreateTemp(config.diskPath, "perf_test_*.tmp")
-	if err != nil {
-		fmt.Printf("Disk: Error creating temp file: %v\n", err)
+	if parallelDisk(config) {
+		parallelDiskBenchmark(memoryChunks, stopChan, config, diskStats, metrics, failures)
 		return
 	}
 
-	defer func(name string) {
-		err := os.Remove(name)
+	var files []*os.File
+	deviceSize := int64(0)
+	if config.diskTarget != "" {
+		// The target belongs to the user, so it is left in place afterwards
+		file, size, err := openDiskTarget(config.diskTarget)
+		if err != nil {
+			failures.Record("Disk", "Error opening disk target: %v", err)
+			return
+		}
+		files, deviceSize = []*os.File{file}, size
+	} else {
+		// Create temporary files for benchmarking
+		var err error
+		count := config.diskRotateFiles
+		if count < 1 {
+			count = 1
+		}
+		files, err = createDiskFiles(diskPaths(config)[0], count)
 		if err != nil {
-			fmt.Printf("Disk: Error removing temp file: %v\n", err)
+			failures.Record("Disk", "Error creating temp file: %v", err)
+			return
 		}
-	}(tempFile.Name())
 
-	defer func(tempFile *os.File) {
-		err := tempFile.Close()
+		defer func() {
+			err := removeDiskFiles(files)
+			if err != nil {
+				failures.Record("Disk", "Error removing temp file: %v", err)
+			}
+		}()
+	}
+
+	if config.diskOSync {
+		syncFiles, err := reopenSyncWrites(files)
 		if err != nil {
-			fmt.Printf("Disk: Error closing temp file: %v\n", err)
+			for _, file := range files {
+				file.Close()
+			}
+			failures.Record("Disk", "Error opening file for synchronous writes: %v", err)
+			return
 		}
-	}(tempFile)
+		files = syncFiles
+	}
 
-	iteration := 0
+	if config.diskDirectVerify {
+		directFiles, err := reopenDirectIO(files)
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+			failures.Record("Disk", "Error opening file for direct I/O: %v", err)
+			return
+		}
+		files = directFiles
+	}
+
+	defer func() {
+		for _, file := range files {
+			err := file.Close()
+			if err != nil {
+				failures.Record("Disk", "Error closing temp file: %v", err)
+			}
+		}
+	}()
+
+	// Each iteration writes the whole allocation unless a file size is given
+	fileSize := diskFileSizeFor(config, diskPaths(config)[0])
+	if fileSize == 0 {
+		for _, chunk := range memoryChunks {
+			fileSize += int64(len(chunk))
+		}
+	}
+	if deviceSize > 0 && fileSize > deviceSize {
+		fileSize = deviceSize
+	}
+
+	// A preallocated file or a device is overwritten in place
+	inPlace := config.diskPreallocate || deviceSize > 0
+
+	if config.diskPreallocate && deviceSize == 0 {
+		for _, file := range files {
+			err := preallocateFile(file, fileSize)
+			if err != nil {
+				fmt.Printf("Disk: Preallocation of %s failed: %v\n", formatBytes(fileSize, config.units), err)
+			} else {
+				fmt.Printf("Disk: Preallocated %s\n", formatBytes(fileSize, config.units))
+			}
+		}
+	}
+
+	if config.diskRWMix >= 0 {
+		mixedDiskBenchmark(files[0], fileSize, memoryChunks, stopChan, config, diskStats, metrics, failures)
+		return
+	}
+	if config.diskMode == "append" {
+		appendDiskBenchmark(files[0], fileSize, memoryChunks, stopChan, config, diskStats, metrics, failures)
+		return
+	}
+	if config.diskDirectVerify {
+		directVerifyDiskBenchmark(files[0], fileSize, stopChan, config, diskStats, metrics, failures)
+		return
+	}
+	if config.diskSparse {
+		sparseDiskBenchmark(files[0], fileSize, memoryChunks, stopChan, config, diskStats, metrics, failures)
+		return
+	}
+
+	blockSize := diskBlockSize(config)
 	lastReport := time.Now()
-	totalWriteMBps := float64(0)
-	totalReadMBps := float64(0)
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+
+	// A resumed run continues the running averages where it left off
+	iteration := int(diskStats.iterations.Load())
+	resumed := metrics.Snapshot()
+	totalWriteMBps := resumed.DiskWriteMBps * float64(iteration)
+	totalReadMBps := resumed.DiskReadMBps * float64(iteration)
+	var writeWindow, readWindow throughputWindow
+	// Measured iterations since the last reset, for the fsync rate of -disk-fsync-interval
+	syncs, syncedBytes := int64(0), int64(0)
+	writeTime := time.Duration(0)
+	writeLatency := newReservoir(config.latencySamples, time.Now().UnixNano())
+	syncLatency := newLatencyStats(config.latencySamples, time.Now().UnixNano())
+	buffer := make([]byte, diskReadBufferSize(config))
+	resetsSeen := diskStats.resets.Load()
+	warmups := 0
+	patternRates := newPatternThroughput()
+	// -burn-in without direct I/O compares what it reads back with what it wrote
+	writeSum, readSum := crc32.New(directVerifyTable), crc32.New(directVerifyTable)
 
 	for {
 		select {
@@ -433,92 +1937,296 @@ func filesystemBenchmark(memoryChunks [][]byte, stopChan <-chan struct{}, config
 			}
 			return
 		default:
-			iteration++
+			if diskStats.resetSince(&resetsSeen) {
+				iteration, totalWriteMBps, totalReadMBps = 0, 0, 0
+				writeWindow, readWindow = throughputWindow{}, throughputWindow{}
+				syncs, syncedBytes, writeTime = 0, 0, 0
+				writeLatency.Reset()
+				syncLatency.Reset()
+				patternRates = newPatternThroughput()
+			}
+			// The first -warmup-iterations run but are left out of the statistics
+			warmingUp := warmups < config.warmupIters
+			if warmingUp {
+				warmups++
+			} else {
+				iteration++
+			}
+			pass := warmups + iteration
+			tempFile := files[(pass-1)%len(files)]
+			pattern := diskIterationPattern(pass, config)
 
 			// Write benchmark
 			_, err := tempFile.Seek(0, 0)
 			if err != nil {
-				fmt.Printf("Disk: Error seeking file: %v\n", err)
+				failures.Record("Disk", "Error seeking file: %v", err)
 				return
 			}
-			err = tempFile.Truncate(0)
-			if err != nil {
-				fmt.Printf("Disk: Error truncating file: %v\n", err)
-				return
+			// Overwriting in place keeps the preallocated blocks
+			if !inPlace {
+				err = tempFile.Truncate(0)
+				if err != nil {
+					failures.Record("Disk", "Error truncating file: %v", err)
+					return
+				}
 			}
 
 			writeStart := time.Now()
 			totalBytesWritten := int64(0)
+			writeSum.Reset()
+			blocksSinceSync := 0
+			iterationSyncs := int64(0)
+			iterationSyncTime := time.Duration(0)
+			iterationThinkTime := time.Duration(0)
+			// Each fsync is timed on its own for -disk-sync-latency
+			syncFile := func() error {
+				syncStart := time.Now()
+				if err := tempFile.Sync(); err != nil {
+					return err
+				}
+				syncDuration := time.Since(syncStart)
+				iterationSyncTime += syncDuration
+				if config.diskSyncLatency && !warmingUp {
+					syncLatency.Add(syncDuration)
+				}
+				iterationSyncs++
+				return nil
+			}
 
-			for _, chunk := range memoryChunks {
+		writeLoop:
+			for chunkIndex := 0; totalBytesWritten < fileSize; chunkIndex++ {
 				select {
 				case <-stopChan:
 					return
 				default:
-					// Fill chunk with random data
-					_, err := rand.Read(chunk)
-					if err != nil {
+					chunk := memoryChunks[chunkIndex%len(memoryChunks)]
+					if remaining := fileSize - totalBytesWritten; remaining < int64(len(chunk)) {
+						chunk = chunk[:remaining]
+					}
+
+					if err := fillDiskChunk(chunk, pattern); err != nil {
 						return
 					}
 
-					n, err := tempFile.Write(chunk)
-					if err != nil {
-						fmt.Printf("Disk: Write error: %v\n", err)
-						break
+					for len(chunk) > 0 {
+						block := chunk
+						if int64(len(block)) > blockSize {
+							block = block[:blockSize]
+						}
+						if budget := diskStats.remainingBudget(config); budget >= 0 && budget < int64(len(block)) {
+							block = block[:budget]
+						}
+						if len(block) == 0 {
+							break writeLoop
+						}
+
+						blockStart := time.Now()
+						n, err := tempFile.Write(block)
+						if !warmingUp {
+							writeLatency.Add(time.Since(blockStart))
+						}
+						diskStats.bytesWritten.Add(int64(n))
+						if err != nil {
+							failures.Record("Disk", "Write error: %v", err)
+							break writeLoop
+						}
+						totalBytesWritten += int64(n)
+						if config.burnIn {
+							writeSum.Write(block[:n])
+						}
+						chunk = chunk[n:]
+
+						blocksSinceSync++
+						if fsyncDue(blocksSinceSync, config) {
+							if err := syncFile(); err != nil {
+								failures.Record("Disk", "Error syncing file: %v", err)
+								return
+							}
+							blocksSinceSync = 0
+						}
+
+						if config.diskThinkTime > 0 {
+							thinkStart := time.Now()
+							if !think(config.diskThinkTime, stopChan) {
+								return
+							}
+							iterationThinkTime += time.Since(thinkStart)
+						}
 					}
-					totalBytesWritten += int64(n)
 				}
 			}
 
-			err = tempFile.Sync()
-			if err != nil {
-				fmt.Printf("Disk: Error syncing file: %v\n", err)
-				return
+			// Flush whatever the periodic fsyncs have not covered yet
+			if blocksSinceSync > 0 {
+				err = syncFile()
+				if err != nil {
+					failures.Record("Disk", "Error syncing file: %v", err)
+					return
+				}
 			}
 			writeDuration := time.Since(writeStart)
-			writeMBps := float64(totalBytesWritten) / (1024 * 1024) / writeDuration.Seconds()
-			totalWriteMBps += writeMBps
+			// -disk-sync-latency reports the flush cost apart from the throughput
+			// The pauses of -disk-think-time are no disk time either
+			transferDuration := writeDuration - iterationThinkTime
+			if config.diskSyncLatency {
+				transferDuration -= iterationSyncTime
+			}
 
 			// Read benchmark
 			_, err = tempFile.Seek(0, 0)
 			if err != nil {
-				fmt.Printf("Disk: Error seeking file: %v\n", err)
+				failures.Record("Disk", "Error seeking file: %v", err)
 				return
 			}
 
+			var reader io.Reader = tempFile
+			if config.burnIn {
+				readSum.Reset()
+				reader = io.TeeReader(tempFile, readSum)
+			}
 			readStart := time.Now()
-			totalBytesRead := int64(0)
-			buffer := make([]byte, config.chunkSizeMB*1024*1024)
+			totalBytesRead, stopped, err := readToEOF(reader, buffer, stopChan)
+			diskStats.bytesRead.Add(totalBytesRead)
+			if stopped {
+				// A partial read would skew the averages
+				return
+			}
+			if err != nil {
+				failures.Record("Disk", "Read error: %v", err)
+			}
+			readDuration := time.Since(readStart)
+			// A device or preallocated file may hold more than was written
+			if config.burnIn && err == nil && totalBytesRead == totalBytesWritten && readSum.Sum32() != writeSum.Sum32() {
+				failures.Record("Disk", "Data read back differs from the data written in pass %d", pass)
+				diskStats.verifyMismatches.Add(1)
+			}
 
-		readLoop:
-			for {
-				select {
-				case <-stopChan:
-					break readLoop
-				default:
-					n, err := tempFile.Read(buffer)
-					if n == 0 {
-						break readLoop
-					}
-					if err != nil && err.Error() != "EOF" {
-						fmt.Printf("Disk: Read error: %v\n", err)
-						break readLoop
-					}
-					totalBytesRead += int64(n)
+			if warmingUp {
+				if diskStats.remainingBudget(config) == 0 {
+					fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+						formatBytes(config.diskTotalLimit, config.units))
+					return
 				}
+				continue
 			}
 
-			readDuration := time.Since(readStart)
-			readMBps := float64(totalBytesRead) / (1024 * 1024) / readDuration.Seconds()
+			syncs += iterationSyncs
+			syncedBytes += totalBytesWritten
+			writeTime += writeDuration
+			writeMBps := mbPerSecond(totalBytesWritten, transferDuration)
+			writeWindow.Add(totalBytesWritten, transferDuration)
+			if config.burnIn && throughputCollapsed(writeMBps, totalWriteMBps/float64(iteration-1), iteration) {
+				failures.Record("Disk", "Write throughput collapsed to %s, average %s",
+					formatMBps(writeMBps, config.units), formatMBps(totalWriteMBps/float64(iteration-1), config.units))
+			}
+			totalWriteMBps += writeMBps
+			if sampleRates(config) {
+				diskStats.addWriteRate(writeMBps)
+			}
+			if config.diskComparePat {
+				patternRates.Add(pattern, writeMBps)
+			}
+
+			readMBps := mbPerSecond(totalBytesRead, readDuration)
+			readWindow.Add(totalBytesRead, readDuration)
+			if config.burnIn && throughputCollapsed(readMBps, totalReadMBps/float64(iteration-1), iteration) {
+				failures.Record("Disk", "Read throughput collapsed to %s, average %s",
+					formatMBps(readMBps, config.units), formatMBps(totalReadMBps/float64(iteration-1), config.units))
+			}
 			totalReadMBps += readMBps
 
-			// Report at intervals or every 5 iterations
-			if time.Since(lastReport) >= time.Duration(config.reportInterval)*time.Second || iteration%5 == 0 {
-				avgWriteMBps := totalWriteMBps / float64(iteration)
-				avgReadMBps := totalReadMBps / float64(iteration)
-				fmt.Printf("Disk: avg write %.2f MB/s, avg read %.2f MB/s\n", avgWriteMBps, avgReadMBps)
+			avgWriteMBps := totalWriteMBps / float64(iteration)
+			avgReadMBps := totalReadMBps / float64(iteration)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.DiskWriteMBps = avgWriteMBps
+				snapshot.DiskReadMBps = avgReadMBps
+				if config.diskComparePat {
+					snapshot.DiskPatternRates = patternRates.Averages()
+				}
+				if config.diskSyncLatency {
+					snapshot.DiskFsyncAvgMs = milliseconds(syncLatency.Average())
+					snapshot.DiskFsyncP99Ms = milliseconds(syncLatency.Percentile(99))
+				}
+				if config.diskOSync {
+					snapshot.DiskSyncWriteP50Ms = milliseconds(writeLatency.Percentile(50))
+					snapshot.DiskSyncWriteP99Ms = milliseconds(writeLatency.Percentile(99))
+				}
+			})
+
+			// Report at intervals or every 5 iterations, unless backing off
+			everyFifth := iteration%5 == 0 && config.reportBackoff == 1
+			if benchmarkReports(config) && (time.Since(lastReport) >= reportInterval || everyFifth) {
+				// With think time the throughput depends on the pauses, so latency leads
+				if config.diskThinkTime > 0 {
+					fmt.Printf("Disk: write latency p50 %v, p90 %v, p99 %v with %v think time\n",
+						writeLatency.Percentile(50).Round(time.Microsecond), writeLatency.Percentile(90).Round(time.Microsecond),
+						writeLatency.Percentile(99).Round(time.Microsecond), config.diskThinkTime)
+				}
+				// The recent rate shows throttling or cache exhaustion the lifetime average hides
+				fmt.Printf("Disk: write %s, read %s (avg write %s, avg read %s)%s\n",
+					formatMBps(writeWindow.Take(), config.units), formatMBps(readWindow.Take(), config.units),
+					formatMBps(avgWriteMBps, config.units), formatMBps(avgReadMBps, config.units), progressSuffix(metrics))
+				if config.diskOSync {
+					fmt.Println(formatSyncWrites(writeLatency, avgWriteMBps, blockSize))
+				} else if config.diskThinkTime == 0 {
+					fmt.Printf("Disk: write latency p50 %v, p99 %v\n",
+						writeLatency.Percentile(50).Round(time.Microsecond), writeLatency.Percentile(99).Round(time.Microsecond))
+				}
+				if config.diskComparePat {
+					fmt.Printf("Disk: avg write by pattern %s\n", formatPatternRates(patternRates.Averages(), config.units))
+				}
+				if config.diskSyncLatency {
+					fmt.Printf("Disk: fsync %.2fms avg, p99 %.2fms\n", milliseconds(syncLatency.Average()), milliseconds(syncLatency.Percentile(99)))
+				}
+				if config.diskFsyncEvery > 0 && syncs > 0 {
+					fmt.Printf("Disk: fsync every %s written, %.1f fsyncs/s\n",
+						formatBytes(syncedBytes/syncs, config.units), perSecond(float64(syncs), writeTime))
+				}
 				lastReport = time.Now()
+				reportInterval = nextReportInterval(reportInterval, config)
 			}
+
+			diskStats.iterations.Add(1)
+			if diskStats.remainingBudget(config) == 0 {
+				fmt.Printf("Disk: Reached total write limit of %s, stopping disk test\n",
+					formatBytes(config.diskTotalLimit, config.units))
+				return
+			}
+		}
+	}
+}
+
+// think pauses for d between I/Os. It reports false if stopChan closed
+// first.
+func think(d time.Duration, stopChan <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stopChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// readToEOF reads r until EOF and returns the number of bytes read, counting
+// the data returned together with io.EOF. It stops early if stopChan closes.
+func readToEOF(r io.Reader, buffer []byte, stopChan <-chan struct{}) (int64, bool, error) {
+	total := int64(0)
+	for {
+		select {
+		case <-stopChan:
+			return total, true, nil
+		default:
+		}
+
+		n, err := r.Read(buffer)
+		total += int64(n)
+		if errors.Is(err, io.EOF) {
+			return total, false, nil
+		}
+		if err != nil {
+			return total, false, err
 		}
 	}
 }
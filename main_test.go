@@ -1,9 +1,17 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestIsPrime(t *testing.T) {
@@ -43,6 +51,82 @@ func TestIsPrime(t *testing.T) {
 	}
 }
 
+func TestThreadPrimeRange(t *testing.T) {
+	config := Config{primeRange: 1000000, cpuRangeStagger: 50000}
+	for threadID, expected := range []int{1000000, 1050000, 1100000, 1150000} {
+		if result := threadPrimeRange(threadID, config); result != expected {
+			t.Errorf("threadPrimeRange(%d) = %d, expected %d", threadID, result, expected)
+		}
+	}
+
+	config.cpuRangeStagger = 0
+	if result := threadPrimeRange(3, config); result != config.primeRange {
+		t.Errorf("threadPrimeRange() without stagger = %d, expected %d", result, config.primeRange)
+	}
+}
+
+func TestNormalizePrimeCount(t *testing.T) {
+	config := Config{primeRange: 1000000}
+	if result := normalizePrimeCount(78498, 1000000, config); result != 78498 {
+		t.Errorf("normalizePrimeCount() for the base range = %d, expected it unchanged", result)
+	}
+	if result := normalizePrimeCount(1000, 2000000, config); result != 500 {
+		t.Errorf("normalizePrimeCount(1000, 2000000) = %d, expected 500", result)
+	}
+}
+
+func TestOversubscription(t *testing.T) {
+	tests := []struct {
+		threads, cores int
+		ratio          float64
+		over           bool
+	}{
+		{3, 4, 0.75, false},
+		{4, 4, 1, false},
+		{5, 4, 1.25, true},
+		{64, 8, 8, true},
+		{4, 0, 0, false},
+	}
+	for _, test := range tests {
+		ratio, over := oversubscription(test.threads, test.cores)
+		if ratio != test.ratio || over != test.over {
+			t.Errorf("oversubscription(%d, %d) = %v, %v, expected %v, %v", test.threads, test.cores, ratio, over, test.ratio, test.over)
+		}
+	}
+}
+
+func TestCountPrimesFromStart(t *testing.T) {
+	tests := []struct {
+		start, end int
+		expected   int
+	}{
+		{0, 100, 25},
+		{2, 100, 25},
+		// 101, 103, 107, 109, 113, 127, 131, 137, 139, 149
+		{100, 150, 10},
+		// 1,000,000 to 1,001,000 holds 75 primes
+		{1000000, 1001000, 75},
+		// The range excludes its end, but includes its start
+		{97, 98, 1},
+		{96, 97, 0},
+	}
+	for _, test := range tests {
+		config := Config{primeStart: test.start, primeRange: test.end}
+		if result := countPrimes(test.end, config); result != test.expected {
+			t.Errorf("countPrimes() from %d to %d = %d, expected %d", test.start, test.end, result, test.expected)
+		}
+	}
+
+	// A stagger is scaled by the shifted widths, here 2,000 against 1,000
+	config := Config{primeStart: 1000000, primeRange: 1001000}
+	if result := normalizePrimeCount(150, 1002000, config); result != 75 {
+		t.Errorf("normalizePrimeCount() of a shifted range = %d, expected 75", result)
+	}
+	if result := formatPrimeRange(1000000, 1001000); result != "1000000 to 1001000" {
+		t.Errorf("formatPrimeRange() = %q, expected \"1000000 to 1001000\"", result)
+	}
+}
+
 func TestFormatWithCommas(t *testing.T) {
 	tests := []struct {
 		input    float64
@@ -107,6 +191,90 @@ func TestGetLinuxMemory(t *testing.T) {
 	}
 }
 
+func TestParseLinuxAvailableMemory(t *testing.T) {
+	withAvailable := "MemTotal:        8000000 kB\nMemFree:         1000000 kB\nMemAvailable:    3000000 kB\n"
+	if available := parseLinuxAvailableMemory(withAvailable, 0); available != 3000000*1024 {
+		t.Errorf("parseLinuxAvailableMemory() = %d, expected MemAvailable %d", available, int64(3000000*1024))
+	}
+
+	// Pre-3.14 kernels have no MemAvailable line
+	oldKernel := "MemTotal:        8000000 kB\nMemFree:         1000000 kB\nBuffers:          100000 kB\n" +
+		"Cached:           400000 kB\nSReclaimable:     100000 kB\n"
+	available := parseLinuxAvailableMemory(oldKernel, 10000*1024)
+	if expected := int64(1000000-12500+250000+50000) * 1024; available != expected {
+		t.Errorf("parseLinuxAvailableMemory() without MemAvailable = %d, expected %d", available, expected)
+	}
+	if naive := int64(1000000+100000+400000) * 1024; available >= naive {
+		t.Errorf("Fallback estimate %d is not below MemFree + Buffers + Cached %d", available, naive)
+	}
+
+	// A watermark above the free memory must not produce a negative estimate
+	if available := parseLinuxAvailableMemory("MemFree:            1000 kB\n", 10000*1024); available != 0 {
+		t.Errorf("parseLinuxAvailableMemory() below the watermark = %d, expected 0", available)
+	}
+}
+
+func TestParseLinuxMemoryBasis(t *testing.T) {
+	meminfo := "MemTotal:        8000000 kB\nMemFree:         1000000 kB\nMemAvailable:    3000000 kB\n"
+	available := func(meminfo string) (int64, error) {
+		return parseLinuxAvailableMemory(meminfo, 0), nil
+	}
+	tests := []struct {
+		basis    string
+		parse    func(string) (int64, error)
+		expected int64
+	}{
+		{"available", available, 3000000 * 1024},
+		{"total", parseLinuxTotalMemory, 8000000 * 1024},
+	}
+
+	for _, test := range tests {
+		memory, err := test.parse(meminfo)
+		if err != nil {
+			t.Errorf("%s memory error: %v", test.basis, err)
+			continue
+		}
+		if memory != test.expected {
+			t.Errorf("%s memory = %d, expected %d", test.basis, memory, test.expected)
+		}
+	}
+
+	if _, err := parseLinuxTotalMemory("MemFree:         1000000 kB\n"); err == nil {
+		t.Error("parseLinuxTotalMemory() without MemTotal succeeded, expected an error")
+	}
+}
+
+func TestNextChunkSize(t *testing.T) {
+	const mib = 1024 * 1024
+	tests := []struct {
+		target    int64
+		chunkSize int64
+		chunks    int
+	}{
+		{600 * mib, 100 * mib, 6},
+		{540 * mib, 100 * mib, 6},
+		{50 * mib, 100 * mib, 1},
+		{1000*mib + 1, 64 * mib, 16},
+		{16 * mib, 16 * mib, 1},
+	}
+
+	for _, test := range tests {
+		allocated, chunks := int64(0), 0
+		for allocated < test.target {
+			size := nextChunkSize(test.target-allocated, test.chunkSize)
+			if size <= 0 || int64(size) > test.chunkSize {
+				t.Fatalf("nextChunkSize(%d, %d) = %d, expected 1 to %d", test.target-allocated, test.chunkSize, size, test.chunkSize)
+			}
+			allocated += int64(size)
+			chunks++
+		}
+		if allocated != test.target || chunks != test.chunks {
+			t.Errorf("Target %d with %d byte chunks allocated %d in %d chunks, expected %d in %d chunks",
+				test.target, test.chunkSize, allocated, chunks, test.target, test.chunks)
+		}
+	}
+}
+
 func TestGetDarwinMemory(t *testing.T) {
 	if runtime.GOOS != "darwin" {
 		t.Skip("Skipping Darwin-specific test on non-Darwin platform")
@@ -120,6 +288,38 @@ func TestGetDarwinMemory(t *testing.T) {
 	}
 }
 
+func TestParseMemorystatusLevel(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected int
+		valid    bool
+	}{
+		{"kern.memorystatus_level: 78\n", 78, true},
+		{"kern.memorystatus_level: 0\n", 0, true},
+		{"kern.memorystatus_level: abc\n", 0, false},
+		{"kern.memorystatus_level: 120\n", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		level, err := parseMemorystatusLevel(test.output)
+		if (err == nil) != test.valid || level != test.expected {
+			t.Errorf("parseMemorystatusLevel(%q) = %d, %v, expected %d, valid %v", test.output, level, err, test.expected, test.valid)
+		}
+	}
+}
+
+func TestDarwinPressureLimit(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	// Under pressure the level caps what vm_stat counts as free and inactive
+	if limit := darwinPressureLimit(8*gib, 16*gib, 25); limit != 4*gib {
+		t.Errorf("darwinPressureLimit(8 GiB, 16 GiB, 25%%) = %d, expected %d", limit, int64(4*gib))
+	}
+	if limit := darwinPressureLimit(4*gib, 16*gib, 80); limit != 4*gib {
+		t.Errorf("darwinPressureLimit(4 GiB, 16 GiB, 80%%) = %d, expected the available %d", limit, int64(4*gib))
+	}
+}
+
 func TestConfigValidation(t *testing.T) {
 	// Test memory percent validation bounds
 	tests := []struct {
@@ -154,6 +354,89 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
+func TestReportIntervalValidation(t *testing.T) {
+	// The validation exits the process, so run main in a child process
+	if interval := os.Getenv("PERF_TEST_REPORT_INTERVAL"); interval != "" {
+		os.Args = []string{"perf-test", "-report-interval", interval}
+		main()
+		return
+	}
+
+	for _, interval := range []string{"0", "-5"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestReportIntervalValidation$")
+		cmd.Env = append(os.Environ(), "PERF_TEST_REPORT_INTERVAL="+interval)
+		output, err := cmd.CombinedOutput()
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			t.Errorf("-report-interval %s exited with %v, expected exit status 1", interval, err)
+		}
+		if !strings.Contains(string(output), "Report interval must be at least 1 second") {
+			t.Errorf("-report-interval %s printed %q, expected the validation error", interval, output)
+		}
+	}
+}
+
+func TestPrimeRangeValidation(t *testing.T) {
+	// The validation exits the process, so run main in a child process
+	if primeRange := os.Getenv("PERF_TEST_PRIME_RANGE"); primeRange != "" {
+		os.Args = []string{"perf-test", "-prime-range", primeRange}
+		main()
+		return
+	}
+
+	for _, primeRange := range []string{"1", "0", "-5"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPrimeRangeValidation$")
+		cmd.Env = append(os.Environ(), "PERF_TEST_PRIME_RANGE="+primeRange)
+		output, err := cmd.CombinedOutput()
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			t.Errorf("-prime-range %s exited with %v, expected exit status 1", primeRange, err)
+		}
+		if !strings.Contains(string(output), "Prime range must be at least 2") {
+			t.Errorf("-prime-range %s printed %q, expected the validation error", primeRange, output)
+		}
+	}
+}
+
+func TestRuntimeTooShort(t *testing.T) {
+	tests := []struct {
+		duration   time.Duration
+		minRuntime time.Duration
+		quickCPU   bool
+		sweep      bool
+		expected   bool
+	}{
+		{2 * time.Second, 30 * time.Second, false, false, true},
+		{time.Minute, 30 * time.Second, false, false, false},
+		{30 * time.Second, 30 * time.Second, false, false, false},
+		// Runs until interrupted, so the length is unknown
+		{0, 30 * time.Second, false, false, false},
+		// Off, the default
+		{2 * time.Second, 0, false, false, false},
+		{10 * time.Second, 30 * time.Second, true, false, false},
+		// -duration is the length of each sweep step
+		{10 * time.Second, 30 * time.Second, false, true, false},
+	}
+
+	for _, test := range tests {
+		config := Config{duration: test.duration, minRuntime: test.minRuntime, quickCPU: test.quickCPU}
+		if test.sweep {
+			config.diskBSSweep = []int64{4096, 8192}
+		}
+		if short := runtimeTooShort(config); short != test.expected {
+			t.Errorf("runtimeTooShort(duration %v, min %v, quick %v, sweep %v) = %v, expected %v",
+				test.duration, test.minRuntime, test.quickCPU, test.sweep, short, test.expected)
+		}
+	}
+	// The CPU range sweep steps the same way
+	config := Config{duration: 10 * time.Second, minRuntime: 30 * time.Second, cpuRangeSweep: []int{1000, 2000}}
+	if runtimeTooShort(config) {
+		t.Error("runtimeTooShort() with -cpu-range-sweep = true, expected false")
+	}
+}
+
 func TestCPUThreadsCalculation(t *testing.T) {
 	cpuCores := runtime.NumCPU()
 
@@ -219,3 +502,403 @@ func TestTempDirWritable(t *testing.T) {
 	tempFile.Close()
 	os.Remove(tempFile.Name())
 }
+
+func TestWaitTimeout(t *testing.T) {
+	var finished sync.WaitGroup
+	finished.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		finished.Done()
+	}()
+	start := time.Now()
+	if !waitTimeout(&finished, time.Minute) {
+		t.Errorf("waitTimeout() = false for goroutines that finished")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waitTimeout() returned after %v, expected as soon as the goroutines finished", elapsed)
+	}
+
+	var stuck sync.WaitGroup
+	stuck.Add(1)
+	defer stuck.Done()
+	start = time.Now()
+	if waitTimeout(&stuck, 50*time.Millisecond) {
+		t.Errorf("waitTimeout() = true for a goroutine that never finished")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("waitTimeout() returned after %v, expected the 50ms timeout", elapsed)
+	}
+}
+
+func TestLaunchThreadsStaggered(t *testing.T) {
+	config := Config{cpuThreads: 3, staggerStart: 20 * time.Millisecond}
+	var wg, workers sync.WaitGroup
+	var mu sync.Mutex
+	var started []time.Time
+	start := time.Now()
+	launchThreads(make(chan struct{}), config, &wg, &workers, func(threadID int) {
+		mu.Lock()
+		started = append(started, time.Now())
+		mu.Unlock()
+	})
+	if !waitTimeout(&workers, 5*time.Second) {
+		t.Fatal("launchThreads() did not start every thread")
+	}
+	if len(started) != 3 {
+		t.Fatalf("launchThreads() started %d threads, expected 3", len(started))
+	}
+	if elapsed := started[2].Sub(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Last thread started after %v, expected at least two staggers of 20ms", elapsed)
+	}
+
+	// Threads that never started must not keep the wait groups waiting
+	stopChan := make(chan struct{})
+	close(stopChan)
+	config = Config{cpuThreads: 3, staggerStart: time.Hour}
+	launchThreads(stopChan, config, &wg, &workers, func(threadID int) {})
+	if !waitTimeout(&wg, 5*time.Second) {
+		t.Error("launchThreads() left unstarted threads in the wait group after stop")
+	}
+}
+
+func TestThink(t *testing.T) {
+	start := time.Now()
+	if !think(20*time.Millisecond, make(chan struct{})) {
+		t.Error("think() = false without a stop")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("think() returned after %v, expected at least 20ms", elapsed)
+	}
+
+	stopChan := make(chan struct{})
+	close(stopChan)
+	start = time.Now()
+	if think(time.Hour, stopChan) {
+		t.Error("think() = true after stop")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("think() returned after %v, expected at once on stop", elapsed)
+	}
+}
+
+func TestDiskThinkTime(t *testing.T) {
+	// Four blocks with a pause after each, and the write limit ends the test
+	// after one iteration
+	config := Config{diskPath: t.TempDir(), diskFileSize: 4 * 4096, diskBlockSize: 4096, diskTotalLimit: 4 * 4096,
+		diskThinkTime: 20 * time.Millisecond, diskRWMix: -1, diskMode: "rewrite", diskRotateFiles: 1, diskWorkers: 1,
+		diskPattern: "random", chunkSizeMB: 1, reportInterval: 3600, reportBackoff: 1, latencySamples: 100}
+	diskStats := &DiskStats{}
+	metrics := &Metrics{}
+	start := time.Now()
+	filesystemBenchmark([][]byte{make([]byte, 1024*1024)}, make(chan struct{}), config, diskStats, metrics, &FailureLog{})
+	elapsed := time.Since(start)
+	if elapsed < 80*time.Millisecond {
+		t.Errorf("Iteration of 4 blocks took %v, expected at least 4 think times of 20ms", elapsed)
+	}
+	if iterations := diskStats.iterations.Load(); iterations != 1 {
+		t.Fatalf("filesystemBenchmark() completed %d iterations, expected 1", iterations)
+	}
+	// The pauses are left out of the throughput, so it beats the bytes over the whole run
+	overall := float64(4*4096) / (1024 * 1024) / elapsed.Seconds()
+	if writeMBps := metrics.Snapshot().DiskWriteMBps; writeMBps <= overall {
+		t.Errorf("Write throughput %.2f MiB/s is not above %.2f MiB/s including the think time", writeMBps, overall)
+	}
+}
+
+func TestNextReportInterval(t *testing.T) {
+	tests := []struct {
+		current  time.Duration
+		backoff  float64
+		max      time.Duration
+		expected time.Duration
+	}{
+		{5 * time.Second, 1, 5 * time.Minute, 5 * time.Second},     // Backoff disabled
+		{5 * time.Second, 2, 5 * time.Minute, 10 * time.Second},    // Doubles
+		{160 * time.Second, 2, 5 * time.Minute, 5 * time.Minute},   // Capped at max
+		{5 * time.Minute, 2, 5 * time.Minute, 5 * time.Minute},     // Stays at max
+		{10 * time.Second, 1.5, 5 * time.Minute, 15 * time.Second}, // Fractional factor
+		{10 * time.Second, 2, 5 * time.Second, 10 * time.Second},   // Max below start never shrinks
+	}
+
+	for _, test := range tests {
+		config := Config{reportBackoff: test.backoff, reportBackoffMax: test.max}
+		result := nextReportInterval(test.current, config)
+		if result != test.expected {
+			t.Errorf("nextReportInterval(%v) with backoff %.1f, max %v = %v, expected %v",
+				test.current, test.backoff, test.max, result, test.expected)
+		}
+	}
+}
+
+func TestCPUStatsConcurrentAdd(t *testing.T) {
+	stats := newCPUStats(time.Hour)
+
+	const goroutines = 64
+	const iterations = 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				stats.Add(3, time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if primes := stats.totalPrimesFound.Load(); primes != goroutines*iterations*3 {
+		t.Errorf("totalPrimesFound = %d, expected %d", primes, goroutines*iterations*3)
+	}
+	if total := time.Duration(stats.totalTimeNanos.Load()); total != goroutines*iterations*time.Millisecond {
+		t.Errorf("totalTime = %v, expected %v", total, goroutines*iterations*time.Millisecond)
+	}
+
+	// 3 primes per millisecond per thread, across 4 threads
+	if rate := stats.TotalPrimesPerSec(4); rate != 12000 {
+		t.Errorf("TotalPrimesPerSec(4) = %f, expected 12000", rate)
+	}
+}
+
+func TestReportCPU(t *testing.T) {
+	stats := newCPUStats(10 * time.Millisecond)
+	stats.Add(3000, time.Second)
+	// Full mode keeps the reports off the test output
+	config := Config{cpuThreads: 2, cpuWorkload: "prime", full: true, reportBackoff: 1, reportBackoffMax: time.Hour}
+	metrics := &Metrics{}
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		reportCPU(stopChan, config, stats, metrics)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for metrics.Snapshot().CPUPrimesPerSec != 6000 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if rate := metrics.Snapshot().CPUPrimesPerSec; rate != 6000 {
+		t.Errorf("reportCPU() published %f primes/sec, expected 6000", rate)
+	}
+
+	close(stopChan)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("reportCPU() did not return after stopChan closed")
+	}
+}
+
+func TestUpdateCPUMetricsOpsWorkload(t *testing.T) {
+	stats := newCPUStats(time.Hour)
+	stats.Add(500, time.Second)
+	metrics := &Metrics{}
+	updateCPUMetrics(Config{cpuThreads: 4, cpuWorkload: "branchy"}, stats, metrics)
+
+	snapshot := metrics.Snapshot()
+	if snapshot.CPUOpsPerSec != 2000 || snapshot.CPUPrimesPerSec != 0 {
+		t.Errorf("updateCPUMetrics() for branchy = %+v, expected 2000 ops/sec and no primes", snapshot)
+	}
+}
+
+func TestRecordIterationWarmup(t *testing.T) {
+	stats := newCPUStats(time.Second)
+	config := Config{warmupIters: 3}
+	for iteration := 1; iteration <= 5; iteration++ {
+		counted := recordIteration(iteration, 100*iteration, time.Second, config, stats, nil)
+		if counted != (iteration > 3) {
+			t.Errorf("recordIteration() for iteration %d = %v, expected %v", iteration, counted, iteration > 3)
+		}
+	}
+	// Only iterations 4 and 5 count
+	if primes, nanos := stats.totalPrimesFound.Load(), stats.totalTimeNanos.Load(); primes != 900 || nanos != int64(2*time.Second) {
+		t.Errorf("totals after warmup = %d primes in %v, expected 900 in 2s", primes, time.Duration(nanos))
+	}
+}
+
+func TestThreadSummaries(t *testing.T) {
+	stats := newCPUStats(time.Second)
+	config := Config{}
+	// Thread 1 runs at half the rate of the others, like a slower core
+	perIteration := map[int]int{0: 1000, 1: 500, 2: 1000}
+	for threadID := 2; threadID >= 0; threadID-- {
+		thread := stats.Thread(threadID)
+		for iteration := 1; iteration <= 4; iteration++ {
+			recordIteration(iteration, perIteration[threadID], time.Second, config, stats, thread)
+		}
+	}
+
+	summaries := stats.ThreadSummaries()
+	expected := []ThreadSummary{{0, 4, 1000}, {1, 4, 500}, {2, 4, 1000}}
+	if len(summaries) != len(expected) {
+		t.Fatalf("ThreadSummaries() = %+v, expected %+v", summaries, expected)
+	}
+	sum := 0.0
+	for i := range expected {
+		if summaries[i] != expected[i] {
+			t.Errorf("ThreadSummaries()[%d] = %+v, expected %+v", i, summaries[i], expected[i])
+		}
+		sum += summaries[i].PerSec
+	}
+
+	// With equal time per thread the rates add up to the aggregate
+	if aggregate := stats.TotalPrimesPerSec(len(expected)); sum != aggregate {
+		t.Errorf("per-thread rates sum to %f, aggregate is %f", sum, aggregate)
+	}
+	var count, nanos int64
+	for _, thread := range stats.threads {
+		count += thread.count.Load()
+		nanos += thread.nanos.Load()
+	}
+	if count != stats.totalPrimesFound.Load() || nanos != stats.totalTimeNanos.Load() {
+		t.Errorf("per-thread totals %d in %v, aggregate %d in %v", count, time.Duration(nanos),
+			stats.totalPrimesFound.Load(), time.Duration(stats.totalTimeNanos.Load()))
+	}
+
+	stats.Reset()
+	if summaries := stats.ThreadSummaries(); summaries[0].Iterations != 0 || summaries[0].PerSec != 0 {
+		t.Errorf("ThreadSummaries() after Reset = %+v, expected zeroes", summaries)
+	}
+}
+
+func TestCPUStatsEmpty(t *testing.T) {
+	if rate := newCPUStats(time.Second).TotalPrimesPerSec(8); rate != 0 {
+		t.Errorf("TotalPrimesPerSec() without samples = %f, expected 0", rate)
+	}
+}
+
+func TestCPUStatsZeroDuration(t *testing.T) {
+	// An iteration too fast for the clock has primes but no duration
+	stats := newCPUStats(time.Second)
+	stats.Thread(0)
+	stats.Add(100, 0)
+	if rate := stats.TotalPrimesPerSec(8); rate != 0 {
+		t.Errorf("TotalPrimesPerSec() after a zero-duration iteration = %f, expected 0", rate)
+	}
+	for _, summary := range stats.ThreadSummaries() {
+		if summary.PerSec != 0 {
+			t.Errorf("thread %d PerSec without time = %f, expected 0", summary.Thread, summary.PerSec)
+		}
+	}
+}
+
+func TestDiskStatsRemainingBudget(t *testing.T) {
+	tests := []struct {
+		limit    int64
+		written  int64
+		expected int64
+	}{
+		{0, 0, -1},       // No limit
+		{0, 1 << 40, -1}, // No limit regardless of bytes written
+		{1000, 0, 1000},  // Full budget left
+		{1000, 400, 600}, // Partially used
+		{1000, 1000, 0},  // Exhausted
+		{1000, 1500, 0},  // Never negative
+	}
+
+	for _, test := range tests {
+		stats := &DiskStats{}
+		stats.bytesWritten.Store(test.written)
+		result := stats.remainingBudget(Config{diskTotalLimit: test.limit})
+		if result != test.expected {
+			t.Errorf("remainingBudget() with limit %d and %d written = %d, expected %d",
+				test.limit, test.written, result, test.expected)
+		}
+	}
+}
+
+func TestDiskStatsReset(t *testing.T) {
+	stats := &DiskStats{}
+	stats.bytesWritten.Store(400)
+	stats.bytesRead.Store(300)
+	stats.iterations.Store(2)
+	stats.verifyMismatches.Store(3)
+
+	seen := stats.resets.Load()
+	stats.Reset()
+	if stats.bytesWritten.Load() != 0 || stats.bytesRead.Load() != 0 || stats.iterations.Load() != 0 {
+		t.Errorf("Reset() left written %d, read %d, iterations %d, expected all 0",
+			stats.bytesWritten.Load(), stats.bytesRead.Load(), stats.iterations.Load())
+	}
+	if result := stats.verifyMismatches.Load(); result != 3 {
+		t.Errorf("Reset() left %d checksum mismatches, expected the 3 found before it", result)
+	}
+	// Bytes written before the reset still count toward the limit
+	if result := stats.remainingBudget(Config{diskTotalLimit: 1000}); result != 600 {
+		t.Errorf("remainingBudget() after Reset() = %d, expected 600", result)
+	}
+	// The lifetime totals behind the OpenMetrics counters never go down
+	stats.bytesWritten.Add(50)
+	if written, read := stats.LifetimeBytes(); written != 450 || read != 300 {
+		t.Errorf("LifetimeBytes() after Reset() = %d, %d, expected 450, 300", written, read)
+	}
+	if !stats.resetSince(&seen) {
+		t.Error("resetSince() after Reset() = false, expected true")
+	}
+	if stats.resetSince(&seen) {
+		t.Error("resetSince() without another Reset() = true, expected false")
+	}
+}
+
+func TestCPUStatsReset(t *testing.T) {
+	stats := newCPUStats(time.Second)
+	linked := newCPUStats(time.Second)
+	stats.Link(linked)
+	stats.Add(100, time.Second)
+	linked.Add(50, time.Second)
+
+	stats.Reset()
+	if stats.TotalPrimesPerSec(1) != 0 || linked.TotalPrimesPerSec(1) != 0 {
+		t.Errorf("Reset() left rates %f and %f, expected 0",
+			stats.TotalPrimesPerSec(1), linked.TotalPrimesPerSec(1))
+	}
+	stats.Add(20, time.Second)
+	if result := stats.LifetimePrimes(); result != 120 {
+		t.Errorf("LifetimePrimes() after Reset() = %d, expected 120", result)
+	}
+}
+
+// wrappedEOFReader returns its data and a wrapped io.EOF in the same call
+type wrappedEOFReader struct {
+	data []byte
+}
+
+func (r *wrappedEOFReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		return n, fmt.Errorf("reading block: %w", io.EOF)
+	}
+	return n, nil
+}
+
+func TestReadToEOF(t *testing.T) {
+	data := strings.Repeat("x", 10000)
+	buffer := make([]byte, 4096)
+	stopChan := make(chan struct{})
+
+	readers := map[string]io.Reader{
+		"plain":           strings.NewReader(data),
+		"data with EOF":   iotest.DataErrReader(strings.NewReader(data)),
+		"wrapped EOF":     &wrappedEOFReader{data: []byte(data)},
+		"one byte a time": iotest.OneByteReader(strings.NewReader(data)),
+	}
+	for name, reader := range readers {
+		total, stopped, err := readToEOF(reader, buffer, stopChan)
+		if err != nil || stopped || total != int64(len(data)) {
+			t.Errorf("readToEOF(%s) = %d, %v, %v, expected %d bytes", name, total, stopped, err, len(data))
+		}
+	}
+
+	// Other errors are reported with the bytes read before them
+	total, _, err := readToEOF(iotest.TimeoutReader(strings.NewReader(data)), buffer, stopChan)
+	if !errors.Is(err, iotest.ErrTimeout) || total != int64(len(buffer)) {
+		t.Errorf("readToEOF() with a failing reader = %d, %v, expected %d bytes and ErrTimeout", total, err, len(buffer))
+	}
+
+	close(stopChan)
+	if _, stopped, _ := readToEOF(strings.NewReader(data), buffer, stopChan); !stopped {
+		t.Errorf("readToEOF() should stop once stopChan is closed")
+	}
+}
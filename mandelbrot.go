@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Largest -mandelbrot-size, a grid of 100 million pixels per iteration
+const maxMandelbrotSize = 10000
+
+// Iterations after which a point counts as inside the set
+const mandelbrotMaxIter = 256
+
+// The grid covers the whole set: -2..1 on the real axis, -1.5..1.5 on the
+// imaginary one
+const (
+	mandelbrotMinX  = -2.0
+	mandelbrotMinY  = -1.5
+	mandelbrotWidth = 3.0
+)
+
+// Size of the picture printed with -full
+const (
+	mandelbrotASCIIWidth  = 64
+	mandelbrotASCIIHeight = 24
+)
+
+// mandelbrotEscape iterates z = z² + c from 0 and returns the number of
+// iterations until |z| exceeds 2, or maxIter if it never does
+func mandelbrotEscape(cx, cy float64, maxIter int) int {
+	x, y := 0.0, 0.0
+	for i := 0; i < maxIter; i++ {
+		x, y = x*x-y*y+cx, 2*x*y+cy
+		if x*x+y*y > 4 {
+			return i + 1
+		}
+	}
+	return maxIter
+}
+
+// mandelbrotPoint maps pixel (col, row) of a width x height grid to the
+// complex plane
+func mandelbrotPoint(col, row, width, height int) (float64, float64) {
+	return mandelbrotMinX + mandelbrotWidth*(float64(col)+0.5)/float64(width),
+		mandelbrotMinY + mandelbrotWidth*(float64(row)+0.5)/float64(height)
+}
+
+// mandelbrotASCII renders the set as text, '#' for points inside it and
+// lighter characters for points that escape sooner
+func mandelbrotASCII(width, height int) string {
+	const shades = " .:-=+*%"
+	var picture strings.Builder
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			cx, cy := mandelbrotPoint(col, row, width, height)
+			iterations := mandelbrotEscape(cx, cy, mandelbrotMaxIter)
+			switch {
+			case iterations == mandelbrotMaxIter:
+				picture.WriteByte('#')
+			case iterations >= len(shades):
+				picture.WriteByte(shades[len(shades)-1])
+			default:
+				picture.WriteByte(shades[iterations])
+			}
+		}
+		picture.WriteByte('\n')
+	}
+	return picture.String()
+}
+
+// newMandelbrotIteration computes the escape time of every pixel of a
+// -mandelbrot-size square grid, counting each pixel as an operation. Points
+// inside the set take all iterations and the rest escape after a few, so the
+// floating point work and the loop exit branch vary from pixel to pixel.
+func newMandelbrotIteration(threadID int, config Config) func() int {
+	if threadID == 0 && config.full {
+		fmt.Print(mandelbrotASCII(mandelbrotASCIIWidth, mandelbrotASCIIHeight))
+	}
+	size := config.mandelbrotSize
+	total := 0
+	return func() int {
+		for row := 0; row < size; row++ {
+			for col := 0; col < size; col++ {
+				cx, cy := mandelbrotPoint(col, row, size, size)
+				// Keeping the total uses every result
+				total += mandelbrotEscape(cx, cy, mandelbrotMaxIter)
+			}
+		}
+		return size * size
+	}
+}
+
+func formatMandelbrotRate(pixelsPerSec float64, config Config) string {
+	return fmt.Sprintf("%s pixels/sec", formatWithCommas(pixelsPerSec))
+}
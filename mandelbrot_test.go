@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMandelbrotEscape(t *testing.T) {
+	tests := []struct {
+		cx, cy     float64
+		iterations int
+	}{
+		// Inside the set, never escaping
+		{0, 0, mandelbrotMaxIter},
+		{-1, 0, mandelbrotMaxIter},
+		{-0.5, 0.5, mandelbrotMaxIter},
+		// On the boundary |z| stays at 2 without exceeding it
+		{-2, 0, mandelbrotMaxIter},
+		// z = 1, 2, 5
+		{1, 0, 3},
+		// |c| alone is already beyond 2
+		{2, 2, 1},
+		{0, 3, 1},
+	}
+	for _, test := range tests {
+		if iterations := mandelbrotEscape(test.cx, test.cy, mandelbrotMaxIter); iterations != test.iterations {
+			t.Errorf("mandelbrotEscape(%v, %v) = %d, expected %d", test.cx, test.cy, iterations, test.iterations)
+		}
+	}
+}
+
+func TestMandelbrotIteration(t *testing.T) {
+	if ops := newMandelbrotIteration(0, Config{mandelbrotSize: 32})(); ops != 32*32 {
+		t.Errorf("mandelbrot iteration counted %d pixels, expected %d", ops, 32*32)
+	}
+}
+
+func TestMandelbrotASCII(t *testing.T) {
+	picture := mandelbrotASCII(16, 8)
+	lines := strings.Split(strings.TrimSuffix(picture, "\n"), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("mandelbrotASCII(16, 8) has %d lines, expected 8", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) != 16 {
+			t.Errorf("mandelbrotASCII(16, 8) line %q has %d characters, expected 16", line, len(line))
+		}
+	}
+	if !strings.Contains(picture, "#") {
+		t.Errorf("mandelbrotASCII(16, 8) shows no point inside the set:\n%s", picture)
+	}
+}
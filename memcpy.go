@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+// newMemcpyIteration copies one per-thread buffer into another, STREAM-copy
+// style. Each iteration counts the bytes read plus the bytes written, so the
+// rate is the memory traffic the thread generated.
+func newMemcpyIteration(threadID int, config Config) func() int {
+	src := make([]byte, config.memcpyBuffer)
+	dst := make([]byte, config.memcpyBuffer)
+	// Touch the source so its pages are backed by real memory
+	fillChunk(src)
+	return func() int {
+		return 2 * copy(dst, src)
+	}
+}
+
+func formatBandwidth(bytesPerSec float64, config Config) string {
+	return fmt.Sprintf("%s/s", formatBytes(int64(bytesPerSec), config.units))
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestMemcpyIteration(t *testing.T) {
+	iterate := newMemcpyIteration(0, Config{memcpyBuffer: 4096})
+	if bytes := iterate(); bytes != 2*4096 {
+		t.Errorf("memcpy iteration counted %d bytes, expected read plus write of %d", bytes, 2*4096)
+	}
+}
+
+func TestOpsWorkloadRate(t *testing.T) {
+	config := Config{units: "binary"}
+	if rate := opsWorkloads["memcpy"].rate(2*1024*1024*1024, config); rate != "2.00 GiB/s" {
+		t.Errorf("memcpy rate = %q, expected \"2.00 GiB/s\"", rate)
+	}
+	if rate := opsWorkloads["branchy"].rate(1500000, config); rate != "1,500,000 ops/sec" {
+		t.Errorf("branchy rate = %q, expected \"1,500,000 ops/sec\"", rate)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// The allocation is filled with byte(i % 256), repeating every 256 bytes
+var fillPattern = func() []byte {
+	pattern := make([]byte, 256)
+	for i := range pattern {
+		pattern[i] = byte(i)
+	}
+	return pattern
+}()
+
+func fillChunk(chunk []byte) {
+	for i := range chunk {
+		chunk[i] = byte(i % 256)
+	}
+}
+
+// countPatternMismatches returns how many bytes of chunk differ from the fill
+// pattern, comparing whole pattern windows first so a clean pass runs at
+// memory speed.
+func countPatternMismatches(chunk []byte) int {
+	mismatches := 0
+	for offset := 0; offset < len(chunk); offset += len(fillPattern) {
+		end := offset + len(fillPattern)
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+		window := chunk[offset:end]
+		if bytes.Equal(window, fillPattern[:len(window)]) {
+			continue
+		}
+		for i, b := range window {
+			if b != fillPattern[i] {
+				mismatches++
+			}
+		}
+	}
+	return mismatches
+}
+
+// verifyMemory reads back every chunk, reporting the read bandwidth and the
+// number of bytes that no longer match the fill pattern.
+func verifyMemory(memoryChunks [][]byte, config Config, metrics *Metrics) int64 {
+	start := time.Now()
+	verified := int64(0)
+	mismatches := int64(0)
+	for _, chunk := range memoryChunks {
+		mismatches += int64(countPatternMismatches(chunk))
+		verified += int64(len(chunk))
+	}
+
+	verifyMBps := mbPerSecond(verified, time.Since(start))
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.MemoryVerifyMBps = verifyMBps
+		snapshot.MemoryMismatches = &mismatches
+	})
+	fmt.Printf("Memory: verify read %s, %d mismatches\n", formatGBps(verifyMBps, config.units), mismatches)
+	return mismatches
+}
+
+// benchmarkMemoryFill refills the allocation pass after pass until stopChan
+// closes, for the memory phase of -sequential. The fill rate of the
+// allocation includes page faults, so the average of the refills replaces it.
+func benchmarkMemoryFill(memoryChunks [][]byte, stopChan <-chan struct{}, config Config, metrics *Metrics) {
+	if len(memoryChunks) == 0 {
+		return
+	}
+	filled := int64(0)
+	elapsed := time.Duration(0)
+	lastReport := time.Now()
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+
+	for pass := 1; ; pass++ {
+		for _, chunk := range memoryChunks {
+			select {
+			case <-stopChan:
+				if config.full {
+					fmt.Printf("Memory: Completed %d fill passes\n", pass-1)
+				}
+				return
+			default:
+			}
+			start := time.Now()
+			fillChunk(chunk)
+			elapsed += time.Since(start)
+			filled += int64(len(chunk))
+		}
+
+		fillMBps := mbPerSecond(filled, elapsed)
+		metrics.Update(func(snapshot *MetricsSnapshot) {
+			snapshot.MemoryFillMBps = fillMBps
+		})
+		if benchmarkReports(config) && time.Since(lastReport) >= reportInterval {
+			fmt.Printf("Memory: fill %s over %d passes%s\n", formatMBps(fillMBps, config.units), pass, progressSuffix(metrics))
+			lastReport = time.Now()
+			reportInterval = nextReportInterval(reportInterval, config)
+		}
+	}
+}
+
+// How many chunks are allocated between two reads of the available memory
+const pressureCheckChunks = 4
+
+// memoryPressureGuard re-reads the available memory during the allocation,
+// since other processes may take memory after the target was computed.
+// A nil guard never reports pressure.
+type memoryPressureGuard struct {
+	readAvailable func() int64
+	watermark     int64
+}
+
+// newMemoryPressureGuard sets the watermark at half of the memory the
+// request meant to leave free. Only Linux reports a MemAvailable that
+// moves with other processes, so elsewhere there is no guard.
+func newMemoryPressureGuard(config Config, availableMemory int64) *memoryPressureGuard {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return &memoryPressureGuard{
+		readAvailable: func() int64 { return getAvailableMemory(config) },
+		watermark:     int64(float64(availableMemory) * (1 - config.memoryPercent) / 2),
+	}
+}
+
+// check reads the available memory every pressureCheckChunks chunks and
+// reports whether it has dropped below the watermark, and the amount read.
+func (g *memoryPressureGuard) check(chunks int) (bool, int64) {
+	if g == nil || chunks == 0 || chunks%pressureCheckChunks != 0 {
+		return false, 0
+	}
+	available := g.readAvailable()
+	return available < g.watermark, available
+}
+
+// chunkBytes converts -chunk-size to bytes in 64-bit math, which would
+// overflow int on 32-bit platforms for 2 GiB and more
+func chunkBytes(config Config) int64 {
+	return int64(config.chunkSizeMB) * 1024 * 1024
+}
+
+// checkChunkSize reports whether a chunk of chunkSizeMB MiB can be allocated
+// as a single slice of at most maxLen bytes, math.MaxInt on the running
+// platform
+func checkChunkSize(chunkSizeMB int, maxLen int64) error {
+	if chunkSizeMB < 1 {
+		return errors.New("chunk size must be at least 1 MiB")
+	}
+	if int64(chunkSizeMB) > maxLen/(1024*1024) {
+		return fmt.Errorf("chunk size of %d MiB is too large for this platform, at most %d MiB", chunkSizeMB, maxLen/(1024*1024))
+	}
+	return nil
+}
+
+// allocateChunks allocates and fills chunks until target bytes are reached
+// or the guard reports memory pressure. It returns false if stopChan closed
+// first.
+func allocateChunks(stopChan <-chan struct{}, config Config, target int64, allocator *chunkAllocator, guard *memoryPressureGuard) ([][]byte, int64, bool) {
+	var memoryChunks [][]byte
+	chunkSize := chunkBytes(config)
+	allocated := int64(0)
+
+	for allocated < target {
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("Memory: Stopping allocation at %s\n", formatBytes(allocated, config.units))
+			}
+			return nil, 0, false
+		default:
+		}
+
+		if pressure, available := guard.check(len(memoryChunks)); pressure {
+			fmt.Printf("Memory: Allocation cut short at %s due to memory pressure, only %s still available\n",
+				formatBytes(allocated, config.units), formatBytes(available, config.units))
+			break
+		}
+
+		chunk := allocator.alloc(nextChunkSize(target-allocated, chunkSize))
+		// Fill with a pattern to ensure actual allocation
+		fillChunk(chunk)
+		memoryChunks = append(memoryChunks, chunk)
+		allocated += int64(len(chunk))
+	}
+	return memoryChunks, allocated, true
+}
+
+// allocateFully is allocateChunks for -allocate-upfront: reaching less than
+// the target, because of memory pressure, a failed allocation or stopChan
+// closing, is an error
+func allocateFully(stopChan <-chan struct{}, config Config, target int64, allocator *chunkAllocator, guard *memoryPressureGuard) (chunks [][]byte, allocated int64, err error) {
+	defer func() {
+		// Oversized makes and failed mappings panic rather than return nil
+		if r := recover(); r != nil {
+			chunks, allocated, err = nil, 0, fmt.Errorf("allocation failed: %v", r)
+		}
+	}()
+
+	chunks, allocated, ok := allocateChunks(stopChan, config, target, allocator, guard)
+	if !ok {
+		return nil, 0, errors.New("interrupted")
+	}
+	if allocated < target {
+		return nil, 0, fmt.Errorf("only %s of %s could be allocated",
+			formatBytes(allocated, config.units), formatBytes(target, config.units))
+	}
+	return chunks, allocated, nil
+}
+
+// formatAllocation compares the requested allocation with what was actually
+// allocated and how much memory the system has left afterwards
+func formatAllocation(requested, allocated, availableAfter int64, units string) string {
+	text := fmt.Sprintf("requested %s, allocated %s, %s still available",
+		formatBytes(requested, units), formatBytes(allocated, units), formatBytes(availableAfter, units))
+	if allocated < requested {
+		text += fmt.Sprintf(" (%s short of the request)", formatBytes(requested-allocated, units))
+	}
+	return text
+}
+
+// chunkAllocator hands out memory chunks from the Go heap or, with -offheap,
+// from anonymous mappings that release() unmaps again
+type chunkAllocator struct {
+	offHeap bool
+	mapped  [][]byte
+}
+
+func newChunkAllocator(config Config) *chunkAllocator {
+	return &chunkAllocator{offHeap: config.offHeap}
+}
+
+// alloc falls back to the Go heap for good once a mapping fails
+func (a *chunkAllocator) alloc(size int) []byte {
+	if a.offHeap {
+		chunk, err := mmapChunk(size)
+		if err == nil {
+			a.mapped = append(a.mapped, chunk)
+			return chunk
+		}
+		fmt.Printf("Memory: Off-heap allocation failed, using the Go heap: %v\n", err)
+		a.offHeap = false
+	}
+	return make([]byte, size)
+}
+
+func (a *chunkAllocator) release() {
+	for _, chunk := range a.mapped {
+		if err := munmapChunk(chunk); err != nil {
+			fmt.Printf("Memory: Error unmapping off-heap chunk: %v\n", err)
+		}
+	}
+	a.mapped = nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "syscall"
+
+// mmapChunk maps anonymous memory outside the Go heap, so the garbage
+// collector neither scans it nor counts it towards its heap goal
+func mmapChunk(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func munmapChunk(chunk []byte) error {
+	return syscall.Munmap(chunk)
+}
+
+// sysinfoMemory asks the kernel for the total and free memory directly, for
+// sandboxes without /proc. sysinfo does not report the page cache, so the
+// free memory is only what is unused or in buffers.
+func sysinfoMemory() (total, free int64, err error) {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0, 0, err
+	}
+	unit := uint64(info.Unit)
+	if unit == 0 {
+		unit = 1
+	}
+	total = int64(uint64(info.Totalram) * unit)
+	free = int64((uint64(info.Freeram) + uint64(info.Bufferram)) * unit)
+	return total, free, nil
+}
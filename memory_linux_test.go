@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestChunkAllocatorOffHeap(t *testing.T) {
+	allocator := newChunkAllocator(Config{offHeap: true})
+	chunk := allocator.alloc(1024 * 1024)
+	if !allocator.offHeap || len(allocator.mapped) != 1 {
+		t.Fatalf("alloc() did not map the chunk off-heap")
+	}
+	if len(chunk) != 1024*1024 {
+		t.Fatalf("alloc() returned %d bytes, expected %d", len(chunk), 1024*1024)
+	}
+
+	// The mapping must be usable like any other chunk
+	fillChunk(chunk)
+	if mismatches := countPatternMismatches(chunk); mismatches != 0 {
+		t.Errorf("Off-heap chunk has %d mismatches after filling", mismatches)
+	}
+
+	allocator.release()
+	if len(allocator.mapped) != 0 {
+		t.Errorf("release() left %d chunks mapped", len(allocator.mapped))
+	}
+}
+
+func TestSysinfoMemory(t *testing.T) {
+	total, free, err := sysinfoMemory()
+	if err != nil {
+		t.Fatalf("sysinfoMemory() returned error: %v", err)
+	}
+	if total <= 0 || free <= 0 {
+		t.Errorf("sysinfoMemory() = %d total, %d free, expected positive values", total, free)
+	}
+	if free > total {
+		t.Errorf("sysinfoMemory() free %d exceeds total %d", free, total)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errOffHeapUnsupported = errors.New("off-heap buffers are only supported on Linux")
+
+func mmapChunk(size int) ([]byte, error) {
+	return nil, errOffHeapUnsupported
+}
+
+func munmapChunk(chunk []byte) error {
+	return errOffHeapUnsupported
+}
+
+// Only Linux has sysinfo; the other systems read their memory elsewhere
+func sysinfoMemory() (total, free int64, err error) {
+	return 0, 0, errors.New("sysinfo is only supported on Linux")
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountPatternMismatches(t *testing.T) {
+	for _, size := range []int{0, 100, 256, 1000, 4096} {
+		chunk := make([]byte, size)
+		fillChunk(chunk)
+		if mismatches := countPatternMismatches(chunk); mismatches != 0 {
+			t.Errorf("countPatternMismatches() on a clean %d-byte chunk = %d, expected 0", size, mismatches)
+		}
+	}
+
+	chunk := make([]byte, 1000)
+	fillChunk(chunk)
+	chunk[0] ^= 0x01
+	chunk[300] ^= 0x80
+	chunk[301] ^= 0xff
+	chunk[999] ^= 0x10
+	if mismatches := countPatternMismatches(chunk); mismatches != 4 {
+		t.Errorf("countPatternMismatches() with 4 corrupted bytes = %d, expected 4", mismatches)
+	}
+}
+
+func TestVerifyMemory(t *testing.T) {
+	chunks := [][]byte{make([]byte, 4096), make([]byte, 4096)}
+	for _, chunk := range chunks {
+		fillChunk(chunk)
+	}
+	chunks[1][17] = 0
+
+	metrics := &Metrics{}
+	if mismatches := verifyMemory(chunks, Config{}, metrics); mismatches != 1 {
+		t.Errorf("verifyMemory() = %d mismatches, expected 1", mismatches)
+	}
+
+	snapshot := metrics.Snapshot()
+	if snapshot.MemoryMismatches == nil || *snapshot.MemoryMismatches != 1 || snapshot.MemoryVerifyMBps <= 0 {
+		t.Errorf("verifyMemory() metrics = %+v, expected 1 mismatch and a positive bandwidth", snapshot)
+	}
+}
+
+func TestBenchmarkMemoryFill(t *testing.T) {
+	chunks := [][]byte{make([]byte, 4096), make([]byte, 4096)}
+	chunks[0][5] = 0xff
+	metrics := &Metrics{}
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		benchmarkMemoryFill(chunks, stopChan, Config{format: "none", reportInterval: 1}, metrics)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(stopChan)
+	<-done
+
+	if fillMBps := metrics.Snapshot().MemoryFillMBps; fillMBps <= 0 {
+		t.Errorf("benchmarkMemoryFill() fill rate = %f, expected a positive rate", fillMBps)
+	}
+	if mismatches := countPatternMismatches(chunks[0]); mismatches != 0 {
+		t.Errorf("benchmarkMemoryFill() left %d mismatches, expected the fill pattern", mismatches)
+	}
+
+	// Without an allocation there is nothing to fill
+	benchmarkMemoryFill(nil, stopChan, Config{}, metrics)
+}
+
+func TestVerifyMemoryCleanInJSON(t *testing.T) {
+	chunk := make([]byte, 4096)
+	fillChunk(chunk)
+	metrics := &Metrics{}
+	verifyMemory([][]byte{chunk}, Config{}, metrics)
+
+	// A clean verification differs from none at all
+	data, err := json.Marshal(metrics.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"memory_mismatches":0`) {
+		t.Errorf("snapshot after a clean verify = %s, expected \"memory_mismatches\":0", data)
+	}
+	data, err = json.Marshal(MetricsSnapshot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "memory_mismatches") {
+		t.Errorf("snapshot without a verify = %s, expected no memory_mismatches", data)
+	}
+}
+
+func TestMemoryAvailableInJSON(t *testing.T) {
+	// A system with nothing left is exactly the case to report
+	available := int64(0)
+	data, err := json.Marshal(MetricsSnapshot{MemoryAvailable: &available})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"memory_available_after_bytes":0`) {
+		t.Errorf("snapshot with no memory left = %s, expected \"memory_available_after_bytes\":0", data)
+	}
+	data, err = json.Marshal(MetricsSnapshot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "memory_available_after_bytes") {
+		t.Errorf("snapshot before allocating = %s, expected no memory_available_after_bytes", data)
+	}
+}
+
+func TestFormatAllocation(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	tests := []struct {
+		requested, allocated, available int64
+		expected                        string
+	}{
+		{9 * gib, 9 * gib, gib, "requested 9.00 GiB, allocated 9.00 GiB, 1.00 GiB still available"},
+		{9 * gib, 8 * gib, 0, "requested 9.00 GiB, allocated 8.00 GiB, 0 B still available (1.00 GiB short of the request)"},
+	}
+
+	for _, test := range tests {
+		if result := formatAllocation(test.requested, test.allocated, test.available, "binary"); result != test.expected {
+			t.Errorf("formatAllocation(%d, %d, %d) = %q, expected %q", test.requested, test.allocated, test.available, result, test.expected)
+		}
+	}
+}
+
+func TestAllocateChunksStopsUnderPressure(t *testing.T) {
+	// Other processes take memory while the allocation runs
+	available := int64(1000)
+	reads := 0
+	guard := &memoryPressureGuard{
+		readAvailable: func() int64 {
+			reads++
+			available -= 400
+			return available
+		},
+		watermark: 300,
+	}
+
+	config := Config{chunkSizeMB: 1}
+	target := int64(20 * 1024 * 1024)
+	chunks, allocated, ok := allocateChunks(make(chan struct{}), config, target, newChunkAllocator(config), guard)
+	if !ok {
+		t.Fatalf("allocateChunks() reported a stop")
+	}
+
+	// 600 is still above the watermark, 200 is below it
+	if reads != 2 || len(chunks) != 2*pressureCheckChunks {
+		t.Errorf("allocateChunks() read the available memory %d times and allocated %d chunks, expected 2 and %d",
+			reads, len(chunks), 2*pressureCheckChunks)
+	}
+	if allocated != int64(len(chunks))*1024*1024 {
+		t.Errorf("allocateChunks() allocated %d bytes for %d chunks", allocated, len(chunks))
+	}
+}
+
+func TestAllocateChunksWithoutGuard(t *testing.T) {
+	config := Config{chunkSizeMB: 1}
+	target := int64(5*1024*1024 + 100)
+	chunks, allocated, ok := allocateChunks(make(chan struct{}), config, target, newChunkAllocator(config), nil)
+	if !ok || allocated != target || len(chunks) != 6 {
+		t.Errorf("allocateChunks() = %d chunks, %d bytes, %v; expected 6 chunks and %d bytes", len(chunks), allocated, ok, target)
+	}
+}
+
+func TestChunkBytes(t *testing.T) {
+	// 4 GiB overflows a 32-bit int, but not the int64 math
+	if result := chunkBytes(Config{chunkSizeMB: 4096}); result != 4<<30 {
+		t.Errorf("chunkBytes(4096 MiB) = %d, expected %d", result, int64(4<<30))
+	}
+}
+
+func TestCheckChunkSize(t *testing.T) {
+	const maxInt32 = 1<<31 - 1
+	tests := []struct {
+		chunkSizeMB int
+		maxLen      int64
+		valid       bool
+	}{
+		{100, maxInt32, true},
+		{2047, maxInt32, true},
+		// 2 GiB is one byte more than a 32-bit slice can hold
+		{2048, maxInt32, false},
+		{4096, maxInt32, false},
+		{4096, 1<<63 - 1, true},
+		{0, maxInt32, false},
+		{-1, 1<<63 - 1, false},
+		// Would overflow the int of any platform if multiplied out
+		{math.MaxInt, math.MaxInt, false},
+	}
+
+	for _, test := range tests {
+		err := checkChunkSize(test.chunkSizeMB, test.maxLen)
+		if (err == nil) != test.valid {
+			t.Errorf("checkChunkSize(%d, %d) = %v, expected valid %v", test.chunkSizeMB, test.maxLen, err, test.valid)
+		}
+	}
+}
+
+func TestAllocateFullyShort(t *testing.T) {
+	// A memory source that runs low as soon as it is read
+	guard := &memoryPressureGuard{
+		readAvailable: func() int64 { return 100 },
+		watermark:     300,
+	}
+
+	config := Config{chunkSizeMB: 1}
+	target := int64(2 * pressureCheckChunks * 1024 * 1024)
+	chunks, allocated, err := allocateFully(make(chan struct{}), config, target, newChunkAllocator(config), guard)
+	if err == nil {
+		t.Fatal("allocateFully() under memory pressure returned no error")
+	}
+	if chunks != nil || allocated != 0 {
+		t.Errorf("allocateFully() kept %d chunks and %d bytes after failing", len(chunks), allocated)
+	}
+
+	chunks, allocated, err = allocateFully(make(chan struct{}), config, target, newChunkAllocator(config), nil)
+	if err != nil || allocated != target || len(chunks) != 2*pressureCheckChunks {
+		t.Errorf("allocateFully() without pressure = %d chunks, %d bytes, %v; expected %d bytes", len(chunks), allocated, err, target)
+	}
+
+	// An interrupt stops the allocation and fails it
+	stopChan := make(chan struct{})
+	close(stopChan)
+	chunks, allocated, err = allocateFully(stopChan, config, target, newChunkAllocator(config), nil)
+	if err == nil || chunks != nil || allocated != 0 {
+		t.Errorf("allocateFully() after a stop = %d chunks, %d bytes, %v; expected an error", len(chunks), allocated, err)
+	}
+}
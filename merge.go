@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// mergedHost is one summary read by -merge, with its metrics flattened to
+// their JSON names
+type mergedHost struct {
+	host    string
+	metrics map[string]float64
+}
+
+// metricStats aggregates one metric across the hosts that reported it
+type metricStats struct {
+	Hosts  int
+	Mean   float64
+	Min    float64
+	Max    float64
+	StdDev float64
+}
+
+// mergeInputs expands the -merge arguments: files are taken as given, and a
+// directory stands for the .json files in it
+func mergeInputs(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no summary files given")
+	}
+	return paths, nil
+}
+
+// loadSummary reads a JSON summary as written by -format json
+func loadSummary(path string) (Summary, error) {
+	var summary Summary
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return summary, err
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return summary, err
+	}
+	return summary, checkSchemaVersion(summary.SchemaVersion)
+}
+
+// flattenMetrics returns every numeric metric of the snapshot under its JSON
+// name. Per-workload and per-pattern rates become "map.key". Metrics the
+// host did not report are left out rather than counted as 0.
+func flattenMetrics(snapshot MetricsSnapshot) (map[string]float64, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]float64)
+	for name, value := range fields {
+		switch value := value.(type) {
+		case float64:
+			metrics[name] = value
+		case map[string]interface{}:
+			for key, nested := range value {
+				if number, ok := nested.(float64); ok {
+					metrics[name+"."+key] = number
+				}
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// aggregateMetrics computes the statistics of every metric across hosts,
+// with the population standard deviation
+func aggregateMetrics(hosts []mergedHost) map[string]metricStats {
+	values := make(map[string][]float64)
+	for _, host := range hosts {
+		for name, value := range host.metrics {
+			values[name] = append(values[name], value)
+		}
+	}
+
+	stats := make(map[string]metricStats, len(values))
+	for name, samples := range values {
+		s := metricStats{Hosts: len(samples), Min: samples[0], Max: samples[0]}
+		sum := 0.0
+		for _, v := range samples {
+			sum += v
+			s.Min = math.Min(s.Min, v)
+			s.Max = math.Max(s.Max, v)
+		}
+		s.Mean = sum / float64(len(samples))
+		variance := 0.0
+		for _, v := range samples {
+			variance += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(variance / float64(len(samples)))
+		stats[name] = s
+	}
+	return stats
+}
+
+// rankHosts orders the hosts that reported metric from the highest value
+// to the lowest
+func rankHosts(hosts []mergedHost, metric string) []mergedHost {
+	var ranked []mergedHost
+	for _, host := range hosts {
+		if _, ok := host.metrics[metric]; ok {
+			ranked = append(ranked, host)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].metrics[metric] > ranked[j].metrics[metric]
+	})
+	return ranked
+}
+
+// printMerge prints the statistics of every metric and the hosts ranked by
+// rankMetric
+func printMerge(w io.Writer, hosts []mergedHost, rankMetric string) {
+	stats := aggregateMetrics(hosts)
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Merged %d summaries\n\n", len(hosts))
+	fmt.Fprintf(w, "%-32s %5s %14s %14s %14s %14s\n", "Metric", "Hosts", "Mean", "Min", "Max", "StdDev")
+	for _, name := range names {
+		s := stats[name]
+		fmt.Fprintf(w, "%-32s %5d %14.2f %14.2f %14.2f %14.2f\n", name, s.Hosts, s.Mean, s.Min, s.Max, s.StdDev)
+	}
+
+	ranked := rankHosts(hosts, rankMetric)
+	if len(ranked) == 0 {
+		fmt.Fprintf(w, "\nNo summary has %s to rank by\n", rankMetric)
+		return
+	}
+	fmt.Fprintf(w, "\nRanked by %s:\n", rankMetric)
+	for i, host := range ranked {
+		fmt.Fprintf(w, "%3d. %-30s %14.2f\n", i+1, host.host, host.metrics[rankMetric])
+	}
+}
+
+// loadMergedHosts reads the summaries in paths
+func loadMergedHosts(paths []string) ([]mergedHost, error) {
+	hosts := make([]mergedHost, 0, len(paths))
+	for _, path := range paths {
+		summary, err := loadSummary(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		metrics, err := flattenMetrics(summary.Metrics)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// Summaries without a host label are told apart by their file
+		host := summary.Host
+		if host == "" {
+			host = filepath.Base(path)
+		}
+		hosts = append(hosts, mergedHost{host: host, metrics: metrics})
+	}
+	return hosts, nil
+}
+
+// runMerge loads the summaries named by args and prints their aggregate
+func runMerge(args []string, rankMetric string) error {
+	paths, err := mergeInputs(args)
+	if err != nil {
+		return err
+	}
+	hosts, err := loadMergedHosts(paths)
+	if err != nil {
+		return err
+	}
+	printMerge(os.Stdout, hosts, rankMetric)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSummary(t *testing.T, path string, summary Summary) {
+	t.Helper()
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeSummaries(t *testing.T) {
+	dir := t.TempDir()
+	writeSummary(t, filepath.Join(dir, "a.json"), Summary{
+		SchemaVersion: CurrentSchemaVersion,
+		Host:          "alpha",
+		Metrics: MetricsSnapshot{
+			CPUPrimesPerSec:  1000,
+			DiskWriteMBps:    200,
+			CPUWorkloadRates: map[string]float64{"prime": 1000, "sort": 50},
+		},
+	})
+	mismatches := int64(2)
+	writeSummary(t, filepath.Join(dir, "b.json"), Summary{
+		SchemaVersion: CurrentSchemaVersion,
+		Metrics: MetricsSnapshot{
+			CPUPrimesPerSec:  3000,
+			DiskWriteMBps:    100,
+			MemoryMismatches: &mismatches,
+		},
+	})
+	// Files that are not summaries are left out of a directory
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := mergeInputs([]string{dir})
+	if err != nil {
+		t.Fatalf("mergeInputs() returned error: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("mergeInputs() = %v, expected the two summaries", paths)
+	}
+	hosts, err := loadMergedHosts(paths)
+	if err != nil {
+		t.Fatalf("loadMergedHosts() returned error: %v", err)
+	}
+	if hosts[1].host != "b.json" {
+		t.Errorf("host without a name = %q, expected b.json", hosts[1].host)
+	}
+
+	stats := aggregateMetrics(hosts)
+	tests := []struct {
+		metric   string
+		expected metricStats
+	}{
+		{"cpu_primes_per_sec", metricStats{Hosts: 2, Mean: 2000, Min: 1000, Max: 3000, StdDev: 1000}},
+		{"disk_write_mbps", metricStats{Hosts: 2, Mean: 150, Min: 100, Max: 200, StdDev: 50}},
+		{"cpu_workload_rates.sort", metricStats{Hosts: 1, Mean: 50, Min: 50, Max: 50}},
+		{"memory_mismatches", metricStats{Hosts: 1, Mean: 2, Min: 2, Max: 2}},
+	}
+	for _, test := range tests {
+		result := stats[test.metric]
+		if result.Hosts != test.expected.Hosts || result.Mean != test.expected.Mean || result.Min != test.expected.Min ||
+			result.Max != test.expected.Max || math.Abs(result.StdDev-test.expected.StdDev) > 1e-9 {
+			t.Errorf("aggregateMetrics()[%q] = %+v, expected %+v", test.metric, result, test.expected)
+		}
+	}
+
+	ranked := rankHosts(hosts, "disk_write_mbps")
+	if len(ranked) != 2 || ranked[0].host != "alpha" {
+		t.Errorf("rankHosts() by disk_write_mbps starts with %q, expected alpha", ranked[0].host)
+	}
+
+	var out bytes.Buffer
+	printMerge(&out, hosts, "cpu_primes_per_sec")
+	for _, expected := range []string{"Merged 2 summaries", "Ranked by cpu_primes_per_sec", "  1. b.json", "  2. alpha"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("printMerge() output is missing %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestMergeInputsErrors(t *testing.T) {
+	if _, err := mergeInputs(nil); err == nil {
+		t.Error("mergeInputs() without arguments returned no error")
+	}
+	if _, err := mergeInputs([]string{t.TempDir()}); err == nil {
+		t.Error("mergeInputs() of an empty directory returned no error")
+	}
+
+	path := filepath.Join(t.TempDir(), "future.json")
+	writeSummary(t, path, Summary{SchemaVersion: 99})
+	if _, err := loadMergedHosts([]string{path}); err == nil {
+		t.Error("loadMergedHosts() of a newer schema returned no error")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MetricsSnapshot struct {
+	CPUPrimesPerSec    float64 `json:"cpu_primes_per_sec"`
+	CPUOpsPerSec       float64 `json:"cpu_ops_per_sec,omitempty"`
+	CPUJitterP99Micros float64 `json:"cpu_jitter_p99_us,omitempty"`
+	CPUJitterMaxMicros float64 `json:"cpu_jitter_max_us,omitempty"`
+	CPUExecFailures    int64   `json:"cpu_exec_failures,omitempty"`
+	MemoryFillMBps     float64 `json:"memory_fill_mbps"`
+	MemoryRequested    int64   `json:"memory_requested_bytes,omitempty"`
+	MemoryAllocated    int64   `json:"memory_allocated_bytes,omitempty"`
+	MemoryAvailable    *int64  `json:"memory_available_after_bytes,omitempty"`
+	MemoryVerifyMBps   float64 `json:"memory_verify_mbps,omitempty"`
+	MemoryMismatches   *int64  `json:"memory_mismatches,omitempty"`
+	MemoryScrubScans   int64   `json:"memory_scrub_scans,omitempty"`
+	MemoryBitErrors    int64   `json:"memory_bit_errors,omitempty"`
+	DiskWriteMBps      float64 `json:"disk_write_mbps"`
+	DiskReadMBps       float64 `json:"disk_read_mbps"`
+	DiskBytesWritten   int64   `json:"disk_bytes_written,omitempty"`
+	DiskBytesRead      int64   `json:"disk_bytes_read,omitempty"`
+	CPUPrimesFound     int64   `json:"cpu_primes_found,omitempty"`
+	IdleTempCelsius    float64 `json:"idle_temp_celsius,omitempty"`
+	IdleFreqMHz        float64 `json:"idle_freq_mhz,omitempty"`
+	DiskFsyncAvgMs     float64 `json:"disk_fsync_avg_ms,omitempty"`
+	DiskFsyncP99Ms     float64 `json:"disk_fsync_p99_ms,omitempty"`
+	CPUStealPercent    float64 `json:"cpu_steal_percent,omitempty"`
+	CPUStealMaxPercent float64 `json:"cpu_steal_max_percent,omitempty"`
+	DiskSyncWriteP50Ms float64 `json:"disk_sync_write_p50_ms,omitempty"`
+	DiskSyncWriteP99Ms float64 `json:"disk_sync_write_p99_ms,omitempty"`
+
+	// Per workload when several rotate, each in the unit of its own rate
+	CPUWorkloadRates map[string]float64 `json:"cpu_workload_rates,omitempty"`
+	// Average disk write MiB/s of each -disk-compare-patterns pattern
+	DiskPatternRates map[string]float64 `json:"disk_pattern_write_mbps,omitempty"`
+}
+
+// Metrics holds the latest value of every reported metric for exporters
+type Metrics struct {
+	mu       sync.RWMutex
+	snapshot MetricsSnapshot
+
+	// When the -duration timer started and how long it runs, for the
+	// progress in the reports
+	clockStart    time.Time
+	clockDuration time.Duration
+}
+
+// StartClock records that the run ends after duration from now. Without a
+// duration the reports show no progress.
+func (m *Metrics) StartClock(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clockStart, m.clockDuration = time.Now(), duration
+}
+
+// Progress returns how far the run has come, and false if it has no
+// -duration or has not started its timer
+func (m *Metrics) Progress() (runProgress, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.clockDuration <= 0 {
+		return runProgress{}, false
+	}
+	return newRunProgress(time.Since(m.clockStart), m.clockDuration), true
+}
+
+func (m *Metrics) Update(update func(snapshot *MetricsSnapshot)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	update(&m.snapshot)
+}
+
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot
+}
+
+// withCounters fills the snapshot's cumulative counters from the lifetime
+// totals of the stats, which a SIGUSR2 reset leaves alone, so the exported
+// counters never go down. Ops workloads count operations, not primes, so
+// their total is left out.
+func withCounters(snapshot MetricsSnapshot, config Config, cpuStats *CPUStats, diskStats *DiskStats) MetricsSnapshot {
+	if hasCPUWorkload(config, "prime") {
+		snapshot.CPUPrimesFound = cpuStats.LifetimePrimes()
+	}
+	snapshot.DiskBytesWritten, snapshot.DiskBytesRead = diskStats.LifetimeBytes()
+	return snapshot
+}
+
+func writeOpenMetrics(w io.Writer, snapshot MetricsSnapshot, config Config) error {
+	type gauge struct {
+		name  string
+		help  string
+		value float64
+	}
+
+	var gauges []gauge
+	if !config.disableCPU && rotatingWorkloads(config) {
+		if hasCPUWorkload(config, "prime") {
+			gauges = append(gauges, gauge{"perftest_cpu_primes_per_second", "Primes found per second across all CPU threads.", snapshot.CPUPrimesPerSec})
+		}
+	} else if !config.disableCPU && config.cpuWorkload == "idle-spin" {
+		gauges = append(gauges,
+			gauge{"perftest_cpu_jitter_p99_seconds", "99th percentile scheduler wakeup latency in the last interval.", snapshot.CPUJitterP99Micros / 1e6},
+			gauge{"perftest_cpu_jitter_max_seconds", "Maximum scheduler wakeup latency in the last interval.", snapshot.CPUJitterMaxMicros / 1e6},
+		)
+	} else if _, ok := opsWorkloads[config.cpuWorkload]; ok && !config.disableCPU {
+		gauges = append(gauges, gauge{"perftest_cpu_ops_per_second", "Workload operations per second across all CPU threads.", snapshot.CPUOpsPerSec})
+	} else if !config.disableCPU {
+		gauges = append(gauges, gauge{"perftest_cpu_primes_per_second", "Primes found per second across all CPU threads.", snapshot.CPUPrimesPerSec})
+	}
+	if !config.disableDisk {
+		gauges = append(gauges,
+			gauge{"perftest_memory_fill_bytes_per_second", "Rate at which the memory allocation was filled.", snapshot.MemoryFillMBps * 1024 * 1024},
+			gauge{"perftest_disk_write_bytes_per_second", "Average disk write throughput.", snapshot.DiskWriteMBps * 1024 * 1024},
+			gauge{"perftest_disk_read_bytes_per_second", "Average disk read throughput.", snapshot.DiskReadMBps * 1024 * 1024},
+		)
+	}
+	if !config.disableDisk && config.memScrub > 0 {
+		gauges = append(gauges, gauge{"perftest_memory_bit_errors", "Bit flips found by the memory scrub so far.", float64(snapshot.MemoryBitErrors)})
+	}
+
+	// Counters only grow, so Prometheus can derive rates itself with rate()
+	var counters []gauge
+	if !config.disableCPU && hasCPUWorkload(config, "prime") {
+		counters = append(counters, gauge{"perftest_cpu_primes_found", "Primes found by all CPU threads.", float64(snapshot.CPUPrimesFound)})
+	}
+	if !config.disableDisk && config.memScrub == 0 {
+		counters = append(counters,
+			gauge{"perftest_disk_bytes_written", "Bytes written by the disk test.", float64(snapshot.DiskBytesWritten)},
+			gauge{"perftest_disk_bytes_read", "Bytes read back by the disk test.", float64(snapshot.DiskBytesRead)},
+		)
+	}
+
+	labels := fmt.Sprintf(`host="%s"`, escapeLabelValue(config.hostLabel))
+	for _, key := range sortedTagKeys(config.tags) {
+		labels += fmt.Sprintf(`,%s="%s"`, key, escapeLabelValue(config.tags[key]))
+	}
+	labels = "{" + labels + "}"
+	for _, g := range gauges {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", g.name, g.help, g.name, g.name, labels, g.value)
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range counters {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s_total%s %g\n", c.name, c.help, c.name, c.name, labels, c.value)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "# EOF")
+	return err
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeOpenMetricsFile replaces path atomically so collectors never read a partial file
+func writeOpenMetricsFile(path string, snapshot MetricsSnapshot, config Config) error {
+	return writeFileAtomic(path, ".perf_test_metrics_*.tmp", func(w io.Writer) error {
+		return writeOpenMetrics(w, snapshot, config)
+	})
+}
+
+func openMetricsWriter(stopChan <-chan struct{}, config Config, metrics *Metrics, cpuStats *CPUStats, diskStats *DiskStats) {
+	ticker := time.NewTicker(time.Duration(config.reportInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			// Final write so the file reflects the end of the run
+			if err := writeOpenMetricsFile(config.openMetricsFile, withCounters(metrics.Snapshot(), config, cpuStats, diskStats), config); err != nil {
+				fmt.Printf("Metrics: Error writing OpenMetrics file: %v\n", err)
+			}
+			return
+		case <-ticker.C:
+			if err := writeOpenMetricsFile(config.openMetricsFile, withCounters(metrics.Snapshot(), config, cpuStats, diskStats), config); err != nil {
+				fmt.Printf("Metrics: Error writing OpenMetrics file: %v\n", err)
+			}
+		}
+	}
+}
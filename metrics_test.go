@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsUpdate(t *testing.T) {
+	metrics := &Metrics{}
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.CPUPrimesPerSec = 1000
+	})
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.DiskWriteMBps = 250
+	})
+
+	snapshot := metrics.Snapshot()
+	if snapshot.CPUPrimesPerSec != 1000 || snapshot.DiskWriteMBps != 250 {
+		t.Errorf("Snapshot() = %+v, expected CPUPrimesPerSec=1000 and DiskWriteMBps=250", snapshot)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	snapshot := MetricsSnapshot{CPUPrimesPerSec: 1500000, DiskWriteMBps: 2, DiskReadMBps: 4}
+
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, snapshot, Config{hostLabel: "db-01"}); err != nil {
+		t.Fatalf("writeOpenMetrics() returned error: %v", err)
+	}
+	output := buf.String()
+
+	expectedLines := []string{
+		"# TYPE perftest_cpu_primes_per_second gauge",
+		`perftest_cpu_primes_per_second{host="db-01"} 1.5e+06`,
+		`perftest_disk_write_bytes_per_second{host="db-01"} 2.097152e+06`,
+		`perftest_disk_read_bytes_per_second{host="db-01"} 4.194304e+06`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("writeOpenMetrics() output missing %q:\n%s", line, output)
+		}
+	}
+	if !strings.HasSuffix(output, "# EOF\n") {
+		t.Errorf("writeOpenMetrics() output must end with # EOF:\n%s", output)
+	}
+}
+
+func TestWriteOpenMetricsCounters(t *testing.T) {
+	cpuStats := newCPUStats(time.Second)
+	cpuStats.Add(78498, time.Second)
+	diskStats := &DiskStats{}
+	diskStats.bytesWritten.Add(3 * 1024 * 1024)
+	diskStats.bytesRead.Add(1024 * 1024)
+	config := Config{hostLabel: "db-01", cpuWorkload: "prime"}
+	snapshot := withCounters(MetricsSnapshot{}, config, cpuStats, diskStats)
+
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, snapshot, config); err != nil {
+		t.Fatalf("writeOpenMetrics() returned error: %v", err)
+	}
+	output := buf.String()
+
+	expectedLines := []string{
+		"# TYPE perftest_cpu_primes_found counter",
+		`perftest_cpu_primes_found_total{host="db-01"} 78498`,
+		"# TYPE perftest_disk_bytes_written counter",
+		`perftest_disk_bytes_written_total{host="db-01"} 3.145728e+06`,
+		`perftest_disk_bytes_read_total{host="db-01"} 1.048576e+06`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("writeOpenMetrics() output missing %q:\n%s", line, output)
+		}
+	}
+
+	// Ops workloads count operations, which are not primes
+	config.cpuWorkload = "branchy"
+	if snapshot := withCounters(MetricsSnapshot{}, config, cpuStats, diskStats); snapshot.CPUPrimesFound != 0 {
+		t.Errorf("withCounters() for branchy = %d primes, expected none", snapshot.CPUPrimesFound)
+	}
+}
+
+func TestWriteOpenMetricsSkipsDisabledSubsystems(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, MetricsSnapshot{}, Config{disableDisk: true}); err != nil {
+		t.Fatalf("writeOpenMetrics() returned error: %v", err)
+	}
+	if strings.Contains(buf.String(), "perftest_disk_") {
+		t.Errorf("writeOpenMetrics() included disk metrics with disk disabled:\n%s", buf.String())
+	}
+}
+
+func TestWriteOpenMetricsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "perftest.prom")
+
+	for i := 0; i < 2; i++ {
+		if err := writeOpenMetricsFile(path, MetricsSnapshot{CPUPrimesPerSec: float64(i)}, Config{}); err != nil {
+			t.Fatalf("writeOpenMetricsFile() returned error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), `perftest_cpu_primes_per_second{host=""} 1`+"\n") {
+		t.Errorf("Metrics file does not contain the latest snapshot:\n%s", data)
+	}
+
+	// Only the target file should remain, no leftover temp files
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Cannot list temp dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the metrics file in %s, found %d entries", dir, len(entries))
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"web-01", "web-01"},
+		{`rack "A"`, `rack \"A\"`},
+		{`C:\perf`, `C:\\perf`},
+		{"two\nlines", `two\nlines`},
+	}
+
+	for _, test := range tests {
+		result := escapeLabelValue(test.input)
+		if result != test.expected {
+			t.Errorf("escapeLabelValue(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output sits between the tool and the terminal for -tui and -output-file.
+// os.Stdout becomes a pipe, and every printed line goes to the terminal, or
+// the dashboard while it runs, and to the output file.
+type Output struct {
+	terminal *os.File
+	pipe     *os.File
+	readDone chan struct{}
+
+	mu          sync.Mutex
+	path        string
+	file        *os.File
+	dashboard   *Dashboard
+	timestampTZ string
+
+	// -output-max-size rolls the file over to path.1, path.2, ... once it
+	// would grow past maxSize, keeping maxFiles of them
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+// formatTimestamp formats t as RFC 3339 in UTC, or in the local time zone
+// for -timestamp-tz local
+func formatTimestamp(t time.Time, timestampTZ string) string {
+	if timestampTZ == "local" {
+		return t.Local().Format(time.RFC3339)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func startOutput() (*Output, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Output{terminal: os.Stdout, pipe: writer, readDone: make(chan struct{})}
+	os.Stdout = writer
+	go o.copyLines(reader)
+	return o, nil
+}
+
+func (o *Output) copyLines(reader *os.File) {
+	defer close(o.readDone)
+	defer reader.Close()
+
+	// Unlike a bufio.Scanner, ReadString has no line length limit, so a long
+	// line cannot stop the copying and leave the writers blocked on the pipe
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadString('\n')
+		if line != "" {
+			o.printLine(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(o.terminal, "Output: Error reading output: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// printLine shows one line of output and appends it to the output file
+func (o *Output) printLine(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.timestampTZ != "" {
+		line = formatTimestamp(time.Now(), o.timestampTZ) + " " + line
+	}
+	if o.dashboard != nil {
+		o.dashboard.addLine(line)
+	} else {
+		fmt.Fprintln(o.terminal, line)
+	}
+	if o.file != nil {
+		o.writeFileLine(line)
+	}
+}
+
+// OpenFile appends all further output to path
+func (o *Output) OpenFile(path string) error {
+	file, size, err := openOutputFile(path)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.path, o.file, o.size = path, file, size
+	return nil
+}
+
+// openOutputFile opens path for appending and returns its current size,
+// which counts toward -output-max-size
+func openOutputFile(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// SetMaxSize rolls the output file over once it would grow past maxSize
+// bytes, keeping maxFiles rolled files. A maxSize of 0 never rolls over.
+func (o *Output) SetMaxSize(maxSize int64, maxFiles int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.maxSize, o.maxFiles = maxSize, maxFiles
+}
+
+// writeFileLine appends line to the output file, rolling it over first if
+// the line would take it past the maximum size. A line longer than the
+// maximum still goes into a file of its own. The size is counted as lines are
+// written and only read from the file before rolling over, since logrotate's
+// copytruncate may have emptied it in the meantime.
+func (o *Output) writeFileLine(line string) {
+	length := int64(len(line) + 1)
+	if o.maxSize > 0 && o.size > 0 && o.size+length > o.maxSize {
+		if info, err := o.file.Stat(); err == nil {
+			o.size = info.Size()
+		}
+	}
+	if o.maxSize > 0 && o.size > 0 && o.size+length > o.maxSize {
+		if err := o.rotate(); err != nil {
+			fmt.Fprintf(o.terminal, "Output: Error rotating %s: %v\n", o.path, err)
+		}
+	}
+	n, _ := fmt.Fprintln(o.file, line)
+	o.size += int64(n)
+}
+
+// rotate rolls the output file over and continues in a fresh file at path
+func (o *Output) rotate() error {
+	o.file.Close()
+	rollErr := rollFiles(o.path, o.maxFiles)
+	// Even if rolling over failed, the output continues at path
+	file, size, err := openOutputFile(o.path)
+	if err != nil {
+		return err
+	}
+	o.file, o.size = file, size
+	return rollErr
+}
+
+// rollFiles renames path.N-1 to path.N down to path to path.1, so the
+// oldest of maxFiles rolled files is overwritten
+func rollFiles(path string, maxFiles int) error {
+	for i := maxFiles - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", path, i)
+		if err := os.Rename(older, fmt.Sprintf("%s.%d", path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// Reopen closes the output file and opens its path again, so after logrotate
+// moved the old file the output continues in a fresh one. The size is read
+// from the file again, which also covers a copytruncate.
+func (o *Output) Reopen() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+
+	file, size, err := openOutputFile(o.path)
+	if err != nil {
+		return err
+	}
+	o.file.Close()
+	o.file, o.size = file, size
+	return nil
+}
+
+// SetTimestamps prefixes every further line with its time in timestampTZ
+func (o *Output) SetTimestamps(timestampTZ string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.timestampTZ = timestampTZ
+}
+
+func (o *Output) SetDashboard(dashboard *Dashboard) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dashboard = dashboard
+}
+
+// StopDashboard restores the terminal's normal screen, and the printed lines
+// go to the terminal again
+func (o *Output) StopDashboard() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dashboard != nil {
+		o.dashboard.Close()
+		o.dashboard = nil
+	}
+}
+
+// Close flushes everything printed so far and restores os.Stdout
+func (o *Output) Close() {
+	o.StopDashboard()
+	os.Stdout = o.terminal
+	o.pipe.Close()
+	<-o.readDone
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+}
+
+// reopenOnSignal reopens the output file on every SIGUSR1 until stopChan closes
+func (o *Output) reopenOnSignal(stopChan <-chan struct{}) {
+	reopen := make(chan os.Signal, 1)
+	notifyReopen(reopen)
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-reopen:
+			if err := o.Reopen(); err != nil {
+				fmt.Printf("Output: Error reopening %s: %v\n", o.path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "perf.log")
+	rotated := filepath.Join(dir, "perf.log.1")
+
+	terminal, err := os.Create(filepath.Join(dir, "terminal.txt"))
+	if err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+	defer terminal.Close()
+	stdout := os.Stdout
+	os.Stdout = terminal
+	defer func() { os.Stdout = stdout }()
+
+	output, err := startOutput()
+	if err != nil {
+		t.Fatalf("startOutput() error: %v", err)
+	}
+	if err := output.OpenFile(path); err != nil {
+		output.Close()
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+
+	fmt.Println("before rotation")
+	// Reopen must not lose lines still in the pipe, so wait until the line arrived
+	waitForContent(t, path, "before rotation\n")
+	if err := os.Rename(path, rotated); err != nil {
+		output.Close()
+		t.Fatalf("Cannot rename: %v", err)
+	}
+	if err := output.Reopen(); err != nil {
+		output.Close()
+		t.Fatalf("Reopen() error: %v", err)
+	}
+	fmt.Println("after rotation")
+	output.Close()
+
+	for file, expected := range map[string]string{
+		rotated:         "before rotation\n",
+		path:            "after rotation\n",
+		terminal.Name(): "before rotation\nafter rotation\n",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Cannot read %s: %v", file, err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s contains %q, expected %q", filepath.Base(file), data, expected)
+		}
+	}
+}
+
+func TestOutputMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perf.log")
+	output := &Output{terminal: os.Stderr}
+	output.SetMaxSize(20, 2)
+	if err := output.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	// Each line takes 10 bytes with its newline, so every file holds two
+	for i := 1; i <= 7; i++ {
+		output.writeFileLine(fmt.Sprintf("line %04d", i))
+	}
+	output.file.Close()
+
+	for file, expected := range map[string]string{
+		path:        "line 0007\n",
+		path + ".1": "line 0005\nline 0006\n",
+		path + ".2": "line 0003\nline 0004\n",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Cannot read %s: %v", file, err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s contains %q, expected %q", filepath.Base(file), data, expected)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("perf.log.3 exists beyond -output-max-files 2: %v", err)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	stamp := time.Date(2024, 5, 1, 14, 30, 0, 0, zone)
+	if formatted := formatTimestamp(stamp, "utc"); formatted != "2024-05-01T12:30:00Z" {
+		t.Errorf("formatTimestamp(utc) = %q, expected 2024-05-01T12:30:00Z", formatted)
+	}
+
+	local := time.Local
+	time.Local = zone
+	defer func() { time.Local = local }()
+	if formatted := formatTimestamp(stamp.UTC(), "local"); formatted != "2024-05-01T14:30:00+02:00" {
+		t.Errorf("formatTimestamp(local) = %q, expected 2024-05-01T14:30:00+02:00", formatted)
+	}
+}
+
+func TestOutputTimestamps(t *testing.T) {
+	terminal, err := os.Create(filepath.Join(t.TempDir(), "terminal.txt"))
+	if err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+	defer terminal.Close()
+	stdout := os.Stdout
+	os.Stdout = terminal
+	defer func() { os.Stdout = stdout }()
+
+	output, err := startOutput()
+	if err != nil {
+		t.Fatalf("startOutput() error: %v", err)
+	}
+	output.SetTimestamps("utc")
+	fmt.Println("CPU: 100 total primes/sec")
+	output.Close()
+
+	data, err := os.ReadFile(terminal.Name())
+	if err != nil {
+		t.Fatalf("Cannot read %s: %v", terminal.Name(), err)
+	}
+	line := strings.TrimSuffix(string(data), "\n")
+	stamp, report, found := strings.Cut(line, " ")
+	if !found || report != "CPU: 100 total primes/sec" {
+		t.Fatalf("Output is %q, expected a timestamp and the report", line)
+	}
+	if parsed, err := time.Parse(time.RFC3339, stamp); err != nil || parsed.Location() != time.UTC {
+		t.Errorf("Timestamp %q is not RFC 3339 in UTC: %v", stamp, err)
+	}
+}
+
+func waitForContent(t *testing.T, path string, expected string) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if data, _ := os.ReadFile(path); string(data) == expected {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s never contained %q", path, expected)
+}
+
+func TestOutputLongLine(t *testing.T) {
+	terminal, err := os.Create(filepath.Join(t.TempDir(), "terminal.txt"))
+	if err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+	defer terminal.Close()
+	stdout := os.Stdout
+	os.Stdout = terminal
+	defer func() { os.Stdout = stdout }()
+
+	output, err := startOutput()
+	if err != nil {
+		t.Fatalf("startOutput() error: %v", err)
+	}
+	// Longer than the 64 KiB a bufio.Scanner accepts, and than the pipe buffer
+	long := strings.Repeat("x", 256*1024)
+	fmt.Println(long)
+	fmt.Println("after the long line")
+	output.Close()
+
+	data, err := os.ReadFile(terminal.Name())
+	if err != nil {
+		t.Fatalf("Cannot read %s: %v", terminal.Name(), err)
+	}
+	if expected := long + "\nafter the long line\n"; string(data) != expected {
+		t.Errorf("Output has %d bytes, expected the long line and the next one, %d bytes", len(data), len(expected))
+	}
+}
+
+func TestOutputMaxSizeAfterTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "perf.log")
+	output := &Output{terminal: os.Stderr}
+	output.SetMaxSize(20, 2)
+	if err := output.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	output.writeFileLine("line 0001")
+	output.writeFileLine("line 0002")
+	// logrotate's copytruncate empties the file while it is open
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("Cannot truncate: %v", err)
+	}
+	output.writeFileLine("line 0003")
+	output.file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read %s: %v", path, err)
+	}
+	if string(data) != "line 0003\n" {
+		t.Errorf("perf.log contains %q, expected \"line 0003\\n\"", data)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("perf.log.1 exists although the truncated file had room: %v", err)
+	}
+}
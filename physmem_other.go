@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// Linux and macOS read their total memory in readTotalMemory
+func physicalMemory() (int64, error) {
+	return 0, errors.New("total memory is not supported on this OS")
+}
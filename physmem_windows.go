@@ -0,0 +1,31 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// memoryStatusEx is MEMORYSTATUSEX from the Windows API
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+var globalMemoryStatusEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+// physicalMemory returns the installed memory as reported by GlobalMemoryStatusEx
+func physicalMemory() (int64, error) {
+	status := memoryStatusEx{}
+	status.length = uint32(unsafe.Sizeof(status))
+	if ok, _, err := globalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		return 0, err
+	}
+	return int64(status.totalPhys), nil
+}
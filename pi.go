@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Random points each pi iteration throws
+const piSamplesPerIteration = 1 << 20
+
+// piSamples pools the Monte Carlo samples of all pi threads, so the reported
+// estimate improves with every thread
+var piSamples struct {
+	inside atomic.Int64
+	total  atomic.Int64
+}
+
+// workloadSeed seeds the random data of one thread: -seed plus the thread ID
+// for reproducible runs, otherwise the clock
+func workloadSeed(threadID int, config Config) int64 {
+	if config.seed != 0 {
+		return config.seed + int64(threadID)
+	}
+	return time.Now().UnixNano() + int64(threadID)
+}
+
+// throwPoints throws random points at the unit square and returns how many
+// land inside the quarter circle, which is pi/4 of them on average
+func throwPoints(rng *rand.Rand, points int) int {
+	inside := 0
+	for i := 0; i < points; i++ {
+		x, y := rng.Float64(), rng.Float64()
+		if x*x+y*y <= 1 {
+			inside++
+		}
+	}
+	return inside
+}
+
+func estimatePi(inside, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 4 * float64(inside) / float64(total)
+}
+
+// newPiIteration estimates pi by Monte Carlo sampling, mixing floating point
+// math, random number generation and an unpredictable branch. Each sample
+// counts as one iteration.
+func newPiIteration(threadID int, config Config) func() int {
+	rng := rand.New(rand.NewSource(workloadSeed(threadID, config)))
+	return func() int {
+		inside := throwPoints(rng, piSamplesPerIteration)
+		piSamples.inside.Add(int64(inside))
+		piSamples.total.Add(piSamplesPerIteration)
+		return piSamplesPerIteration
+	}
+}
+
+func formatPiRate(perSec float64, config Config) string {
+	estimate := estimatePi(piSamples.inside.Load(), piSamples.total.Load())
+	return fmt.Sprintf("%s iters/sec, est=%.5f err=%.1e",
+		formatWithCommas(perSec), estimate, math.Abs(estimate-math.Pi))
+}
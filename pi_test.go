@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestThrowPointsConverges(t *testing.T) {
+	const points = 4000000
+	rng := rand.New(rand.NewSource(1))
+	estimate := estimatePi(int64(throwPoints(rng, points)), points)
+
+	// The standard error of the estimate is about 1.6/sqrt(points)
+	if err := math.Abs(estimate - math.Pi); err > 0.005 {
+		t.Errorf("Estimate %.5f after %d points is off by %.1e, expected within 5e-3", estimate, points, err)
+	}
+}
+
+func TestWorkloadSeed(t *testing.T) {
+	config := Config{seed: 42}
+	if workloadSeed(0, config) != 42 || workloadSeed(3, config) != 45 {
+		t.Errorf("workloadSeed() = %d, %d, expected 42, 45", workloadSeed(0, config), workloadSeed(3, config))
+	}
+
+	// The same seed reproduces the same samples
+	first := throwPoints(rand.New(rand.NewSource(workloadSeed(1, config))), 1000)
+	second := throwPoints(rand.New(rand.NewSource(workloadSeed(1, config))), 1000)
+	if first != second {
+		t.Errorf("throwPoints() with the same seed = %d and %d, expected equal", first, second)
+	}
+}
+
+func TestEstimatePiEmpty(t *testing.T) {
+	if estimate := estimatePi(0, 0); estimate != 0 {
+		t.Errorf("estimatePi(0, 0) = %v, expected 0", estimate)
+	}
+}
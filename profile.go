@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling writes a CPU profile of the whole run to dir/cpu.prof. The
+// returned function stops it and adds a heap profile as dir/mem.prof.
+func startProfiling(dir string) (func() error, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	cpuFile, err := os.Create(filepath.Join(dir, "cpu.prof"))
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, err
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpuFile.Close(); err != nil {
+			return err
+		}
+
+		memFile, err := os.Create(filepath.Join(dir, "mem.prof"))
+		if err != nil {
+			return err
+		}
+		// Collect garbage first so the profile shows live memory only
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(memFile); err != nil {
+			memFile.Close()
+			return err
+		}
+		return memFile.Close()
+	}, nil
+}
+
+// servePprof exposes net/http/pprof on addr until the process exits
+func servePprof(addr string) {
+	fmt.Printf("Profile: Serving pprof on http://%s/debug/pprof/\n", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		fmt.Printf("Profile: Error serving pprof: %v\n", err)
+	}
+}
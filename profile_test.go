@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	stop, err := startProfiling(dir)
+	if err != nil {
+		t.Fatalf("startProfiling() error: %v", err)
+	}
+	isPrime(1000003)
+	if err := stop(); err != nil {
+		t.Fatalf("Stopping the profiles returned error: %v", err)
+	}
+
+	for _, name := range []string{"cpu.prof", "mem.prof"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Size() == 0 {
+			t.Errorf("Expected a non-empty %s, got %v", name, err)
+		}
+	}
+}
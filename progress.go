@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Width of the -tui progress bar in characters
+const progressBarWidth = 40
+
+// runProgress is how far a run with -duration has come
+type runProgress struct {
+	elapsed   time.Duration
+	remaining time.Duration
+	percent   int
+}
+
+// newRunProgress computes the progress after elapsed of a run lasting
+// duration, which must be positive. A run that overran its duration while
+// shutting down stays at 100%.
+func newRunProgress(elapsed, duration time.Duration) runProgress {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > duration {
+		elapsed = duration
+	}
+	return runProgress{elapsed: elapsed, remaining: duration - elapsed, percent: int(elapsed * 100 / duration)}
+}
+
+// String formats the progress like [00:45 / 02:00, 37%]
+func (p runProgress) String() string {
+	return fmt.Sprintf("[%s / %s, %d%%]", formatClock(p.elapsed), formatClock(p.elapsed+p.remaining), p.percent)
+}
+
+// bar draws the progress as a bar of width characters
+func (p runProgress) bar(width int) string {
+	filled := width * p.percent / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// formatClock formats d in whole seconds as mm:ss, or h:mm:ss from an hour on
+func formatClock(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// progressSuffix is appended to the report lines: the run's progress after
+// a space, or nothing if the run has no -duration
+func progressSuffix(metrics *Metrics) string {
+	if progress, ok := metrics.Progress(); ok {
+		return " " + progress.String()
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRunProgress(t *testing.T) {
+	tests := []struct {
+		elapsed   time.Duration
+		duration  time.Duration
+		remaining time.Duration
+		percent   int
+		formatted string
+	}{
+		{45 * time.Second, 2 * time.Minute, 75 * time.Second, 37, "[00:45 / 02:00, 37%]"},
+		{0, 2 * time.Minute, 2 * time.Minute, 0, "[00:00 / 02:00, 0%]"},
+		{90 * time.Minute, 2 * time.Hour, 30 * time.Minute, 75, "[1:30:00 / 2:00:00, 75%]"},
+		// Shutting down may take longer than the duration
+		{2*time.Minute + 3*time.Second, 2 * time.Minute, 0, 100, "[02:00 / 02:00, 100%]"},
+		{-time.Second, time.Minute, time.Minute, 0, "[00:00 / 01:00, 0%]"},
+	}
+
+	for _, test := range tests {
+		progress := newRunProgress(test.elapsed, test.duration)
+		if progress.remaining != test.remaining || progress.percent != test.percent {
+			t.Errorf("newRunProgress(%v, %v) = %v remaining, %d%%, expected %v, %d%%",
+				test.elapsed, test.duration, progress.remaining, progress.percent, test.remaining, test.percent)
+		}
+		if formatted := progress.String(); formatted != test.formatted {
+			t.Errorf("newRunProgress(%v, %v) formats as %q, expected %q", test.elapsed, test.duration, formatted, test.formatted)
+		}
+	}
+}
+
+func TestRunProgressBar(t *testing.T) {
+	if bar := newRunProgress(30*time.Second, time.Minute).bar(10); bar != "[#####-----]" {
+		t.Errorf("bar(10) at 50%% = %q, expected [#####-----]", bar)
+	}
+	if bar := newRunProgress(time.Minute, time.Minute).bar(4); bar != "[####]" {
+		t.Errorf("bar(4) at 100%% = %q, expected [####]", bar)
+	}
+}
+
+func TestMetricsProgress(t *testing.T) {
+	metrics := &Metrics{}
+	if _, ok := metrics.Progress(); ok || progressSuffix(metrics) != "" {
+		t.Errorf("Progress() before StartClock reported progress")
+	}
+	metrics.StartClock(0)
+	if _, ok := metrics.Progress(); ok {
+		t.Errorf("Progress() without a duration reported progress")
+	}
+
+	metrics.StartClock(time.Hour)
+	progress, ok := metrics.Progress()
+	if !ok || progress.percent != 0 || progress.remaining <= 59*time.Minute {
+		t.Errorf("Progress() right after StartClock(1h) = %+v, %v, expected 0%% with about an hour left", progress, ok)
+	}
+	if suffix := progressSuffix(metrics); !strings.HasPrefix(suffix, " [00:00 / 1:00:00, 0%]") {
+		t.Errorf("progressSuffix() = %q, expected \" [00:00 / 1:00:00, 0%%]\"", suffix)
+	}
+}
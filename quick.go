@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// How long -quick-cpu runs unless -duration is given
+const quickCPUDuration = 10 * time.Second
+
+// applyQuickCPU turns the run into a one-shot CPU score: the prime workload
+// alone for a fixed time, with all output silenced like -format none so
+// only the score is printed. Flags given explicitly are kept.
+func applyQuickCPU(config Config, explicit map[string]bool) Config {
+	if !explicit["duration"] {
+		config.duration = quickCPUDuration
+	}
+	config.disableDisk = true
+	config.format = "none"
+	return config
+}
+
+// formatQuickScore renders primes/sec as a plain integer for shell scripts
+func formatQuickScore(primesPerSec float64) string {
+	return strconv.FormatFloat(primesPerSec, 'f', 0, 64)
+}
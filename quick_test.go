@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyQuickCPU(t *testing.T) {
+	config := applyQuickCPU(Config{format: "text"}, map[string]bool{})
+	if config.duration != quickCPUDuration || !config.disableDisk || config.format != "none" {
+		t.Errorf("applyQuickCPU() = %+v, expected %v, no disk and -format none", config, quickCPUDuration)
+	}
+
+	config = applyQuickCPU(Config{duration: 3 * time.Second}, map[string]bool{"duration": true})
+	if config.duration != 3*time.Second {
+		t.Errorf("applyQuickCPU() overrode the explicit duration: %v", config.duration)
+	}
+}
+
+func TestFormatQuickScore(t *testing.T) {
+	tests := []struct {
+		primesPerSec float64
+		expected     string
+	}{
+		{213453.4, "213453"},
+		{1234567.5, "1234568"},
+		{0, "0"},
+	}
+
+	for _, test := range tests {
+		if result := formatQuickScore(test.primesPerSec); result != test.expected {
+			t.Errorf("formatQuickScore(%v) = %q, expected %q", test.primesPerSec, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// regexCorpusLines is the log the regex workload scans, repeated up to
+// -regex-corpus-size: web access logs and application logs
+var regexCorpusLines = []string{
+	`10.0.0.1 - - [16/Oct/2026:10:00:00 +0000] "GET /index.html HTTP/1.1" 200 512 "-" "Mozilla/5.0"`,
+	`192.168.1.20 - - [16/Oct/2026:10:00:01 +0000] "POST /api/login HTTP/1.1" 503 128 "-" "curl/8.0"`,
+	`2026-10-16T10:00:02Z ERROR payment failed for user alice@example.com`,
+	`172.16.5.4 - - [16/Oct/2026:10:00:03 +0000] "GET /search?q=1 UNION SELECT password FROM users HTTP/1.1" 403 0 "-" "sqlmap"`,
+	`2026-10-16T10:00:04Z INFO cache warmed in 42ms`,
+}
+
+// regexPatterns are typical log processing and WAF rules
+var regexPatterns = []string{
+	`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`,
+	`"(GET|POST|PUT|DELETE) [^ ]+ HTTP/1\.[01]"`,
+	`" 5\d\d `,
+	`[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`,
+	`(?i)union\s+select|<script`,
+}
+
+// regexStats pools the matches and bytes scanned of all regex threads for
+// the matches/sec in the report
+var regexStats struct {
+	matches atomic.Int64
+	scanned atomic.Int64
+}
+
+// regexCorpus repeats the corpus lines up to size bytes, keeping whole lines
+// so the match counts stay predictable, but at least one line
+func regexCorpus(size int64) []byte {
+	var corpus []byte
+	for i := 0; ; i++ {
+		line := regexCorpusLines[i%len(regexCorpusLines)] + "\n"
+		if len(corpus) > 0 && int64(len(corpus)+len(line)) > size {
+			return corpus
+		}
+		corpus = append(corpus, line...)
+	}
+}
+
+func countMatches(patterns []*regexp.Regexp, corpus []byte) int {
+	matches := 0
+	for _, pattern := range patterns {
+		matches += len(pattern.FindAllIndex(corpus, -1))
+	}
+	return matches
+}
+
+// newRegexIteration runs every pattern over the corpus. The patterns are
+// compiled once per thread, and each byte scanned by a pattern counts as an
+// operation, so the rate is the scan bandwidth.
+func newRegexIteration(threadID int, config Config) func() int {
+	patterns := make([]*regexp.Regexp, len(regexPatterns))
+	for i, pattern := range regexPatterns {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+	corpus := regexCorpus(config.regexCorpusSize)
+	return func() int {
+		scanned := len(corpus) * len(patterns)
+		regexStats.matches.Add(int64(countMatches(patterns, corpus)))
+		regexStats.scanned.Add(int64(scanned))
+		return scanned
+	}
+}
+
+func formatRegexRate(bytesPerSec float64, config Config) string {
+	matchesPerSec := 0.0
+	if scanned := regexStats.scanned.Load(); scanned > 0 {
+		matchesPerSec = bytesPerSec * float64(regexStats.matches.Load()) / float64(scanned)
+	}
+	return fmt.Sprintf("%s scanned, %s matches/sec", formatBandwidth(bytesPerSec, config), formatWithCommas(matchesPerSec))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexPatternMatches(t *testing.T) {
+	// Matches per pattern in one pass over the corpus lines
+	expected := []int{3, 2, 1, 1, 1}
+	corpus := []byte(strings.Join(regexCorpusLines, "\n") + "\n")
+
+	for i, pattern := range regexPatterns {
+		matches := countMatches([]*regexp.Regexp{regexp.MustCompile(pattern)}, corpus)
+		if matches != expected[i] {
+			t.Errorf("Pattern %q matches %d times, expected %d", pattern, matches, expected[i])
+		}
+	}
+}
+
+func TestRegexCorpus(t *testing.T) {
+	block := len(strings.Join(regexCorpusLines, "\n")) + 1
+	corpus := regexCorpus(int64(4 * block))
+	if len(corpus) != 4*block {
+		t.Fatalf("regexCorpus(%d) has %d bytes, expected whole lines filling it", 4*block, len(corpus))
+	}
+
+	patterns := make([]*regexp.Regexp, len(regexPatterns))
+	for i, pattern := range regexPatterns {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+	if matches := countMatches(patterns, corpus); matches != 4*8 {
+		t.Errorf("countMatches() over 4 repetitions = %d, expected 32", matches)
+	}
+
+	// A size below one line still yields a line to scan
+	if corpus := regexCorpus(1); !strings.HasSuffix(string(corpus), "\n") || len(corpus) < 2 {
+		t.Errorf("regexCorpus(1) = %q, expected one whole line", corpus)
+	}
+}
+
+func TestNewRegexIteration(t *testing.T) {
+	iterate := newRegexIteration(0, Config{regexCorpusSize: 4096})
+	if scanned := iterate(); scanned != len(regexCorpus(4096))*len(regexPatterns) {
+		t.Errorf("Regex iteration scanned %d bytes, expected the corpus once per pattern", scanned)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// parseReportTemplate parses -report-template and renders it once with
+// empty metrics, so unknown fields fail at startup instead of at the first
+// report
+func parseReportTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("report").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, MetricsSnapshot{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderReport renders one report line from the current metrics, ending it
+// with a newline unless the template already does
+func renderReport(tmpl *template.Template, snapshot MetricsSnapshot) (string, error) {
+	var line strings.Builder
+	if err := tmpl.Execute(&line, snapshot); err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line.String(), "\n") {
+		line.WriteString("\n")
+	}
+	return line.String(), nil
+}
+
+// benchmarkReports reports whether the benchmarks print their own interval
+// reports, which a -report-template line replaces
+func benchmarkReports(config Config) bool {
+	return config.reportTemplate == ""
+}
+
+// templateReporter prints the -report-template line at every report
+// interval, backing off like the other reports, until stopChan closes
+func templateReporter(stopChan <-chan struct{}, config Config, tmpl *template.Template, metrics *Metrics) {
+	interval := time.Duration(config.reportInterval) * time.Second
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-timer.C:
+		}
+
+		interval = nextReportInterval(interval, config)
+		timer.Reset(interval)
+
+		line, err := renderReport(tmpl, metrics.Snapshot())
+		if err != nil {
+			fmt.Printf("Report: Error rendering -report-template: %v\n", err)
+			continue
+		}
+		fmt.Print(line)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestRenderReport(t *testing.T) {
+	snapshot := MetricsSnapshot{CPUPrimesPerSec: 1234567.8, DiskWriteMBps: 512.25, DiskReadMBps: 2048}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"{{.CPUPrimesPerSec}} {{.DiskWriteMBps}}", "1.2345678e+06 512.25\n"},
+		{`cpu={{printf "%.0f" .CPUPrimesPerSec}} read={{printf "%.1f" .DiskReadMBps}}`, "cpu=1234568 read=2048.0\n"},
+		// A template that ends its own line gets no second newline
+		{"{{.DiskWriteMBps}}\n", "512.25\n"},
+	}
+
+	for _, test := range tests {
+		tmpl, err := parseReportTemplate(test.template)
+		if err != nil {
+			t.Fatalf("parseReportTemplate(%q) returned error: %v", test.template, err)
+		}
+		line, err := renderReport(tmpl, snapshot)
+		if err != nil {
+			t.Fatalf("renderReport(%q) returned error: %v", test.template, err)
+		}
+		if line != test.expected {
+			t.Errorf("renderReport(%q) = %q, expected %q", test.template, line, test.expected)
+		}
+	}
+}
+
+func TestParseReportTemplateErrors(t *testing.T) {
+	for _, text := range []string{"{{.CPUPrimesPerSec", "{{.NoSuchMetric}}", "{{end}}"} {
+		if _, err := parseReportTemplate(text); err == nil {
+			t.Errorf("parseReportTemplate(%q) succeeded, expected an error", text)
+		}
+	}
+}
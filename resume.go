@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// ResumeState is the part of the accumulated statistics that -resume carries
+// over to the next run. Disk throughput is the average over DiskIterations.
+// The lifetime counters include what came before a reset with SIGUSR1, so
+// the exported counters keep growing across runs.
+type ResumeState struct {
+	SchemaVersion    int           `json:"schema_version"`
+	Elapsed          time.Duration `json:"elapsed_ns"`
+	CPUPrimes        int64         `json:"cpu_primes"`
+	CPUTimeNanos     int64         `json:"cpu_time_ns"`
+	DiskBytesWritten int64         `json:"disk_bytes_written"`
+	DiskBytesRead    int64         `json:"disk_bytes_read"`
+	DiskIterations   int64         `json:"disk_iterations"`
+	DiskWriteMBps    float64       `json:"disk_write_mbps"`
+	DiskReadMBps     float64       `json:"disk_read_mbps"`
+
+	CPULifetimePrimes   int64 `json:"cpu_lifetime_primes,omitempty"`
+	DiskLifetimeWritten int64 `json:"disk_lifetime_bytes_written,omitempty"`
+	DiskLifetimeRead    int64 `json:"disk_lifetime_bytes_read,omitempty"`
+}
+
+func captureResumeState(elapsed time.Duration, cpuStats *CPUStats, diskStats *DiskStats, metrics *Metrics) ResumeState {
+	snapshot := metrics.Snapshot()
+	lifetimeWritten, lifetimeRead := diskStats.LifetimeBytes()
+	return ResumeState{
+		Elapsed:             elapsed,
+		CPUPrimes:           cpuStats.totalPrimesFound.Load(),
+		CPUTimeNanos:        cpuStats.totalTimeNanos.Load(),
+		DiskBytesWritten:    diskStats.bytesWritten.Load(),
+		DiskBytesRead:       diskStats.bytesRead.Load(),
+		DiskIterations:      diskStats.iterations.Load(),
+		DiskWriteMBps:       snapshot.DiskWriteMBps,
+		DiskReadMBps:        snapshot.DiskReadMBps,
+		CPULifetimePrimes:   cpuStats.LifetimePrimes(),
+		DiskLifetimeWritten: lifetimeWritten,
+		DiskLifetimeRead:    lifetimeRead,
+	}
+}
+
+// apply seeds the stats of a new run, which must not have started yet
+func (s ResumeState) apply(cpuStats *CPUStats, diskStats *DiskStats, metrics *Metrics) {
+	cpuStats.totalPrimesFound.Store(s.CPUPrimes)
+	cpuStats.totalTimeNanos.Store(s.CPUTimeNanos)
+	diskStats.bytesWritten.Store(s.DiskBytesWritten)
+	diskStats.bytesRead.Store(s.DiskBytesRead)
+	diskStats.iterations.Store(s.DiskIterations)
+	// Files saved without the lifetime counters had no reset to carry over
+	cpuStats.primesBeforeReset = beforeReset(s.CPULifetimePrimes, s.CPUPrimes)
+	diskStats.bytesBeforeReset.Store(beforeReset(s.DiskLifetimeWritten, s.DiskBytesWritten))
+	diskStats.bytesReadBeforeReset.Store(beforeReset(s.DiskLifetimeRead, s.DiskBytesRead))
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.DiskWriteMBps = s.DiskWriteMBps
+		snapshot.DiskReadMBps = s.DiskReadMBps
+	})
+}
+
+// beforeReset is the part of a lifetime counter that came before the last
+// reset, zero for a state saved without it
+func beforeReset(lifetime, total int64) int64 {
+	if lifetime < total {
+		return 0
+	}
+	return lifetime - total
+}
+
+func loadResumeState(path string) (ResumeState, error) {
+	var state ResumeState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	return state, checkSchemaVersion(state.SchemaVersion)
+}
+
+// saveResumeState replaces path atomically so an interrupted save keeps the
+// previous state
+func saveResumeState(path string, state ResumeState) error {
+	state.SchemaVersion = CurrentSchemaVersion
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, ".perf_test_resume_*.tmp", func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumeStateSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.json")
+	state := ResumeState{
+		SchemaVersion:    CurrentSchemaVersion,
+		Elapsed:          90 * time.Minute,
+		CPUPrimes:        123456789,
+		CPUTimeNanos:     int64(time.Hour),
+		DiskBytesWritten: 5 * 1024 * 1024 * 1024,
+		DiskBytesRead:    4 * 1024 * 1024 * 1024,
+		DiskIterations:   42,
+		DiskWriteMBps:    512.5,
+		DiskReadMBps:     1024.25,
+	}
+
+	if err := saveResumeState(path, state); err != nil {
+		t.Fatalf("saveResumeState() returned error: %v", err)
+	}
+	loaded, err := loadResumeState(path)
+	if err != nil {
+		t.Fatalf("loadResumeState() returned error: %v", err)
+	}
+	if loaded != state {
+		t.Errorf("loadResumeState() = %+v, expected %+v", loaded, state)
+	}
+
+	// Saving again replaces the previous state
+	state.DiskIterations = 43
+	if err := saveResumeState(path, state); err != nil {
+		t.Fatalf("saveResumeState() returned error on overwrite: %v", err)
+	}
+	if loaded, _ := loadResumeState(path); loaded.DiskIterations != 43 {
+		t.Errorf("loadResumeState() after overwrite has %d iterations, expected 43", loaded.DiskIterations)
+	}
+}
+
+func TestLoadResumeStateMissing(t *testing.T) {
+	_, err := loadResumeState(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loadResumeState() of a missing file returned %v, expected os.ErrNotExist", err)
+	}
+}
+
+func TestLoadResumeStateSchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"old.json":    `{"elapsed_ns": 60000000000, "cpu_primes": 100}`,
+		"future.json": `{"schema_version": 99, "elapsed_ns": 60000000000}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write %s: %v", name, err)
+		}
+		_, err := loadResumeState(path)
+		if err == nil || !strings.Contains(err.Error(), "schema_version") {
+			t.Errorf("loadResumeState(%s) returned %v, expected a schema_version error", name, err)
+		}
+	}
+}
+
+func TestResumeStateApplyCapture(t *testing.T) {
+	state := ResumeState{Elapsed: time.Hour, CPUPrimes: 3000, CPUTimeNanos: int64(time.Second),
+		DiskBytesWritten: 1024, DiskBytesRead: 512, DiskIterations: 2, DiskWriteMBps: 100, DiskReadMBps: 200}
+
+	cpuStats := newCPUStats(time.Second)
+	diskStats := &DiskStats{}
+	metrics := &Metrics{}
+	state.apply(cpuStats, diskStats, metrics)
+
+	// The resumed run carries on from the loaded totals
+	cpuStats.Add(1000, time.Second)
+	diskStats.bytesWritten.Add(1024)
+	diskStats.bytesRead.Add(1024)
+
+	captured := captureResumeState(2*time.Hour, cpuStats, diskStats, metrics)
+	expected := ResumeState{Elapsed: 2 * time.Hour, CPUPrimes: 4000, CPUTimeNanos: int64(2 * time.Second),
+		DiskBytesWritten: 2048, DiskBytesRead: 1536, DiskIterations: 2, DiskWriteMBps: 100, DiskReadMBps: 200,
+		CPULifetimePrimes: 4000, DiskLifetimeWritten: 2048, DiskLifetimeRead: 1536}
+	if captured != expected {
+		t.Errorf("captureResumeState() = %+v, expected %+v", captured, expected)
+	}
+	if rate := cpuStats.TotalPrimesPerSec(1); rate != 2000 {
+		t.Errorf("TotalPrimesPerSec() after resume = %f, expected 2000", rate)
+	}
+}
+
+func TestResumeStateKeepsLifetimeAcrossReset(t *testing.T) {
+	cpuStats := newCPUStats(time.Second)
+	diskStats := &DiskStats{}
+	cpuStats.Add(3000, time.Second)
+	diskStats.bytesWritten.Add(1024)
+	diskStats.bytesRead.Add(512)
+	cpuStats.Reset()
+	diskStats.Reset()
+	cpuStats.Add(1000, time.Second)
+	diskStats.bytesWritten.Add(256)
+	state := captureResumeState(time.Hour, cpuStats, diskStats, &Metrics{})
+
+	// The next run starts from the totals since the reset, but its lifetime
+	// counters continue from those of the first run
+	resumedCPU := newCPUStats(time.Second)
+	resumedDisk := &DiskStats{}
+	state.apply(resumedCPU, resumedDisk, &Metrics{})
+	resumedCPU.Add(500, time.Second)
+	resumedDisk.bytesWritten.Add(128)
+
+	if total := resumedCPU.totalPrimesFound.Load(); total != 1500 {
+		t.Errorf("totalPrimesFound after resume = %d, expected 1500", total)
+	}
+	if primes := resumedCPU.LifetimePrimes(); primes != 4500 {
+		t.Errorf("LifetimePrimes() after resume = %d, expected 4500", primes)
+	}
+	if written, read := resumedDisk.LifetimeBytes(); written != 1408 || read != 512 {
+		t.Errorf("LifetimeBytes() after resume = %d, %d, expected 1408, 512", written, read)
+	}
+}
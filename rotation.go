@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cpuWorkloadList splits -cpu-workload, which may name several workloads
+// separated by commas
+func cpuWorkloadList(config Config) []string {
+	var workloads []string
+	for _, name := range strings.Split(config.cpuWorkload, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			workloads = append(workloads, name)
+		}
+	}
+	return workloads
+}
+
+// rotatingWorkloads reports whether the threads cycle through several workloads
+func rotatingWorkloads(config Config) bool {
+	return len(cpuWorkloadList(config)) > 1
+}
+
+func hasCPUWorkload(config Config, name string) bool {
+	for _, workload := range cpuWorkloadList(config) {
+		if workload == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rotationIndex picks the workload of a thread's iteration. Offsetting by
+// the thread ID keeps the threads on different workloads, so every workload
+// runs somewhere at any time.
+func rotationIndex(threadID, iteration, workloads int) int {
+	return (threadID + iteration) % workloads
+}
+
+// newPrimeIteration lets the prime workload take part in a rotation like
+// an opsWorkload, counting primes as operations
+func newPrimeIteration(threadID int, config Config) func() int {
+	primeRange := threadPrimeRange(threadID, config)
+	return func() int {
+		return countPrimes(primeRange, config)
+	}
+}
+
+// workloadRate formats the rate of any rotating workload in its own unit
+func workloadRate(name string, perSec float64, config Config) string {
+	if name == "prime" {
+		return formatWithCommas(perSec) + " primes/sec"
+	}
+	return opsWorkloads[name].rate(perSec, config)
+}
+
+// startRotationThreads is startCPUThreads for rotating workloads, keeping
+// separate stats per workload. Only primes are counted in cpuStats.
+func startRotationThreads(stopChan <-chan struct{}, config Config, cpuStats *CPUStats, metrics *Metrics, wg *sync.WaitGroup) {
+	workloads := cpuWorkloadList(config)
+	stats := make(map[string]*CPUStats, len(workloads))
+	for _, name := range workloads {
+		// Primes count toward the run's totals like a single prime workload
+		if name == "prime" {
+			stats[name] = cpuStats
+			continue
+		}
+		stats[name] = newCPUStats(time.Duration(config.reportInterval) * time.Second)
+		cpuStats.Link(stats[name])
+	}
+
+	var workers sync.WaitGroup
+	launchThreads(stopChan, config, wg, &workers, func(threadID int) {
+		benchmarkRotation(threadID, stopChan, config, workloads, stats)
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reportRotation(stopChan, config, workloads, stats, metrics)
+		workers.Wait()
+		updateRotationMetrics(config, workloads, stats, metrics)
+	}()
+}
+
+// benchmarkRotation runs one iteration of the next workload in turn,
+// adding its result to that workload's stats
+func benchmarkRotation(threadID int, stopChan <-chan struct{}, config Config, workloads []string, stats map[string]*CPUStats) {
+	if config.full {
+		fmt.Printf("CPU Thread %d: Starting %s\n", threadID, strings.Join(workloads, ", "))
+	}
+
+	iterations := make([]func() int, len(workloads))
+	for i, name := range workloads {
+		if name == "prime" {
+			iterations[i] = newPrimeIteration(threadID, config)
+		} else {
+			iterations[i] = opsWorkloads[name].newIteration(threadID, config)
+		}
+	}
+
+	iteration := 0
+	for {
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("CPU Thread %d: Completed %d iterations\n", threadID, iteration)
+			}
+			return
+		default:
+			index := rotationIndex(threadID, iteration, len(workloads))
+			start := time.Now()
+			ops := iterations[index]()
+			iteration++
+			// The workloads differ in unit, so there is no per-thread rate
+			recordIteration(iteration, ops, time.Since(start), config, stats[workloads[index]], nil)
+		}
+	}
+}
+
+// updateRotationMetrics publishes each workload's rate, scaled to all
+// threads like the single-workload rate, and returns them
+func updateRotationMetrics(config Config, workloads []string, stats map[string]*CPUStats, metrics *Metrics) map[string]float64 {
+	rates := make(map[string]float64, len(workloads))
+	for _, name := range workloads {
+		rates[name] = stats[name].TotalPrimesPerSec(config.cpuThreads)
+	}
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.CPUWorkloadRates = rates
+		snapshot.CPUPrimesPerSec = rates["prime"]
+	})
+	return rates
+}
+
+// reportRotation is reportCPU for rotating workloads, with one line per workload
+func reportRotation(stopChan <-chan struct{}, config Config, workloads []string, stats map[string]*CPUStats, metrics *Metrics) {
+	interval := time.Duration(config.reportInterval) * time.Second
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-timer.C:
+		}
+
+		interval = nextReportInterval(interval, config)
+		timer.Reset(interval)
+
+		rates := updateRotationMetrics(config, workloads, stats, metrics)
+		if !benchmarkReports(config) {
+			continue
+		}
+		for _, name := range workloads {
+			// Nothing to report before the workload's first iteration completed
+			if stats[name].totalTimeNanos.Load() == 0 {
+				continue
+			}
+			fmt.Printf("CPU: %s total %s%s\n", name, workloadRate(name, rates[name], config), progressSuffix(metrics))
+		}
+	}
+}
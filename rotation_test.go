@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCPUWorkloadList(t *testing.T) {
+	tests := []struct {
+		cpuWorkload string
+		expected    []string
+		rotating    bool
+	}{
+		{"prime", []string{"prime"}, false},
+		{"prime,pi,regex", []string{"prime", "pi", "regex"}, true},
+		{" prime , branchy ,", []string{"prime", "branchy"}, true},
+	}
+
+	for _, test := range tests {
+		config := Config{cpuWorkload: test.cpuWorkload}
+		if result := cpuWorkloadList(config); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("cpuWorkloadList(%q) = %q, expected %q", test.cpuWorkload, result, test.expected)
+		}
+		if rotatingWorkloads(config) != test.rotating {
+			t.Errorf("rotatingWorkloads(%q) = %v, expected %v", test.cpuWorkload, !test.rotating, test.rotating)
+		}
+	}
+}
+
+func TestRotationIndex(t *testing.T) {
+	// Each thread cycles through every workload, starting at its own offset
+	expected := [][]int{
+		{0, 1, 2, 0, 1, 2},
+		{1, 2, 0, 1, 2, 0},
+		{2, 0, 1, 2, 0, 1},
+		{0, 1, 2, 0, 1, 2},
+	}
+	for threadID, sequence := range expected {
+		for iteration, index := range sequence {
+			if result := rotationIndex(threadID, iteration, 3); result != index {
+				t.Errorf("rotationIndex(%d, %d, 3) = %d, expected %d", threadID, iteration, result, index)
+			}
+		}
+	}
+
+	// At any iteration, threads cover all workloads when there are enough of them
+	for iteration := 0; iteration < 3; iteration++ {
+		seen := make(map[int]bool)
+		for threadID := 0; threadID < 3; threadID++ {
+			seen[rotationIndex(threadID, iteration, 3)] = true
+		}
+		if len(seen) != 3 {
+			t.Errorf("Iteration %d runs only %d of 3 workloads across 3 threads", iteration, len(seen))
+		}
+	}
+}
+
+func TestBenchmarkRotation(t *testing.T) {
+	config := Config{cpuWorkload: "prime,branchy", primeRange: 1000, reportInterval: 3600}
+	workloads := cpuWorkloadList(config)
+	stats := map[string]*CPUStats{"prime": newCPUStats(time.Hour), "branchy": newCPUStats(time.Hour)}
+
+	stopChan := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stopChan) })
+	benchmarkRotation(0, stopChan, config, workloads, stats)
+
+	for _, name := range workloads {
+		if stats[name].totalTimeNanos.Load() == 0 {
+			t.Errorf("benchmarkRotation() never ran the %s workload", name)
+		}
+	}
+	// 168 primes below 1000
+	if primes := stats["prime"].totalPrimesFound.Load(); primes%168 != 0 {
+		t.Errorf("benchmarkRotation() counted %d primes, expected a multiple of 168", primes)
+	}
+}
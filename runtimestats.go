@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	schedLatenciesMetric = "/sched/latencies:seconds"
+	gcPausesMetric       = "/gc/pauses:seconds"
+	gcCyclesMetric       = "/gc/cycles/total:gc-cycles"
+	heapObjectsMetric    = "/memory/classes/heap/objects:bytes"
+	goroutinesMetric     = "/sched/goroutines:goroutines"
+)
+
+// RuntimeStats is what the Go runtime did during the run, from
+// runtime/metrics: how long runnable goroutines waited for a thread, the
+// GC pauses and the live heap
+type RuntimeStats struct {
+	SchedWakeups     uint64        `json:"sched_wakeups"`
+	SchedLatencyP50  time.Duration `json:"sched_latency_p50_ns"`
+	SchedLatencyP90  time.Duration `json:"sched_latency_p90_ns"`
+	SchedLatencyP99  time.Duration `json:"sched_latency_p99_ns"`
+	SchedLatencyMax  time.Duration `json:"sched_latency_max_ns"`
+	GCCycles         uint64        `json:"gc_cycles"`
+	GCPauseP99       time.Duration `json:"gc_pause_p99_ns"`
+	GCPauseMax       time.Duration `json:"gc_pause_max_ns"`
+	HeapObjectsStart uint64        `json:"heap_objects_start_bytes"`
+	HeapObjectsEnd   uint64        `json:"heap_objects_end_bytes"`
+	Goroutines       uint64        `json:"goroutines"`
+}
+
+// readRuntimeMetrics samples the runtime metrics RuntimeStats is made of.
+// Metrics this Go version does not know keep metrics.KindBad.
+func readRuntimeMetrics() []metrics.Sample {
+	samples := []metrics.Sample{
+		{Name: schedLatenciesMetric},
+		{Name: gcPausesMetric},
+		{Name: gcCyclesMetric},
+		{Name: heapObjectsMetric},
+		{Name: goroutinesMetric},
+	}
+	metrics.Read(samples)
+	return samples
+}
+
+// runtimeStatsBetween turns two readRuntimeMetrics samples into the
+// distributions and deltas of the time between them
+func runtimeStatsBetween(start, end []metrics.Sample) RuntimeStats {
+	var stats RuntimeStats
+	for i, sample := range end {
+		switch sample.Value.Kind() {
+		case metrics.KindFloat64Histogram:
+			// Histograms count since process start, so only the delta
+			// describes the run
+			delta := histogramDelta(start[i].Value.Float64Histogram(), sample.Value.Float64Histogram())
+			switch sample.Name {
+			case schedLatenciesMetric:
+				stats.SchedWakeups = histogramCount(delta)
+				stats.SchedLatencyP50 = secondsDuration(histogramPercentile(delta, 50))
+				stats.SchedLatencyP90 = secondsDuration(histogramPercentile(delta, 90))
+				stats.SchedLatencyP99 = secondsDuration(histogramPercentile(delta, 99))
+				stats.SchedLatencyMax = secondsDuration(histogramPercentile(delta, 100))
+			case gcPausesMetric:
+				stats.GCPauseP99 = secondsDuration(histogramPercentile(delta, 99))
+				stats.GCPauseMax = secondsDuration(histogramPercentile(delta, 100))
+			}
+		case metrics.KindUint64:
+			switch sample.Name {
+			case gcCyclesMetric:
+				stats.GCCycles = sample.Value.Uint64() - start[i].Value.Uint64()
+			case heapObjectsMetric:
+				stats.HeapObjectsStart = start[i].Value.Uint64()
+				stats.HeapObjectsEnd = sample.Value.Uint64()
+			case goroutinesMetric:
+				stats.Goroutines = sample.Value.Uint64()
+			}
+		}
+	}
+	return stats
+}
+
+// histogramDelta subtracts the counts of start from those of end. Both come
+// from the same metric, so their buckets are identical.
+func histogramDelta(start, end *metrics.Float64Histogram) *metrics.Float64Histogram {
+	delta := &metrics.Float64Histogram{Counts: make([]uint64, len(end.Counts)), Buckets: end.Buckets}
+	for i, count := range end.Counts {
+		delta.Counts[i] = count
+		if i < len(start.Counts) {
+			delta.Counts[i] -= start.Counts[i]
+		}
+	}
+	return delta
+}
+
+func histogramCount(histogram *metrics.Float64Histogram) uint64 {
+	total := uint64(0)
+	for _, count := range histogram.Counts {
+		total += count
+	}
+	return total
+}
+
+// histogramPercentile returns the upper bound of the bucket holding the
+// p-th percentile (0-100) by the nearest-rank method, or its lower bound
+// for the open-ended last bucket. An empty histogram has 0.
+func histogramPercentile(histogram *metrics.Float64Histogram, p float64) float64 {
+	total := histogramCount(histogram)
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := uint64(0)
+	for i, count := range histogram.Counts {
+		seen += count
+		if seen < rank {
+			continue
+		}
+		if upper := histogram.Buckets[i+1]; !math.IsInf(upper, 1) {
+			return upper
+		}
+		return histogram.Buckets[i]
+	}
+	return 0
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	if math.IsInf(seconds, 0) {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// printRuntimeStats prints the -runtime-metrics section of the text summary
+func printRuntimeStats(stats RuntimeStats, config Config) {
+	fmt.Printf("Runtime: sched latency p50 %v, p90 %v, p99 %v, max %v over %d wakeups\n",
+		stats.SchedLatencyP50, stats.SchedLatencyP90, stats.SchedLatencyP99, stats.SchedLatencyMax, stats.SchedWakeups)
+	fmt.Printf("Runtime: %d GC cycles, pause p99 %v, max %v\n", stats.GCCycles, stats.GCPauseP99, stats.GCPauseMax)
+	fmt.Printf("Runtime: heap objects %s at start, %s at end, %d goroutines\n",
+		formatBytes(int64(stats.HeapObjectsStart), config.units), formatBytes(int64(stats.HeapObjectsEnd), config.units), stats.Goroutines)
+}
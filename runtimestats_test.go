@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	histogram := &metrics.Float64Histogram{
+		Counts:  []uint64{50, 40, 9, 1},
+		Buckets: []float64{math.Inf(-1), 0.001, 0.01, 0.1, math.Inf(1)},
+	}
+
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 0.001},
+		{50, 0.001},
+		{51, 0.01},
+		{90, 0.01},
+		{99, 0.1},
+		// The open-ended last bucket only has a lower bound
+		{100, 0.1},
+	}
+	for _, test := range tests {
+		if result := histogramPercentile(histogram, test.p); result != test.expected {
+			t.Errorf("histogramPercentile(%g) = %g, expected %g", test.p, result, test.expected)
+		}
+	}
+
+	if result := histogramPercentile(&metrics.Float64Histogram{Counts: []uint64{0}, Buckets: []float64{0, 1}}, 99); result != 0 {
+		t.Errorf("histogramPercentile() of an empty histogram = %g, expected 0", result)
+	}
+}
+
+func TestHistogramDelta(t *testing.T) {
+	buckets := []float64{0, 1, 2, 3}
+	start := &metrics.Float64Histogram{Counts: []uint64{5, 1, 0}, Buckets: buckets}
+	end := &metrics.Float64Histogram{Counts: []uint64{5, 4, 2}, Buckets: buckets}
+
+	delta := histogramDelta(start, end)
+	if delta.Counts[0] != 0 || delta.Counts[1] != 3 || delta.Counts[2] != 2 || histogramCount(delta) != 5 {
+		t.Errorf("histogramDelta() = %v, expected [0 3 2]", delta.Counts)
+	}
+	// Only what happened in between counts
+	if result := histogramPercentile(delta, 50); result != 2 {
+		t.Errorf("histogramPercentile() of the delta = %g, expected 2", result)
+	}
+}
+
+func TestRuntimeStatsBetween(t *testing.T) {
+	start := readRuntimeMetrics()
+
+	// Goroutine wakeups and a GC cycle to observe
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	runtime.GC()
+
+	stats := runtimeStatsBetween(start, readRuntimeMetrics())
+	if stats.SchedWakeups == 0 || stats.GCCycles == 0 {
+		t.Errorf("runtimeStatsBetween() = %+v, expected wakeups and GC cycles", stats)
+	}
+	if stats.SchedLatencyP50 > stats.SchedLatencyP99 || stats.SchedLatencyP99 > stats.SchedLatencyMax {
+		t.Errorf("runtimeStatsBetween() latencies p50 %v, p99 %v, max %v are not ordered",
+			stats.SchedLatencyP50, stats.SchedLatencyP99, stats.SchedLatencyMax)
+	}
+	if stats.HeapObjectsEnd == 0 || stats.Goroutines == 0 {
+		t.Errorf("runtimeStatsBetween() = %+v, expected a heap and goroutines", stats)
+	}
+}
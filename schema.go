@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+// CurrentSchemaVersion versions the JSON written by the tool: the summary and
+// the -resume state. Bump it whenever a field changes meaning or is removed,
+// so older files are rejected instead of silently misparsed.
+const CurrentSchemaVersion = 1
+
+func checkSchemaVersion(version int) error {
+	switch {
+	case version == 0:
+		return fmt.Errorf("no schema_version, written by a version of perf-test before %d", CurrentSchemaVersion)
+	case version != CurrentSchemaVersion:
+		return fmt.Errorf("schema_version %d is not supported, this perf-test reads version %d", version, CurrentSchemaVersion)
+	}
+	return nil
+}
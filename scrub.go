@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// How many flipped bytes each scan records individually, so a failing DIMM
+// does not flood the output
+const scrubMaxLoggedErrors = 10
+
+// bitError is a byte of the allocation that no longer holds the fill pattern
+type bitError struct {
+	chunk    int
+	offset   int
+	expected byte
+	actual   byte
+}
+
+func (e bitError) flippedBits() int {
+	return bits.OnesCount8(e.expected ^ e.actual)
+}
+
+// scrubChunk compares chunk with the fill pattern, repairing and returning
+// every byte that differs, so the next scan only finds new flips
+func scrubChunk(index int, chunk []byte) []bitError {
+	var errors []bitError
+	for offset := 0; offset < len(chunk); offset += len(fillPattern) {
+		end := offset + len(fillPattern)
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+		window := chunk[offset:end]
+		if string(window) == string(fillPattern[:len(window)]) {
+			continue
+		}
+		for i, b := range window {
+			if b != fillPattern[i] {
+				errors = append(errors, bitError{chunk: index, offset: offset + i, expected: fillPattern[i], actual: b})
+				window[i] = fillPattern[i]
+			}
+		}
+	}
+	return errors
+}
+
+// scrubMemory holds the filled allocation and scans it for bit flips every
+// -mem-scrub interval until stopChan closes, like the patrol scrubbing of
+// ECC memory controllers
+func scrubMemory(memoryChunks [][]byte, stopChan <-chan struct{}, config Config, metrics *Metrics, failures *FailureLog) {
+	fmt.Printf("Memory: Scrubbing the allocation every %v instead of running the disk test\n", config.memScrub)
+
+	scans, bitErrors := int64(0), int64(0)
+	ticker := time.NewTicker(config.memScrub)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		logged := 0
+		for index, chunk := range memoryChunks {
+			select {
+			case <-stopChan:
+				// A partial scan is not counted
+				return
+			default:
+			}
+
+			for _, e := range scrubChunk(index, chunk) {
+				bitErrors += int64(e.flippedBits())
+				if logged < scrubMaxLoggedErrors {
+					failures.Record("Memory", "Bit flip in chunk %d at offset %#x: expected %#02x, read %#02x",
+						e.chunk, e.offset, e.expected, e.actual)
+				}
+				logged++
+			}
+		}
+		if logged > scrubMaxLoggedErrors {
+			failures.Record("Memory", "%d more flipped bytes in this scan", logged-scrubMaxLoggedErrors)
+		}
+
+		scans++
+		metrics.Update(func(snapshot *MetricsSnapshot) {
+			snapshot.MemoryScrubScans = scans
+			snapshot.MemoryBitErrors = bitErrors
+		})
+		fmt.Printf("Memory scrub: %d scans, %d bit errors\n", scans, bitErrors)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestScrubChunkFaultInjection(t *testing.T) {
+	chunk := make([]byte, 4096)
+	fillChunk(chunk)
+	if errors := scrubChunk(0, chunk); len(errors) != 0 {
+		t.Fatalf("scrubChunk() found %d errors in a clean chunk", len(errors))
+	}
+
+	// Flip one bit, then three bits of another byte
+	chunk[100] ^= 0x04
+	chunk[3000] ^= 0x83
+
+	errors := scrubChunk(2, chunk)
+	if len(errors) != 2 {
+		t.Fatalf("scrubChunk() found %d flipped bytes, expected 2: %+v", len(errors), errors)
+	}
+	if errors[0].chunk != 2 || errors[0].offset != 100 || errors[0].flippedBits() != 1 {
+		t.Errorf("First error = %+v, expected chunk 2, offset 100, 1 bit", errors[0])
+	}
+	if errors[1].offset != 3000 || errors[1].flippedBits() != 3 || errors[1].expected != byte(3000%256) {
+		t.Errorf("Second error = %+v, expected offset 3000, 3 bits", errors[1])
+	}
+
+	// The scrub repairs the flips, so they are not counted again
+	if errors := scrubChunk(2, chunk); len(errors) != 0 {
+		t.Errorf("scrubChunk() found %d errors after repairing", len(errors))
+	}
+}
+
+func TestScrubChunkShortChunk(t *testing.T) {
+	// The final chunk may end in the middle of a pattern window
+	chunk := make([]byte, 300)
+	fillChunk(chunk)
+	chunk[299] ^= 0xff
+	if errors := scrubChunk(0, chunk); len(errors) != 1 || errors[0].flippedBits() != 8 {
+		t.Errorf("scrubChunk() = %+v, expected one byte with 8 flipped bits", errors)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long each subsystem runs during -self-test
+const selfTestDuration = time.Second
+
+// runSelfTest runs every enabled subsystem briefly and reports whether all passed
+func runSelfTest(config Config) bool {
+	checks := []struct {
+		name     string
+		disabled bool
+		run      func(Config) (string, error)
+	}{
+		{"CPU", config.disableCPU, selfTestCPU},
+		{"Memory", config.disableDisk, selfTestMemory},
+		{"Disk", config.disableDisk, selfTestDisk},
+	}
+
+	passed := true
+	for _, check := range checks {
+		if check.disabled {
+			fmt.Printf("Self-test: %s SKIP (disabled)\n", check.name)
+			continue
+		}
+
+		result, err := check.run(config)
+		if err != nil {
+			fmt.Printf("Self-test: %s FAIL: %v\n", check.name, err)
+			passed = false
+		} else {
+			fmt.Printf("Self-test: %s PASS (%s)\n", check.name, result)
+		}
+	}
+	return passed
+}
+
+// selfTestConfig shrinks the workload so each subsystem produces a result
+// within selfTestDuration and does not print interval reports.
+func selfTestConfig(config Config) Config {
+	config.full = false
+	config.reportInterval = 3600
+	config.reportBackoff = 2
+	config.reportBackoffMax = time.Hour
+	if config.primeRange > 100000 {
+		config.primeRange = 100000
+		config.primeStart = 0
+	}
+	config.chunkSizeMB = 16
+	config.memoryPercent = 0.001
+	config.diskFileSize = 16 * 1024 * 1024
+	// Sizes given per -disk-path would override the small file
+	config.diskPath = strings.Join(diskPaths(config), ",")
+	config.diskPreallocate = false
+	config.diskRWMix = -1
+	config.cpuWorkload = "prime"
+	return config
+}
+
+func selfTestCPU(config Config) (string, error) {
+	config = selfTestConfig(config)
+	cpuStats := newCPUStats(time.Hour)
+	metrics := &Metrics{}
+
+	stopChan := make(chan struct{})
+	var wg sync.WaitGroup
+	startCPUThreads(stopChan, config, cpuStats, metrics, &wg)
+	time.Sleep(selfTestDuration)
+	close(stopChan)
+	wg.Wait()
+
+	primesPerSec := metrics.Snapshot().CPUPrimesPerSec
+	if primesPerSec <= 0 {
+		return "", errors.New("no primes computed")
+	}
+	return fmt.Sprintf("%s primes/sec", formatWithCommas(primesPerSec)), nil
+}
+
+func selfTestMemory(config Config) (string, error) {
+	config = selfTestConfig(config)
+	if getAvailableMemory(config) == fallbackMemory {
+		return "", errors.New("available memory could not be detected")
+	}
+
+	metrics := &Metrics{}
+	allocator := newChunkAllocator(config)
+	defer allocator.release()
+	chunks, ok := allocateMemory(make(chan struct{}), config, allocator, metrics, nil)
+	if !ok || len(chunks) == 0 {
+		return "", errors.New("allocation failed")
+	}
+
+	fillMBps := metrics.Snapshot().MemoryFillMBps
+	if fillMBps <= 0 {
+		return "", errors.New("no fill throughput measured")
+	}
+	return fmt.Sprintf("fill %s", formatMBps(fillMBps, config.units)), nil
+}
+
+func selfTestDisk(config Config) (string, error) {
+	config = selfTestConfig(config)
+	chunks := [][]byte{make([]byte, chunkBytes(config))}
+	metrics := &Metrics{}
+	failures := &FailureLog{}
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		filesystemBenchmark(chunks, stopChan, config, &DiskStats{}, metrics, failures)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(selfTestDuration):
+	}
+	close(stopChan)
+	<-done
+
+	if recorded := failures.Failures(); len(recorded) > 0 {
+		return "", errors.New(recorded[0].Message)
+	}
+	snapshot := metrics.Snapshot()
+	if snapshot.DiskWriteMBps <= 0 || snapshot.DiskReadMBps <= 0 {
+		return "", fmt.Errorf("no disk throughput measured in %s", config.diskPath)
+	}
+	return fmt.Sprintf("write %s, read %s", formatMBps(snapshot.DiskWriteMBps, config.units), formatMBps(snapshot.DiskReadMBps, config.units)), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelfTestConfig(t *testing.T) {
+	config := selfTestConfig(Config{primeRange: 10000000, reportInterval: 5, full: true})
+
+	if config.primeRange != 100000 {
+		t.Errorf("selfTestConfig() primeRange = %d, expected 100000", config.primeRange)
+	}
+	if config.full {
+		t.Errorf("selfTestConfig() should disable full output")
+	}
+
+	small := selfTestConfig(Config{primeRange: 1000})
+	if small.primeRange != 1000 {
+		t.Errorf("selfTestConfig() primeRange = %d, expected smaller range 1000 to be kept", small.primeRange)
+	}
+}
+
+func TestSelfTestCPU(t *testing.T) {
+	result, err := selfTestCPU(Config{primeRange: 10000, cpuThreads: 1})
+	if err != nil {
+		t.Fatalf("selfTestCPU() returned error: %v", err)
+	}
+	if !strings.Contains(result, "primes/sec") {
+		t.Errorf("selfTestCPU() = %q, expected a primes/sec result", result)
+	}
+}
+
+func TestSelfTestDisk(t *testing.T) {
+	if _, err := selfTestDisk(Config{diskPath: t.TempDir()}); err != nil {
+		t.Errorf("selfTestDisk() on a writable path returned error: %v", err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	if _, err := selfTestDisk(Config{diskPath: missing}); err == nil {
+		t.Errorf("selfTestDisk() on a missing path expected an error")
+	}
+}
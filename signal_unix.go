@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyReopen(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
+
+func notifyReset(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// Windows has no SIGUSR1, so the output file is never reopened
+func notifyReopen(c chan<- os.Signal) {}
+
+// Windows has no SIGUSR2, so stats cannot be reset by a signal
+func notifyReset(c chan<- os.Signal) {}
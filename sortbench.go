@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// sortValues returns the numbers 0 to size-1, which each iteration shuffles
+// and sorts again
+func sortValues(size int) []int {
+	values := make([]int, size)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
+// shuffleAndSort puts values in a random order drawn from rng and sorts them
+// again with sort.Ints
+func shuffleAndSort(values []int, rng *rand.Rand) {
+	rng.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+	sort.Ints(values)
+}
+
+// newSortIteration shuffles and sorts -sort-size integers per iteration,
+// counting each element as an operation. The shuffle is seeded like the
+// other workloads, so -seed repeats the same orders.
+func newSortIteration(threadID int, config Config) func() int {
+	values := sortValues(config.sortSize)
+	rng := rand.New(rand.NewSource(workloadSeed(threadID, config)))
+	return func() int {
+		shuffleAndSort(values, rng)
+		return len(values)
+	}
+}
+
+func formatSortRate(elementsPerSec float64, config Config) string {
+	return fmt.Sprintf("%s elements/sec, %.2f sorts/sec", formatWithCommas(elementsPerSec), elementsPerSec/float64(config.sortSize))
+}
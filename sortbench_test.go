@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestShuffleAndSort(t *testing.T) {
+	values := sortValues(10000)
+	rng := rand.New(rand.NewSource(42))
+
+	rng.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+	if sort.IntsAreSorted(values) {
+		t.Fatal("Shuffled values are still sorted, the test would prove nothing")
+	}
+
+	for pass := 0; pass < 3; pass++ {
+		shuffleAndSort(values, rng)
+		// Sorted, and still exactly the numbers 0 to size-1
+		for i, v := range values {
+			if v != i {
+				t.Fatalf("Pass %d: values[%d] = %d after sorting, expected %d", pass, i, v, i)
+			}
+		}
+	}
+}
+
+func TestSortIteration(t *testing.T) {
+	config := Config{sortSize: 1000, seed: 7}
+	if ops := newSortIteration(0, config)(); ops != 1000 {
+		t.Errorf("Sort iteration reported %d ops, expected 1000", ops)
+	}
+
+	if rate := formatSortRate(5000, config); rate != "5,000 elements/sec, 5.00 sorts/sec" {
+		t.Errorf("formatSortRate(5000) = %q, expected 5 sorts/sec", rate)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// percentile returns the p-th percentile (0-100) of sorted samples using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// perSecond divides count by duration. Without measurable time, or when the
+// result is not finite, it returns 0, so a report that fires early shows no
+// rate instead of Inf or NaN.
+func perSecond(count float64, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	rate := count / duration.Seconds()
+	if math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return 0
+	}
+	return rate
+}
+
+// mbPerSecond is perSecond for a byte count, in MiB/s
+func mbPerSecond(bytes int64, duration time.Duration) float64 {
+	return perSecond(float64(bytes)/(1024*1024), duration)
+}
+
+// throughputWindow accumulates bytes and the time spent moving them until
+// the next report, so a report can show the recent rate next to the average
+type throughputWindow struct {
+	bytes    int64
+	duration time.Duration
+}
+
+func (w *throughputWindow) Add(bytes int64, duration time.Duration) {
+	w.bytes += bytes
+	w.duration += duration
+}
+
+// Take returns the rate in MiB/s since the previous Take and starts a new window
+func (w *throughputWindow) Take() float64 {
+	mbps := mbPerSecond(w.bytes, w.duration)
+	*w = throughputWindow{}
+	return mbps
+}
+
+// Reservoir keeps a uniform random sample of at most size durations, so
+// percentiles over a long run need bounded memory.
+type Reservoir struct {
+	mu      sync.Mutex
+	rng     *rand.Rand
+	samples []time.Duration
+	size    int
+	seen    int64
+}
+
+func newReservoir(size int, seed int64) *Reservoir {
+	return &Reservoir{rng: rand.New(rand.NewSource(seed)), size: size}
+}
+
+// Add offers a sample. Once the reservoir is full, the n-th sample replaces
+// a random kept one with probability size/n.
+func (r *Reservoir) Add(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen++
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, d)
+		return
+	}
+	if i := r.rng.Int63n(r.seen); i < int64(r.size) {
+		r.samples[i] = d
+	}
+}
+
+// Reset drops all samples, so percentiles start over
+func (r *Reservoir) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = r.samples[:0]
+	r.seen = 0
+}
+
+// Percentile returns the p-th percentile (0-100) of the kept samples
+func (r *Reservoir) Percentile(p float64) time.Duration {
+	r.mu.Lock()
+	sorted := append([]time.Duration(nil), r.samples...)
+	r.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return percentile(sorted, p)
+}
+
+// latencyStats adds the average over every sample, which a Reservoir loses
+// once it drops samples, to a Reservoir for the percentiles. It is only
+// used by the goroutine that adds the samples.
+type latencyStats struct {
+	reservoir *Reservoir
+	count     int64
+	total     time.Duration
+}
+
+func newLatencyStats(size int, seed int64) *latencyStats {
+	return &latencyStats{reservoir: newReservoir(size, seed)}
+}
+
+func (s *latencyStats) Add(d time.Duration) {
+	s.count++
+	s.total += d
+	s.reservoir.Add(d)
+}
+
+// Average returns the mean of all samples added since the last Reset
+func (s *latencyStats) Average() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.count)
+}
+
+func (s *latencyStats) Percentile(p float64) time.Duration {
+	return s.reservoir.Percentile(p)
+}
+
+func (s *latencyStats) Reset() {
+	s.count, s.total = 0, 0
+	s.reservoir.Reset()
+}
+
+// milliseconds converts d to fractional milliseconds for reports
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Microsecond)
+	}
+
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 1 * time.Microsecond},
+		{50, 50 * time.Microsecond},
+		{90, 90 * time.Microsecond},
+		{99, 99 * time.Microsecond},
+		{99.9, 100 * time.Microsecond},
+		{100, 100 * time.Microsecond},
+	}
+
+	for _, test := range tests {
+		result := percentile(samples, test.p)
+		if result != test.expected {
+			t.Errorf("percentile(1..100µs, %g) = %v, expected %v", test.p, result, test.expected)
+		}
+	}
+}
+
+func TestPercentileSmallSamples(t *testing.T) {
+	if result := percentile(nil, 99); result != 0 {
+		t.Errorf("percentile(nil, 99) = %v, expected 0", result)
+	}
+
+	single := []time.Duration{7 * time.Millisecond}
+	if result := percentile(single, 50); result != 7*time.Millisecond {
+		t.Errorf("percentile([7ms], 50) = %v, expected 7ms", result)
+	}
+}
+
+func TestReservoirBounded(t *testing.T) {
+	reservoir := newReservoir(100, 1)
+	for i := 0; i < 100000; i++ {
+		reservoir.Add(time.Duration(i))
+	}
+	if len(reservoir.samples) != 100 {
+		t.Errorf("Reservoir kept %d samples, expected at most 100", len(reservoir.samples))
+	}
+	if reservoir.seen != 100000 {
+		t.Errorf("Reservoir counted %d samples, expected 100000", reservoir.seen)
+	}
+}
+
+func TestReservoirPercentile(t *testing.T) {
+	// A uniform distribution over 0-999µs keeps its shape when sampled
+	reservoir := newReservoir(1000, 1)
+	for i := 0; i < 100000; i++ {
+		reservoir.Add(time.Duration(i%1000) * time.Microsecond)
+	}
+
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{50, 500 * time.Microsecond},
+		{90, 900 * time.Microsecond},
+		{99, 990 * time.Microsecond},
+	}
+	for _, test := range tests {
+		result := reservoir.Percentile(test.p)
+		if diff := result - test.expected; diff < -50*time.Microsecond || diff > 50*time.Microsecond {
+			t.Errorf("Reservoir.Percentile(%g) = %v, expected about %v", test.p, result, test.expected)
+		}
+	}
+}
+
+func TestReservoirReset(t *testing.T) {
+	reservoir := newReservoir(10, 1)
+	for i := 0; i < 100; i++ {
+		reservoir.Add(time.Second)
+	}
+	reservoir.Reset()
+	if result := reservoir.Percentile(50); result != 0 || reservoir.seen != 0 {
+		t.Errorf("Reset() left percentile %v and %d seen, expected none", result, reservoir.seen)
+	}
+	// Samples added after a reset fill the reservoir again
+	reservoir.Add(time.Millisecond)
+	if result := reservoir.Percentile(50); result != time.Millisecond {
+		t.Errorf("Percentile() after Reset() = %v, expected 1ms", result)
+	}
+}
+
+func TestReservoirEmpty(t *testing.T) {
+	if result := newReservoir(10, 1).Percentile(99); result != 0 {
+		t.Errorf("Percentile() of an empty reservoir = %v, expected 0", result)
+	}
+	// A zero-size reservoir keeps nothing
+	reservoir := newReservoir(0, 1)
+	reservoir.Add(time.Millisecond)
+	if result := reservoir.Percentile(50); result != 0 {
+		t.Errorf("Percentile() of a zero-size reservoir = %v, expected 0", result)
+	}
+}
+
+func TestPerSecond(t *testing.T) {
+	tests := []struct {
+		count    float64
+		duration time.Duration
+		expected float64
+	}{
+		{100, time.Second, 100},
+		{100, 500 * time.Millisecond, 200},
+		{0, time.Second, 0},
+		// A report before any measurable time has passed
+		{100, 0, 0},
+		{0, 0, 0},
+		{100, -time.Second, 0},
+		{math.Inf(1), time.Second, 0},
+		{math.NaN(), time.Second, 0},
+	}
+
+	for _, test := range tests {
+		if rate := perSecond(test.count, test.duration); rate != test.expected {
+			t.Errorf("perSecond(%v, %v) = %v, expected %v", test.count, test.duration, rate, test.expected)
+		}
+	}
+}
+
+func TestMBPerSecond(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		duration time.Duration
+		expected float64
+	}{
+		{100 * 1024 * 1024, time.Second, 100},
+		{0, time.Second, 0},
+		{100 * 1024 * 1024, 0, 0},
+		{0, 0, 0},
+	}
+
+	for _, test := range tests {
+		if mbps := mbPerSecond(test.bytes, test.duration); mbps != test.expected {
+			t.Errorf("mbPerSecond(%d, %v) = %v, expected %v", test.bytes, test.duration, mbps, test.expected)
+		}
+	}
+}
+
+func TestThroughputWindow(t *testing.T) {
+	var window throughputWindow
+	if mbps := window.Take(); mbps != 0 {
+		t.Errorf("Take() of an empty window = %f, expected 0", mbps)
+	}
+
+	window.Add(100*1024*1024, time.Second)
+	window.Add(100*1024*1024, time.Second)
+	if mbps := window.Take(); mbps != 100 {
+		t.Errorf("Take() = %f MiB/s, expected 100", mbps)
+	}
+
+	// Each Take starts a fresh window, so a slowdown shows up at once
+	window.Add(10*1024*1024, time.Second)
+	if mbps := window.Take(); mbps != 10 {
+		t.Errorf("Take() after the previous window = %f MiB/s, expected 10", mbps)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	stats := newLatencyStats(2, 1)
+	if average := stats.Average(); average != 0 {
+		t.Errorf("Average() without samples = %v, expected 0", average)
+	}
+
+	// The average covers samples the full reservoir no longer keeps
+	for _, d := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 10 * time.Millisecond} {
+		stats.Add(d)
+	}
+	if average := stats.Average(); average != 4*time.Millisecond {
+		t.Errorf("Average() = %v, expected 4ms", average)
+	}
+	if p99 := stats.Percentile(99); p99 > 10*time.Millisecond || p99 == 0 {
+		t.Errorf("Percentile(99) = %v, expected a kept sample", p99)
+	}
+	if ms := milliseconds(stats.Average()); ms != 4 {
+		t.Errorf("milliseconds(4ms) = %g, expected 4", ms)
+	}
+
+	stats.Reset()
+	if average, p99 := stats.Average(), stats.Percentile(99); average != 0 || p99 != 0 {
+		t.Errorf("Reset() left average %v and p99 %v, expected none", average, p99)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Share of steal time from which CPU results are flagged as unreliable
+const stealWarnPercent = 5.0
+
+// cpuTimes are the jiffies of the aggregate "cpu" line of /proc/stat
+type cpuTimes struct {
+	total uint64
+	steal uint64
+}
+
+// parseProcStatSteal reads the total and steal time of all CPUs from
+// /proc/stat. Guest time is already part of user time and left out of the
+// total; kernels before 2.6.11 have no steal column.
+func parseProcStatSteal(stat string) (cpuTimes, bool) {
+	for _, line := range strings.Split(stat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+		var times cpuTimes
+		// user nice system idle iowait irq softirq steal
+		for i, field := range fields[1:9] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, false
+			}
+			times.total += value
+			if i == 7 {
+				times.steal = value
+			}
+		}
+		return times, true
+	}
+	return cpuTimes{}, false
+}
+
+// stealPercent is the share of the CPU time between two reads that the
+// hypervisor gave to other guests
+func stealPercent(before, after cpuTimes) float64 {
+	if after.total <= before.total || after.steal < before.steal {
+		return 0
+	}
+	return float64(after.steal-before.steal) / float64(after.total-before.total) * 100
+}
+
+func readCPUTimes() (cpuTimes, bool) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, false
+	}
+	return parseProcStatSteal(string(data))
+}
+
+// StealMonitor tracks the CPU steal time of a virtual machine over the run
+// and per report interval
+type StealMonitor struct {
+	mu         sync.Mutex
+	start      cpuTimes
+	last       cpuTimes
+	maxPercent float64
+}
+
+// newStealMonitor returns nil where steal time cannot be read, which is
+// everywhere but Linux
+func newStealMonitor() *StealMonitor {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	times, ok := readCPUTimes()
+	if !ok {
+		return nil
+	}
+	return &StealMonitor{start: times, last: times}
+}
+
+// Update adds the interval since the previous update, reports its steal
+// share when there was any and publishes the run's average and maximum
+func (m *StealMonitor) Update(metrics *Metrics) {
+	times, ok := readCPUTimes()
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	interval := stealPercent(m.last, times)
+	m.last = times
+	m.maxPercent = math.Max(m.maxPercent, interval)
+	average, max := stealPercent(m.start, times), m.maxPercent
+	m.mu.Unlock()
+
+	metrics.Update(func(snapshot *MetricsSnapshot) {
+		snapshot.CPUStealPercent = average
+		snapshot.CPUStealMaxPercent = max
+	})
+	if interval >= stealWarnPercent {
+		fmt.Printf("WARNING: CPU steal %.1f%% in the last interval, the hypervisor is taking CPU time and CPU results are not reliable\n", interval)
+	} else if interval > 0 {
+		fmt.Printf("CPU steal: %.1f%% in the last interval\n", interval)
+	}
+}
+
+func stealMonitorLoop(stopChan <-chan struct{}, config Config, monitor *StealMonitor, metrics *Metrics) {
+	ticker := time.NewTicker(time.Duration(config.reportInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			monitor.Update(metrics)
+			return
+		case <-ticker.C:
+			monitor.Update(metrics)
+		}
+	}
+}
+
+// formatSteal summarizes the steal time of the run, flagging a run whose
+// average or worst interval reached stealWarnPercent
+func formatSteal(average, max float64) string {
+	text := fmt.Sprintf("CPU steal: %.1f%% average, %.1f%% in the worst interval", average, max)
+	if average >= stealWarnPercent || max >= stealWarnPercent {
+		text = "WARNING: " + text + ", CPU results are not reliable"
+	}
+	return text
+}
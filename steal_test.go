@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseProcStatSteal(t *testing.T) {
+	tests := []struct {
+		stat     string
+		expected cpuTimes
+		ok       bool
+	}{
+		{"cpu  142883 0 20972 448384 5253 0 13 1957 0 0\ncpu0 142883 0 20972 448384 5253 0 13 1957 0 0\n", cpuTimes{total: 619462, steal: 1957}, true},
+		// Guest time is part of user time and not counted twice
+		{"cpu  100 0 100 700 0 0 0 100 50 0\n", cpuTimes{total: 1000, steal: 100}, true},
+		// Kernels without a steal column
+		{"cpu  100 0 100 700 0 0 0\n", cpuTimes{}, false},
+		{"cpu0 100 0 100 700 0 0 0 100 0 0\n", cpuTimes{}, false},
+		{"cpu  100 0 x 700 0 0 0 100 0 0\n", cpuTimes{}, false},
+		{"", cpuTimes{}, false},
+	}
+
+	for _, test := range tests {
+		times, ok := parseProcStatSteal(test.stat)
+		if ok != test.ok || times != test.expected {
+			t.Errorf("parseProcStatSteal(%q) = %+v, %v, expected %+v, %v", test.stat, times, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestStealPercent(t *testing.T) {
+	tests := []struct {
+		before, after cpuTimes
+		expected      float64
+	}{
+		{cpuTimes{total: 1000, steal: 10}, cpuTimes{total: 1400, steal: 50}, 10},
+		{cpuTimes{total: 1000, steal: 10}, cpuTimes{total: 1400, steal: 10}, 0},
+		// No time passed between the reads
+		{cpuTimes{total: 1000, steal: 10}, cpuTimes{total: 1000, steal: 10}, 0},
+	}
+	for _, test := range tests {
+		if result := stealPercent(test.before, test.after); math.Abs(result-test.expected) > 1e-9 {
+			t.Errorf("stealPercent(%+v, %+v) = %f, expected %f", test.before, test.after, result, test.expected)
+		}
+	}
+}
+
+func TestFormatSteal(t *testing.T) {
+	if result := formatSteal(1.2, 3.4); result != "CPU steal: 1.2% average, 3.4% in the worst interval" {
+		t.Errorf("formatSteal(1.2, 3.4) = %q", result)
+	}
+	if result := formatSteal(2, 12); !strings.HasPrefix(result, "WARNING: ") {
+		t.Errorf("formatSteal() with a 12%% interval = %q, expected a warning", result)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type GCStats struct {
+	Cycles     uint32        `json:"cycles"`
+	TotalPause time.Duration `json:"total_pause_ns"`
+	MaxPause   time.Duration `json:"max_pause_ns"`
+}
+
+type DiskSummary struct {
+	BytesWritten int64 `json:"bytes_written"`
+	BytesRead    int64 `json:"bytes_read"`
+	Iterations   int64 `json:"iterations"`
+
+	// Blocks checked by -disk-direct-io-verify and checksum mismatches
+	VerifiedBlocks int64 `json:"verified_blocks,omitempty"`
+	Mismatches     int64 `json:"checksum_mismatches,omitempty"`
+
+	// Size of the -disk-sparse file and the space it takes up on disk, -1
+	// when the platform cannot tell
+	SparseLogical int64 `json:"sparse_logical_bytes,omitempty"`
+	SparseAlloc   int64 `json:"sparse_allocated_bytes,omitempty"`
+
+	// Filesystem type of every disk path, if it could be detected
+	Filesystems map[string]string `json:"filesystems,omitempty"`
+}
+
+type Summary struct {
+	SchemaVersion int                    `json:"schema_version"`
+	Host          string                 `json:"host"`
+	Timestamp     string                 `json:"timestamp"`
+	Tags          map[string]string      `json:"tags,omitempty"`
+	Environment   Environment            `json:"environment"`
+	Metrics       MetricsSnapshot        `json:"metrics"`
+	GC            GCStats                `json:"gc"`
+	Disk          *DiskSummary           `json:"disk,omitempty"`
+	Runtime       *RuntimeStats          `json:"runtime,omitempty"`
+	Sweep         []SweepResult          `json:"cpu_range_sweep,omitempty"`
+	DiskSweep     []BlockSizeSweepResult `json:"disk_block_size_sweep,omitempty"`
+	Swapping      bool                   `json:"swapping"`
+	Threads       []ThreadSummary        `json:"cpu_threads,omitempty"`
+}
+
+// ThreadSummary is one CPU thread's share of the run, in the unit of the
+// workload
+type ThreadSummary struct {
+	Thread     int     `json:"thread"`
+	Iterations int64   `json:"iterations"`
+	PerSec     float64 `json:"per_sec"`
+}
+
+// printThreadSummaries prints a row per CPU thread, so a parked or slower
+// core stands out against the others
+func printThreadSummaries(w io.Writer, threads []ThreadSummary, config Config) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CPU Thread\tIterations\tAverage")
+	for _, thread := range threads {
+		fmt.Fprintf(tw, "%d\t%d\t%s\n", thread.Thread, thread.Iterations, workloadRate(config.cpuWorkload, thread.PerSec, config))
+	}
+	tw.Flush()
+}
+
+// formatCPUTotal renders the CPU rate across all threads in the unit of the
+// workload, one rate per workload when they rotate. Idle-spin measures
+// latency rather than a rate, so it and a disabled CPU test have none.
+func formatCPUTotal(metrics MetricsSnapshot, config Config) string {
+	switch {
+	case config.disableCPU || config.cpuWorkload == "idle-spin":
+		return ""
+	case rotatingWorkloads(config):
+		rates := make([]string, 0, len(metrics.CPUWorkloadRates))
+		for _, name := range cpuWorkloadList(config) {
+			rates = append(rates, name+" "+workloadRate(name, metrics.CPUWorkloadRates[name], config))
+		}
+		return strings.Join(rates, ", ")
+	case config.cpuWorkload == "prime":
+		return workloadRate("prime", metrics.CPUPrimesPerSec, config)
+	}
+	return workloadRate(config.cpuWorkload, metrics.CPUOpsPerSec, config)
+}
+
+func gcStatsBetween(start, end *runtime.MemStats) GCStats {
+	stats := GCStats{
+		Cycles:     end.NumGC - start.NumGC,
+		TotalPause: time.Duration(end.PauseTotalNs - start.PauseTotalNs),
+	}
+
+	// PauseNs is a circular buffer holding only the most recent pauses
+	recent := stats.Cycles
+	if recent > uint32(len(end.PauseNs)) {
+		recent = uint32(len(end.PauseNs))
+	}
+	for i := uint32(0); i < recent; i++ {
+		idx := (end.NumGC - i + uint32(len(end.PauseNs)) - 1) % uint32(len(end.PauseNs))
+		pause := time.Duration(end.PauseNs[idx])
+		if pause > stats.MaxPause {
+			stats.MaxPause = pause
+		}
+	}
+	return stats
+}
+
+func printSummary(summary Summary, config Config) {
+	if config.format == "json" {
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding summary: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(summary.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", formatTags(summary.Tags))
+	}
+	if summary.Environment.Hypervisor != "" {
+		fmt.Printf("Virtualization: %s\n", summary.Environment.Hypervisor)
+	}
+	if summary.Swapping {
+		fmt.Println("WARNING: System was swapping during the run, results are not reliable")
+	}
+	if summary.Metrics.CPUStealMaxPercent > 0 {
+		fmt.Println(formatSteal(summary.Metrics.CPUStealPercent, summary.Metrics.CPUStealMaxPercent))
+	}
+	if summary.Metrics.MemoryAllocated > 0 && summary.Metrics.MemoryAvailable != nil {
+		fmt.Printf("Memory: %s\n", formatAllocation(summary.Metrics.MemoryRequested,
+			summary.Metrics.MemoryAllocated, *summary.Metrics.MemoryAvailable, config.units))
+	}
+	if summary.Metrics.DiskSyncWriteP99Ms > 0 {
+		fmt.Printf("Disk: durable write latency p50 %.2fms, p99 %.2fms\n", summary.Metrics.DiskSyncWriteP50Ms, summary.Metrics.DiskSyncWriteP99Ms)
+	}
+	if summary.Metrics.DiskFsyncAvgMs > 0 {
+		fmt.Printf("Disk: fsync %.2fms avg, p99 %.2fms\n", summary.Metrics.DiskFsyncAvgMs, summary.Metrics.DiskFsyncP99Ms)
+	}
+	if len(summary.Metrics.DiskPatternRates) > 0 {
+		fmt.Printf("Disk: avg write by pattern %s\n", formatPatternRates(summary.Metrics.DiskPatternRates, config.units))
+	}
+	if summary.Disk != nil {
+		// The reports show the last interval, the summary keeps the whole run
+		fmt.Printf("Disk: avg write %s, read %s\n",
+			formatMBps(summary.Metrics.DiskWriteMBps, config.units), formatMBps(summary.Metrics.DiskReadMBps, config.units))
+		fmt.Printf("Disk: total written %s, read %s over %d iterations\n",
+			formatBytes(summary.Disk.BytesWritten, config.units), formatBytes(summary.Disk.BytesRead, config.units), summary.Disk.Iterations)
+		if summary.Disk.VerifiedBlocks > 0 {
+			fmt.Printf("Disk: %d blocks verified with direct I/O, %d checksum mismatches\n",
+				summary.Disk.VerifiedBlocks, summary.Disk.Mismatches)
+		}
+		if summary.Disk.SparseLogical > 0 {
+			fmt.Printf("Disk: sparse file %s\n", formatSparseSize(summary.Disk.SparseLogical, summary.Disk.SparseAlloc, config.units))
+		}
+		printDiskFilesystems(summary.Disk.Filesystems)
+	}
+	if total := formatCPUTotal(summary.Metrics, config); total != "" {
+		fmt.Printf("CPU: total %s\n", total)
+	}
+	if len(summary.Threads) > 0 {
+		printThreadSummaries(os.Stdout, summary.Threads, config)
+	}
+	fmt.Printf("GC: %d cycles, total pause %.2f ms, max %.2f ms\n",
+		summary.GC.Cycles, summary.GC.TotalPause.Seconds()*1000, summary.GC.MaxPause.Seconds()*1000)
+	if summary.Runtime != nil {
+		printRuntimeStats(*summary.Runtime, config)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGCStatsBetween(t *testing.T) {
+	start := &runtime.MemStats{NumGC: 10, PauseTotalNs: 5000}
+	end := &runtime.MemStats{NumGC: 13, PauseTotalNs: 9000}
+	end.PauseNs[9] = 7000 // before the run, must be ignored
+	end.PauseNs[10] = 1000
+	end.PauseNs[11] = 2500
+	end.PauseNs[12] = 500
+
+	stats := gcStatsBetween(start, end)
+	if stats.Cycles != 3 {
+		t.Errorf("gcStatsBetween() cycles = %d, expected 3", stats.Cycles)
+	}
+	if stats.TotalPause != 4000*time.Nanosecond {
+		t.Errorf("gcStatsBetween() total pause = %v, expected 4µs", stats.TotalPause)
+	}
+	if stats.MaxPause != 2500*time.Nanosecond {
+		t.Errorf("gcStatsBetween() max pause = %v, expected 2.5µs", stats.MaxPause)
+	}
+}
+
+func TestGCStatsBetweenWrapsBuffer(t *testing.T) {
+	start := &runtime.MemStats{NumGC: 0}
+	end := &runtime.MemStats{NumGC: 300}
+	for i := range end.PauseNs {
+		end.PauseNs[i] = uint64(i)
+	}
+
+	stats := gcStatsBetween(start, end)
+	if stats.Cycles != 300 {
+		t.Errorf("gcStatsBetween() cycles = %d, expected 300", stats.Cycles)
+	}
+	if stats.MaxPause != 255*time.Nanosecond {
+		t.Errorf("gcStatsBetween() max pause = %v, expected 255ns", stats.MaxPause)
+	}
+}
+
+func TestSummaryJSON(t *testing.T) {
+	summary := Summary{
+		SchemaVersion: CurrentSchemaVersion,
+		Host:          "db-01",
+		Environment:   Environment{GOOS: "linux", GOARCH: "arm64", WordSize: 64, Endianness: "little", GoVersion: "go1.19"},
+		Metrics:       MetricsSnapshot{CPUPrimesPerSec: 1000},
+		GC:            GCStats{Cycles: 2, TotalPause: time.Millisecond},
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatalf("json.Marshal(summary) returned error: %v", err)
+	}
+
+	for _, field := range []string{`"schema_version":1`, `"host":"db-01"`, `"goarch":"arm64"`, `"word_size_bits":64`, `"endianness":"little"`, `"cpu_primes_per_sec":1000`, `"total_pause_ns":1000000`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Summary JSON missing %s: %s", field, data)
+		}
+	}
+}
+
+func TestFormatCPUTotal(t *testing.T) {
+	metrics := MetricsSnapshot{
+		CPUPrimesPerSec:  1500,
+		CPUOpsPerSec:     2000,
+		CPUWorkloadRates: map[string]float64{"prime": 1500, "branchy": 3000},
+	}
+	tests := []struct {
+		config   Config
+		expected string
+	}{
+		{Config{cpuWorkload: "prime"}, "1,500 primes/sec"},
+		{Config{cpuWorkload: "branchy"}, "2,000 ops/sec"},
+		{Config{cpuWorkload: "prime,branchy"}, "prime 1,500 primes/sec, branchy 3,000 ops/sec"},
+		{Config{cpuWorkload: "idle-spin"}, ""},
+		{Config{cpuWorkload: "prime", disableCPU: true}, ""},
+	}
+
+	for _, test := range tests {
+		if result := formatCPUTotal(metrics, test.config); result != test.expected {
+			t.Errorf("formatCPUTotal(%q) = %q, expected %q", test.config.cpuWorkload, result, test.expected)
+		}
+	}
+}
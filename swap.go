@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SwapMonitor compares swap usage against the start of the run. Once the
+// system swaps, memory and disk results no longer measure the hardware.
+type SwapMonitor struct {
+	baseline int64
+	swapping atomic.Bool
+}
+
+// newSwapMonitor records the current swap usage as the baseline. It returns
+// nil where swap usage cannot be read, so the run goes on unmonitored.
+func newSwapMonitor() *SwapMonitor {
+	used, ok := getSwapUsed()
+	if !ok {
+		return nil
+	}
+	return &SwapMonitor{baseline: used}
+}
+
+// Check reads the swap usage again and warns the first time it has grown
+func (m *SwapMonitor) Check(config Config) {
+	used, ok := getSwapUsed()
+	if !ok || used <= m.baseline {
+		return
+	}
+	if m.swapping.CompareAndSwap(false, true) {
+		fmt.Printf("WARNING: System is swapping (%s swapped out since start), results are not reliable\n",
+			formatBytes(used-m.baseline, config.units))
+	}
+}
+
+// Swapping reports whether swap usage grew at any check so far
+func (m *SwapMonitor) Swapping() bool {
+	return m != nil && m.swapping.Load()
+}
+
+func swapMonitorLoop(stopChan <-chan struct{}, config Config, monitor *SwapMonitor) {
+	ticker := time.NewTicker(time.Duration(config.reportInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			monitor.Check(config)
+			return
+		case <-ticker.C:
+			monitor.Check(config)
+		}
+	}
+}
+
+func getSwapUsed() (int64, bool) {
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/meminfo")
+		if err != nil {
+			return 0, false
+		}
+		return parseLinuxSwapUsed(string(data))
+	case "darwin":
+		output, err := exec.Command("sysctl", "vm.swapusage").Output()
+		if err != nil {
+			return 0, false
+		}
+		return parseDarwinSwapUsed(string(output))
+	default:
+		return 0, false
+	}
+}
+
+// parseLinuxSwapUsed returns SwapTotal minus SwapFree from /proc/meminfo
+func parseLinuxSwapUsed(meminfo string) (int64, bool) {
+	var total, free int64
+	var haveTotal, haveFree bool
+
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "SwapTotal:":
+			total, haveTotal = kb*1024, true
+		case "SwapFree:":
+			free, haveFree = kb*1024, true
+		}
+	}
+
+	if !haveTotal || !haveFree {
+		return 0, false
+	}
+	return total - free, true
+}
+
+// parseDarwinSwapUsed reads the used value from output such as
+// "vm.swapusage: total = 2048.00M  used = 1024.50M  free = 1023.50M  (encrypted)"
+func parseDarwinSwapUsed(output string) (int64, bool) {
+	fields := strings.Fields(output)
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] != "used" || fields[i+1] != "=" {
+			continue
+		}
+		used, err := parseSize(fields[i+2])
+		if err != nil {
+			return 0, false
+		}
+		return used, true
+	}
+	return 0, false
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseLinuxSwapUsed(t *testing.T) {
+	tests := []struct {
+		meminfo  string
+		expected int64
+		ok       bool
+	}{
+		{"MemTotal:       16384000 kB\nSwapTotal:       2097152 kB\nSwapFree:        2096128 kB\n", 1024 * 1024, true},
+		{"SwapTotal:             0 kB\nSwapFree:              0 kB\n", 0, true},
+		{"MemTotal:       16384000 kB\nSwapFree:        2096128 kB\n", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		used, ok := parseLinuxSwapUsed(test.meminfo)
+		if ok != test.ok || used != test.expected {
+			t.Errorf("parseLinuxSwapUsed(%q) = %d, %v, expected %d, %v", test.meminfo, used, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestParseDarwinSwapUsed(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected int64
+		ok       bool
+	}{
+		{"vm.swapusage: total = 2048.00M  used = 1024.50M  free = 1023.50M  (encrypted)\n", 1024*1024*1024 + 512*1024, true},
+		{"vm.swapusage: total = 0.00M  used = 0.00M  free = 0.00M  (encrypted)\n", 0, true},
+		{"vm.swapusage: total = 2048.00M\n", 0, false},
+		{"vm.swapusage: total = 2048.00M  used = lots\n", 0, false},
+	}
+
+	for _, test := range tests {
+		used, ok := parseDarwinSwapUsed(test.output)
+		if ok != test.ok || used != test.expected {
+			t.Errorf("parseDarwinSwapUsed(%q) = %d, %v, expected %d, %v", test.output, used, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestSwapMonitorNil(t *testing.T) {
+	// Platforms without swap information get no monitor
+	var monitor *SwapMonitor
+	if monitor.Swapping() {
+		t.Errorf("nil SwapMonitor reported swapping")
+	}
+}
+
+func TestSwapMonitorCheck(t *testing.T) {
+	if _, ok := getSwapUsed(); !ok {
+		t.Skip("Swap usage not available on this platform")
+	}
+
+	// Any current usage is growth over a negative baseline
+	monitor := &SwapMonitor{baseline: -1}
+	monitor.Check(Config{units: "binary"})
+	if !monitor.Swapping() {
+		t.Errorf("SwapMonitor did not flag growth over its baseline")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long each sweep step runs when -duration is not set
+const defaultSweepStepDuration = 5 * time.Second
+
+type SweepResult struct {
+	PrimeRange   int     `json:"prime_range"`
+	PrimesPerSec float64 `json:"primes_per_sec"`
+}
+
+// parseRangeSweep expands "start:end:step" into the prime ranges to test,
+// including end when it falls on a step.
+func parseRangeSweep(spec string) ([]int, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("sweep %q must have the form start:end:step", spec)
+	}
+
+	var values [3]int
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("sweep %q: %q is not a number", spec, part)
+		}
+		values[i] = value
+	}
+	start, end, step := values[0], values[1], values[2]
+
+	if start < 3 {
+		return nil, fmt.Errorf("sweep %q: start must be at least 3", spec)
+	}
+	if end < start {
+		return nil, fmt.Errorf("sweep %q: end must not be below start", spec)
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("sweep %q: step must be positive", spec)
+	}
+
+	var ranges []int
+	for r := start; r <= end; r += step {
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// runRangeSweep runs the prime benchmark once per range with fresh threads and
+// stats, printing a row per step as it completes.
+func runRangeSweep(sigChan <-chan os.Signal, config Config, metrics *Metrics) []SweepResult {
+	stepDuration := config.duration
+	if stepDuration == 0 {
+		stepDuration = defaultSweepStepDuration
+	}
+
+	fmt.Printf("=== CPU range sweep: %d steps of %v ===\n", len(config.cpuRangeSweep), stepDuration)
+	fmt.Printf("%12s  %15s\n", "Range", "Primes/sec")
+
+	var results []SweepResult
+	for _, primeRange := range config.cpuRangeSweep {
+		stepConfig := config
+		stepConfig.primeRange = primeRange
+		// Interval reports would interleave with the table, so only the row is printed
+		stepStats := newCPUStats(24 * time.Hour)
+
+		stepStop := make(chan struct{})
+		var wg sync.WaitGroup
+		startCPUThreads(stepStop, stepConfig, stepStats, metrics, &wg)
+		interrupted := waitForStop(sigChan, stepDuration)
+		close(stepStop)
+		wg.Wait()
+
+		result := SweepResult{PrimeRange: primeRange, PrimesPerSec: stepStats.TotalPrimesPerSec(config.cpuThreads)}
+		results = append(results, result)
+		fmt.Printf("%12s  %15s\n", formatWithCommas(float64(result.PrimeRange)), formatWithCommas(result.PrimesPerSec))
+
+		if interrupted {
+			if config.full {
+				fmt.Println("\nReceived interrupt signal, stopping sweep...")
+			}
+			break
+		}
+	}
+	return results
+}
+
+// rangeSweepValue is -cpu-range-sweep. String rebuilds a spec that expands
+// to the same ranges, so the flag round-trips through -dump-config.
+type rangeSweepValue []int
+
+func (v *rangeSweepValue) String() string {
+	if v == nil || len(*v) == 0 {
+		return ""
+	}
+	ranges := *v
+	step := 1
+	if len(ranges) > 1 {
+		step = ranges[1] - ranges[0]
+	}
+	return fmt.Sprintf("%d:%d:%d", ranges[0], ranges[len(ranges)-1], step)
+}
+
+func (v *rangeSweepValue) Set(spec string) error {
+	if spec == "" {
+		*v = nil
+		return nil
+	}
+	ranges, err := parseRangeSweep(spec)
+	*v = ranges
+	return err
+}
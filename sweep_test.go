@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRangeSweep(t *testing.T) {
+	tests := []struct {
+		spec       string
+		expected   []int
+		shouldFail bool
+	}{
+		{"1000:5000:1000", []int{1000, 2000, 3000, 4000, 5000}, false},
+		{"1000:4500:1000", []int{1000, 2000, 3000, 4000}, false},
+		{"100000:100000:1", []int{100000}, false},
+		{" 10 : 30 : 10 ", []int{10, 20, 30}, false},
+		{"1000:5000", nil, true},
+		{"1000:5000:1000:1", nil, true},
+		{"a:5000:1000", nil, true},
+		{"2:5000:1000", nil, true},
+		{"5000:1000:1000", nil, true},
+		{"1000:5000:0", nil, true},
+		{"1000:5000:-10", nil, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseRangeSweep(test.spec)
+		if (err != nil) != test.shouldFail {
+			t.Errorf("parseRangeSweep(%q) error = %v, expected shouldFail=%v", test.spec, err, test.shouldFail)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseRangeSweep(%q) = %v, expected %v", test.spec, result, test.expected)
+		}
+	}
+}
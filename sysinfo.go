@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+type Environment struct {
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	WordSize   int    `json:"word_size_bits"`
+	Endianness string `json:"endianness"`
+	GoVersion  string `json:"go_version"`
+	Hypervisor string `json:"hypervisor"`
+}
+
+type DarwinCPUInfo struct {
+	PerformanceCores int
+	EfficiencyCores  int
+}
+
+// parseSysctl parses "name: value" lines as printed by sysctl(8)
+func parseSysctl(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+func parseDarwinCPUInfo(output string) (DarwinCPUInfo, error) {
+	values := parseSysctl(output)
+
+	var info DarwinCPUInfo
+	pCores, err := strconv.Atoi(values["hw.perflevel0.logicalcpu"])
+	if err != nil {
+		return info, fmt.Errorf("parsing hw.perflevel0.logicalcpu: %w", err)
+	}
+	info.PerformanceCores = pCores
+
+	// Chips without efficiency cores only report perflevel0
+	if value, ok := values["hw.perflevel1.logicalcpu"]; ok {
+		eCores, err := strconv.Atoi(value)
+		if err != nil {
+			return info, fmt.Errorf("parsing hw.perflevel1.logicalcpu: %w", err)
+		}
+		info.EfficiencyCores = eCores
+	}
+	return info, nil
+}
+
+func getDarwinCPUInfo() (DarwinCPUInfo, error) {
+	// Query each level separately since sysctl fails on the first unknown name
+	var output strings.Builder
+	for _, name := range []string{"hw.perflevel0.logicalcpu", "hw.perflevel1.logicalcpu"} {
+		out, err := exec.Command("sysctl", name).Output()
+		if err != nil {
+			continue
+		}
+		output.Write(out)
+	}
+	return parseDarwinCPUInfo(output.String())
+}
+
+// parsePhysicalCores counts the distinct (physical id, core id) pairs in
+// /proc/cpuinfo, so SMT siblings count once. It returns 0 when the file has
+// no core ids, as on many ARM systems and some VMs.
+func parsePhysicalCores(cpuinfo string) int {
+	cores := make(map[[2]string]bool)
+	physicalID, coreID := "0", ""
+	for _, line := range strings.Split(cpuinfo+"\n", "\n") {
+		name, value, found := strings.Cut(line, ":")
+		switch {
+		case !found:
+			// A blank line ends one processor's block
+			if coreID != "" {
+				cores[[2]string{physicalID, coreID}] = true
+			}
+			physicalID, coreID = "0", ""
+		case strings.TrimSpace(name) == "physical id":
+			physicalID = strings.TrimSpace(value)
+		case strings.TrimSpace(name) == "core id":
+			coreID = strings.TrimSpace(value)
+		}
+	}
+	return len(cores)
+}
+
+// getPhysicalCores returns the number of physical cores, excluding SMT
+// siblings, or an error where it cannot be detected
+func getPhysicalCores() (int, error) {
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/cpuinfo")
+		if err != nil {
+			return 0, err
+		}
+		if cores := parsePhysicalCores(string(data)); cores > 0 {
+			return cores, nil
+		}
+		return 0, errors.New("no core ids in /proc/cpuinfo")
+	case "darwin":
+		output, err := exec.Command("sysctl", "hw.physicalcpu").Output()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(parseSysctl(string(output))["hw.physicalcpu"])
+	default:
+		return 0, fmt.Errorf("physical core detection is not supported on %s", runtime.GOOS)
+	}
+}
+
+func detectEnvironment() Environment {
+	return Environment{
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		WordSize:   strconv.IntSize,
+		Endianness: detectEndianness(),
+		GoVersion:  runtime.Version(),
+		Hypervisor: detectHypervisor(),
+	}
+}
+
+// DMI system vendors of hypervisors and clouds. Vendors that also build
+// physical machines only count when the CPU reports a hypervisor as well.
+var dmiPlatforms = []struct {
+	vendor      string
+	name        string
+	virtualOnly bool
+}{
+	{"QEMU", "KVM", true},
+	{"Red Hat", "KVM", true},
+	{"OpenStack", "OpenStack", true},
+	{"VMware", "VMware", true},
+	{"Xen", "Xen", true},
+	{"innotek", "VirtualBox", true},
+	{"Amazon EC2", "Amazon EC2", false},
+	{"Google", "Google Compute Engine", false},
+	{"Microsoft Corporation", "Hyper-V", false},
+}
+
+// parseHypervisor names the virtualization platform from the DMI system
+// vendor and the flags in /proc/cpuinfo. Only x86 lists flags, where a
+// missing hypervisor flag shows bare metal. Without them, as on ARM, a
+// machine that no vendor marks as virtual is "unknown".
+func parseHypervisor(sysVendor string, cpuinfo string) string {
+	sysVendor = strings.TrimSpace(sysVendor)
+	virtual, listed := hasCPUFlag(cpuinfo, "hypervisor")
+	for _, platform := range dmiPlatforms {
+		if strings.HasPrefix(sysVendor, platform.vendor) && (virtual || platform.virtualOnly) {
+			return platform.name
+		}
+	}
+	switch {
+	case virtual:
+		return "unknown hypervisor"
+	case listed:
+		return "bare-metal"
+	default:
+		return "unknown"
+	}
+}
+
+// hasCPUFlag reports whether cpuinfo lists flag, and whether it lists CPU
+// flags at all
+func hasCPUFlag(cpuinfo string, flag string) (has, listed bool) {
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) != "flags" {
+			continue
+		}
+		for _, field := range strings.Fields(value) {
+			if field == flag {
+				return true, true
+			}
+		}
+		// Every processor lists the same flags
+		return false, true
+	}
+	return false, false
+}
+
+// detectHypervisor reports whether the tool runs in a virtual machine, and
+// on which platform, or "unknown" where it cannot tell
+func detectHypervisor() string {
+	switch runtime.GOOS {
+	case "linux":
+		// DMI is missing on many ARM boards, which leaves the cpuinfo flag
+		sysVendor, _ := os.ReadFile("/sys/class/dmi/id/sys_vendor")
+		cpuinfo, err := os.ReadFile("/proc/cpuinfo")
+		if err != nil && len(sysVendor) == 0 {
+			return "unknown"
+		}
+		return parseHypervisor(string(sysVendor), string(cpuinfo))
+	case "darwin":
+		output, err := exec.Command("sysctl", "kern.hv_vmm_present").Output()
+		if err != nil {
+			return "unknown"
+		}
+		if parseSysctl(string(output))["kern.hv_vmm_present"] == "1" {
+			return "unknown hypervisor"
+		}
+		return "bare-metal"
+	default:
+		return "unknown"
+	}
+}
+
+func detectEndianness() string {
+	// Look at which byte of a 16-bit value holds the low bits
+	value := uint16(1)
+	if *(*byte)(unsafe.Pointer(&value)) == 1 {
+		return "little"
+	}
+	return "big"
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestParseSysctl(t *testing.T) {
+	output := "hw.perflevel0.logicalcpu: 8\nhw.perflevel1.logicalcpu: 4\n\nkern.ostype: Darwin\n"
+	values := parseSysctl(output)
+
+	expected := map[string]string{
+		"hw.perflevel0.logicalcpu": "8",
+		"hw.perflevel1.logicalcpu": "4",
+		"kern.ostype":              "Darwin",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("parseSysctl() returned %d values, expected %d", len(values), len(expected))
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("parseSysctl()[%q] = %q, expected %q", name, values[name], value)
+		}
+	}
+}
+
+func TestParseDarwinCPUInfo(t *testing.T) {
+	tests := []struct {
+		output     string
+		expected   DarwinCPUInfo
+		shouldFail bool
+	}{
+		{"hw.perflevel0.logicalcpu: 8\nhw.perflevel1.logicalcpu: 4\n", DarwinCPUInfo{8, 4}, false},
+		{"hw.perflevel0.logicalcpu: 10\n", DarwinCPUInfo{10, 0}, false},
+		{"", DarwinCPUInfo{}, true},
+		{"hw.perflevel0.logicalcpu: eight\n", DarwinCPUInfo{}, true},
+		{"hw.perflevel0.logicalcpu: 8\nhw.perflevel1.logicalcpu: ?\n", DarwinCPUInfo{8, 0}, true},
+	}
+
+	for _, test := range tests {
+		info, err := parseDarwinCPUInfo(test.output)
+		if (err != nil) != test.shouldFail {
+			t.Errorf("parseDarwinCPUInfo(%q) error = %v, expected shouldFail=%v", test.output, err, test.shouldFail)
+			continue
+		}
+		if info != test.expected {
+			t.Errorf("parseDarwinCPUInfo(%q) = %+v, expected %+v", test.output, info, test.expected)
+		}
+	}
+}
+
+func TestGetDarwinCPUInfo(t *testing.T) {
+	if runtime.GOOS != "darwin" || runtime.GOARCH != "arm64" {
+		t.Skip("Skipping Apple Silicon-specific test")
+	}
+
+	info, err := getDarwinCPUInfo()
+	if err != nil {
+		t.Fatalf("getDarwinCPUInfo() returned error: %v", err)
+	}
+	if info.PerformanceCores+info.EfficiencyCores > runtime.NumCPU() {
+		t.Errorf("getDarwinCPUInfo() = %+v, more cores than NumCPU %d", info, runtime.NumCPU())
+	}
+}
+
+func TestDetectEndianness(t *testing.T) {
+	bigEndian := map[string]bool{
+		"mips": true, "mips64": true, "ppc64": true, "s390x": true, "sparc64": true,
+	}
+
+	expected := "little"
+	if bigEndian[runtime.GOARCH] {
+		expected = "big"
+	}
+	if result := detectEndianness(); result != expected {
+		t.Errorf("detectEndianness() = %q on %s, expected %q", result, runtime.GOARCH, expected)
+	}
+}
+
+func TestDetectEnvironment(t *testing.T) {
+	env := detectEnvironment()
+
+	if env.GOARCH != runtime.GOARCH || env.GOOS != runtime.GOOS {
+		t.Errorf("detectEnvironment() = %s/%s, expected %s/%s", env.GOOS, env.GOARCH, runtime.GOOS, runtime.GOARCH)
+	}
+	if env.WordSize != 32 && env.WordSize != 64 {
+		t.Errorf("detectEnvironment() word size = %d, expected 32 or 64", env.WordSize)
+	}
+	if env.GoVersion != runtime.Version() {
+		t.Errorf("detectEnvironment() Go version = %q, expected %q", env.GoVersion, runtime.Version())
+	}
+}
+
+func TestParsePhysicalCores(t *testing.T) {
+	// Two sockets, two cores each, two SMT siblings per core
+	var cpuinfo string
+	for processor := 0; processor < 8; processor++ {
+		cpuinfo += "processor\t: " + strconv.Itoa(processor) + "\n" +
+			"model name\t: Intel(R) Xeon(R) CPU\n" +
+			"physical id\t: " + strconv.Itoa(processor/4) + "\n" +
+			"siblings\t: 4\n" +
+			"core id\t\t: " + strconv.Itoa(processor%2) + "\n" +
+			"cpu cores\t: 2\n\n"
+	}
+
+	tests := []struct {
+		name     string
+		cpuinfo  string
+		expected int
+	}{
+		{"two sockets with SMT", cpuinfo, 4},
+		{"no trailing blank line", "processor\t: 0\ncore id\t\t: 3", 1},
+		{"single socket without physical id", "processor\t: 0\ncore id\t\t: 0\n\nprocessor\t: 1\ncore id\t\t: 1\n\n", 2},
+		{"ARM without core ids", "processor\t: 0\nBogoMIPS\t: 48.00\n\nprocessor\t: 1\nBogoMIPS\t: 48.00\n\n", 0},
+	}
+
+	for _, test := range tests {
+		if cores := parsePhysicalCores(test.cpuinfo); cores != test.expected {
+			t.Errorf("parsePhysicalCores(%s) = %d, expected %d", test.name, cores, test.expected)
+		}
+	}
+}
+
+func TestParseHypervisor(t *testing.T) {
+	const guestFlags = "flags\t\t: fpu vme de pse tsc msr hypervisor lahf_lm\n"
+	const hostFlags = "flags\t\t: fpu vme de pse tsc msr vmx lahf_lm\n"
+	const armCPUInfo = "processor\t: 0\nBogoMIPS\t: 48.00\nFeatures\t: fp asimd evtstrm aes pmull sha1 sha2 crc32\n"
+
+	tests := []struct {
+		sysVendor string
+		cpuinfo   string
+		expected  string
+	}{
+		{"QEMU\n", guestFlags, "KVM"},
+		{"VMware, Inc.\n", guestFlags, "VMware"},
+		{"Xen\n", "", "Xen"},
+		{"innotek GmbH\n", guestFlags, "VirtualBox"},
+		{"Amazon EC2\n", guestFlags, "Amazon EC2"},
+		{"Google\n", guestFlags, "Google Compute Engine"},
+		{"Microsoft Corporation\n", guestFlags, "Hyper-V"},
+		// Physical Surface devices and EC2 metal instances share the vendors
+		{"Microsoft Corporation\n", hostFlags, "bare-metal"},
+		{"Amazon EC2\n", hostFlags, "bare-metal"},
+		{"Dell Inc.\n", hostFlags, "bare-metal"},
+		{"", guestFlags, "unknown hypervisor"},
+		{"", hostFlags, "bare-metal"},
+		// Without any evidence either way
+		{"", "", "unknown"},
+		// ARM lists Features instead of flags
+		{"", armCPUInfo, "unknown"},
+		{"Amazon EC2\n", armCPUInfo, "unknown"},
+		{"QEMU\n", armCPUInfo, "KVM"},
+	}
+
+	for _, test := range tests {
+		if result := parseHypervisor(test.sysVendor, test.cpuinfo); result != test.expected {
+			t.Errorf("parseHypervisor(%q, %q) = %q, expected %q", test.sysVendor, test.cpuinfo, result, test.expected)
+		}
+	}
+}
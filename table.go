@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+)
+
+// tableRow is one line of the -table summary. Peak and samples are empty
+// for values measured once or not sampled during the run.
+type tableRow struct {
+	subsystem string
+	metric    string
+	average   string
+	peak      string
+	samples   string
+}
+
+// sampleRates reports whether the CPU rate of every report interval and the
+// disk throughput of every iteration are kept for the summary
+func sampleRates(config Config) bool {
+	return config.histogram || config.table
+}
+
+// peakAndCount returns the largest sample, formatted, and the sample count
+func peakAndCount(samples []float64, format func(float64) string) (string, string) {
+	if len(samples) == 0 {
+		return "", ""
+	}
+	peak := samples[0]
+	for _, sample := range samples {
+		peak = math.Max(peak, sample)
+	}
+	return format(peak), strconv.Itoa(len(samples))
+}
+
+// summaryTableRows collects the key metric of every subsystem that ran, with
+// cpuRates sampled per report interval and diskRates in MiB/s per iteration
+func summaryTableRows(summary Summary, config Config, cpuRates, diskRates []float64) []tableRow {
+	var rows []tableRow
+	metrics := summary.Metrics
+	switch {
+	case config.disableCPU || config.cpuWorkload == "idle-spin":
+	case rotatingWorkloads(config):
+		// The rotating workloads are not sampled per interval
+		for _, name := range cpuWorkloadList(config) {
+			rows = append(rows, tableRow{subsystem: "CPU", metric: name,
+				average: workloadRate(name, metrics.CPUWorkloadRates[name], config)})
+		}
+	case config.cpuWorkload == "prime":
+		row := tableRow{subsystem: "CPU", metric: "primes/sec", average: formatWithCommas(metrics.CPUPrimesPerSec)}
+		row.peak, row.samples = peakAndCount(cpuRates, formatWithCommas)
+		rows = append(rows, row)
+	default:
+		workload := opsWorkloads[config.cpuWorkload]
+		format := func(perSec float64) string { return workload.rate(perSec, config) }
+		row := tableRow{subsystem: "CPU", metric: config.cpuWorkload, average: format(metrics.CPUOpsPerSec)}
+		row.peak, row.samples = peakAndCount(cpuRates, format)
+		rows = append(rows, row)
+	}
+
+	formatRate := func(mbps float64) string { return formatMBps(mbps, config.units) }
+	if metrics.MemoryFillMBps > 0 {
+		rows = append(rows, tableRow{subsystem: "Memory", metric: "fill", average: formatRate(metrics.MemoryFillMBps), samples: "1"})
+	}
+	if metrics.MemoryVerifyMBps > 0 {
+		rows = append(rows, tableRow{subsystem: "Memory", metric: "verify", average: formatRate(metrics.MemoryVerifyMBps), samples: "1"})
+	}
+	if summary.Disk != nil {
+		row := tableRow{subsystem: "Disk", metric: "write", average: formatRate(metrics.DiskWriteMBps)}
+		row.peak, row.samples = peakAndCount(diskRates, formatRate)
+		rows = append(rows, row, tableRow{subsystem: "Disk", metric: "read", average: formatRate(metrics.DiskReadMBps)})
+		if metrics.DiskFsyncAvgMs > 0 {
+			rows = append(rows, tableRow{subsystem: "Disk", metric: "fsync",
+				average: fmt.Sprintf("%.2fms", metrics.DiskFsyncAvgMs)})
+		}
+	}
+	return rows
+}
+
+// printSummaryTable prints rows with aligned columns, a dash for empty cells
+func printSummaryTable(w io.Writer, rows []tableRow) {
+	if len(rows) == 0 {
+		return
+	}
+	dash := func(cell string) string {
+		if cell == "" {
+			return "-"
+		}
+		return cell
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Subsystem\tMetric\tAverage\tPeak\tSamples")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", row.subsystem, row.metric, row.average, dash(row.peak), dash(row.samples))
+	}
+	tw.Flush()
+}
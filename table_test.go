@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummaryTableRows(t *testing.T) {
+	config := Config{cpuWorkload: "prime", units: "binary"}
+	summary := Summary{
+		Metrics: MetricsSnapshot{CPUPrimesPerSec: 1500, MemoryFillMBps: 2048, DiskWriteMBps: 400, DiskReadMBps: 900},
+		Disk:    &DiskSummary{},
+	}
+	rows := summaryTableRows(summary, config, []float64{1400, 1600, 1500}, []float64{380, 420})
+
+	expected := []tableRow{
+		{"CPU", "primes/sec", "1,500", "1,600", "3"},
+		{"Memory", "fill", "2048.00 MiB/s", "", "1"},
+		{"Disk", "write", "400.00 MiB/s", "420.00 MiB/s", "2"},
+		{"Disk", "read", "900.00 MiB/s", "", ""},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("summaryTableRows() = %+v, expected %+v", rows, expected)
+	}
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("summaryTableRows()[%d] = %+v, expected %+v", i, rows[i], expected[i])
+		}
+	}
+
+	// Disabled subsystems have no row
+	config.disableCPU = true
+	summary.Disk = nil
+	if rows := summaryTableRows(summary, config, nil, nil); len(rows) != 1 || rows[0].subsystem != "Memory" {
+		t.Errorf("summaryTableRows() without CPU and disk = %+v, expected only the memory row", rows)
+	}
+}
+
+func TestPrintSummaryTable(t *testing.T) {
+	var out bytes.Buffer
+	printSummaryTable(&out, []tableRow{
+		{"CPU", "primes/sec", "1,500", "1,600", "3"},
+		{"Disk", "read", "900.00 MiB/s", "", ""},
+	})
+	expected := "Subsystem  Metric      Average       Peak   Samples\n" +
+		"CPU        primes/sec  1,500         1,600  3\n" +
+		"Disk       read        900.00 MiB/s  -      -\n"
+	if out.String() != expected {
+		t.Errorf("printSummaryTable() =\n%s\nexpected\n%s", out.String(), expected)
+	}
+
+	out.Reset()
+	if printSummaryTable(&out, nil); out.Len() != 0 {
+		t.Errorf("printSummaryTable() without rows printed %q", strings.TrimSpace(out.String()))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tag keys double as OpenMetrics label names, so they follow its syntax
+var tagKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func parseTag(s string) (string, string, error) {
+	key, value, found := strings.Cut(s, "=")
+	if !found {
+		return "", "", fmt.Errorf("tag %q must be key=value", s)
+	}
+	if !tagKeyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("tag key %q must start with a letter or underscore and contain only letters, digits and underscores", key)
+	}
+	if key == "host" {
+		return "", "", fmt.Errorf("tag key %q is reserved, use -host-label", key)
+	}
+	return key, value, nil
+}
+
+// tagsValue is a repeatable flag.Value collecting key=value tags
+type tagsValue map[string]string
+
+func (v *tagsValue) String() string {
+	return formatTags(*v)
+}
+
+func (v *tagsValue) Set(s string) error {
+	key, value, err := parseTag(s)
+	if err != nil {
+		return err
+	}
+	if *v == nil {
+		*v = make(map[string]string)
+	}
+	(*v)[key] = value
+	return nil
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatTags renders tags as "key=value" pairs sorted by key
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, key := range sortedTagKeys(tags) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
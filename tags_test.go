@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		input      string
+		key, value string
+		shouldFail bool
+	}{
+		{"region=eu-west-1", "region", "eu-west-1", false},
+		{"kernel=6.1.0=rc1", "kernel", "6.1.0=rc1", false},
+		{"note=", "note", "", false},
+		{"region", "", "", true},
+		{"=eu", "", "", true},
+		{"instance-type=m7i", "", "", true},
+		{"host=db-01", "", "", true},
+	}
+
+	for _, test := range tests {
+		key, value, err := parseTag(test.input)
+		if test.shouldFail {
+			if err == nil {
+				t.Errorf("parseTag(%q) expected an error", test.input)
+			}
+			continue
+		}
+		if err != nil || key != test.key || value != test.value {
+			t.Errorf("parseTag(%q) = %q, %q, %v, expected %q, %q", test.input, key, value, err, test.key, test.value)
+		}
+	}
+}
+
+func TestTagsValueRepeatable(t *testing.T) {
+	var tags map[string]string
+	value := (*tagsValue)(&tags)
+	for _, tag := range []string{"region=eu", "instance=m7i.large", "region=us"} {
+		if err := value.Set(tag); err != nil {
+			t.Fatalf("tagsValue.Set(%q) returned error: %v", tag, err)
+		}
+	}
+	if len(tags) != 2 || tags["region"] != "us" || tags["instance"] != "m7i.large" {
+		t.Errorf("tagsValue collected %v, expected the last region and the instance", tags)
+	}
+	if value.String() != "instance=m7i.large, region=us" {
+		t.Errorf("tagsValue.String() = %q, expected sorted pairs", value.String())
+	}
+}
+
+func TestWriteOpenMetricsTags(t *testing.T) {
+	config := Config{hostLabel: "db-01", tags: map[string]string{"region": "eu", "az": `a"b`}}
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, MetricsSnapshot{CPUPrimesPerSec: 1}, config); err != nil {
+		t.Fatalf("writeOpenMetrics() returned error: %v", err)
+	}
+	expected := `perftest_cpu_primes_per_second{host="db-01",az="a\"b",region="eu"} 1` + "\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("writeOpenMetrics() output missing %q:\n%s", expected, buf.String())
+	}
+}
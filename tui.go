@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many of the most recent output lines the dashboard shows
+const dashboardLogLines = 10
+
+const (
+	ansiEnterAltScreen = "\x1b[?1049h\x1b[?25l"
+	ansiLeaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	ansiHome           = "\x1b[H"
+	ansiClearLine      = "\x1b[K"
+	ansiClearBelow     = "\x1b[J"
+	ansiBold           = "\x1b[1m"
+	ansiReset          = "\x1b[0m"
+)
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Dashboard redraws live metrics in place on the terminal. Output hands it
+// the printed lines, which appear at the bottom of the dashboard, so the
+// regular reports do not scroll it away.
+type Dashboard struct {
+	terminal *os.File
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func startDashboard(terminal *os.File) *Dashboard {
+	fmt.Fprint(terminal, ansiEnterAltScreen)
+	return &Dashboard{terminal: terminal}
+}
+
+func (d *Dashboard) addLine(line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lines = append(d.lines, line)
+	if len(d.lines) > dashboardLogLines {
+		d.lines = d.lines[len(d.lines)-dashboardLogLines:]
+	}
+}
+
+func (d *Dashboard) Run(stopChan <-chan struct{}, config Config, metrics *Metrics, start time.Time) {
+	ticker := time.NewTicker(time.Duration(config.reportInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		d.mu.Lock()
+		lines := append([]string(nil), d.lines...)
+		d.mu.Unlock()
+		renderDashboard(d.terminal, metrics.Snapshot(), config, time.Since(start), lines)
+
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close restores the terminal's normal screen
+func (d *Dashboard) Close() {
+	fmt.Fprint(d.terminal, ansiLeaveAltScreen)
+}
+
+func renderDashboard(w io.Writer, snapshot MetricsSnapshot, config Config, uptime time.Duration, lines []string) {
+	var screen strings.Builder
+	row := func(format string, args ...interface{}) {
+		fmt.Fprintf(&screen, format, args...)
+		screen.WriteString(ansiClearLine + "\n")
+	}
+
+	screen.WriteString(ansiHome)
+	row("%sperf-test%s  %s  uptime %v", ansiBold, ansiReset, config.hostLabel, uptime.Round(time.Second))
+	if config.duration > 0 {
+		progress := newRunProgress(uptime, config.duration)
+		row("%s %3d%%  %s left", progress.bar(progressBarWidth), progress.percent, formatClock(progress.remaining))
+	}
+	row("")
+
+	switch {
+	case config.disableCPU:
+		row("%-8s disabled", "CPU")
+	case rotatingWorkloads(config):
+		for _, name := range cpuWorkloadList(config) {
+			row("%-8s %s %s", "CPU", name, workloadRate(name, snapshot.CPUWorkloadRates[name], config))
+		}
+	case config.cpuWorkload == "idle-spin":
+		row("%-8s jitter p99 %.1f µs, max %.1f µs", "CPU", snapshot.CPUJitterP99Micros, snapshot.CPUJitterMaxMicros)
+	case config.cpuWorkload == "prime":
+		row("%-8s %s primes/sec", "CPU", formatWithCommas(snapshot.CPUPrimesPerSec))
+	default:
+		row("%-8s %s %s", "CPU", config.cpuWorkload, opsWorkloads[config.cpuWorkload].rate(snapshot.CPUOpsPerSec, config))
+	}
+	if config.disableDisk {
+		row("%-8s disabled", "Memory")
+		row("%-8s disabled", "Disk")
+	} else {
+		row("%-8s fill %s", "Memory", formatMBps(snapshot.MemoryFillMBps, config.units))
+		row("%-8s write %s, read %s", "Disk",
+			formatMBps(snapshot.DiskWriteMBps, config.units), formatMBps(snapshot.DiskReadMBps, config.units))
+	}
+
+	row("")
+	row("%sRecent output%s", ansiBold, ansiReset)
+	for _, line := range lines {
+		row("%s", line)
+	}
+	screen.WriteString(ansiClearBelow)
+
+	io.WriteString(w, screen.String())
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDashboard(t *testing.T) {
+	var screen strings.Builder
+	snapshot := MetricsSnapshot{CPUPrimesPerSec: 1234567, MemoryFillMBps: 2048, DiskWriteMBps: 300, DiskReadMBps: 900}
+	config := Config{hostLabel: "db-01", cpuWorkload: "prime", units: "binary"}
+	renderDashboard(&screen, snapshot, config, 83*time.Second, []string{"Disk: write 300.00 MiB/s"})
+	output := screen.String()
+
+	if !strings.HasPrefix(output, ansiHome) || !strings.HasSuffix(output, ansiClearBelow) {
+		t.Errorf("renderDashboard() must redraw from the top and clear the rest of the screen: %q", output)
+	}
+	for _, expected := range []string{"db-01", "uptime 1m23s", "1,234,567 primes/sec", "fill 2048.00 MiB/s",
+		"write 300.00 MiB/s, read 900.00 MiB/s", "Disk: write 300.00 MiB/s"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("renderDashboard() output missing %q:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRenderDashboardProgress(t *testing.T) {
+	var screen strings.Builder
+	config := Config{cpuWorkload: "prime", duration: 2 * time.Minute}
+	renderDashboard(&screen, MetricsSnapshot{}, config, 30*time.Second, nil)
+	if expected := "[##########------------------------------]  25%  01:30 left"; !strings.Contains(screen.String(), expected) {
+		t.Errorf("renderDashboard() with -duration missing %q:\n%s", expected, screen.String())
+	}
+
+	screen.Reset()
+	renderDashboard(&screen, MetricsSnapshot{}, Config{cpuWorkload: "prime"}, 30*time.Second, nil)
+	if strings.Contains(screen.String(), "left") {
+		t.Errorf("renderDashboard() without -duration shows progress:\n%s", screen.String())
+	}
+}
+
+func TestRenderDashboardDisabled(t *testing.T) {
+	var screen strings.Builder
+	renderDashboard(&screen, MetricsSnapshot{}, Config{disableCPU: true, disableDisk: true}, 0, nil)
+	if strings.Count(screen.String(), "disabled") != 3 {
+		t.Errorf("renderDashboard() should mark CPU, memory and disk as disabled:\n%s", screen.String())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output.txt"))
+	if err != nil {
+		t.Fatalf("Cannot create file: %v", err)
+	}
+	defer file.Close()
+
+	if isTerminal(file) {
+		t.Errorf("isTerminal() = true for a regular file")
+	}
+}
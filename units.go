@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeSuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// Longest suffixes first so "MB" is not matched as "B"
+	{"KIB", 1024}, {"MIB", 1024 * 1024}, {"GIB", 1024 * 1024 * 1024}, {"TIB", 1024 * 1024 * 1024 * 1024},
+	{"KB", 1024}, {"MB", 1024 * 1024}, {"GB", 1024 * 1024 * 1024}, {"TB", 1024 * 1024 * 1024 * 1024},
+	{"K", 1024}, {"M", 1024 * 1024}, {"G", 1024 * 1024 * 1024}, {"T", 1024 * 1024 * 1024 * 1024},
+	{"B", 1},
+}
+
+// parseSize parses sizes like "4096", "4K", "10MB" or "1.5GiB" into bytes.
+// Suffixes are binary, matching the MB = 1024*1024 used throughout the tool.
+func parseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeSuffixes {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// sizeValue is a flag.Value accepting the size syntax of parseSize
+type sizeValue int64
+
+func (v *sizeValue) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *sizeValue) Set(s string) error {
+	size, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(size)
+	return nil
+}
+
+// formatBytes renders a byte count in the largest fitting unit of the
+// configured convention: binary (KiB, MiB, ...) or decimal (kB, MB, ...).
+func formatBytes(bytes int64, units string) string {
+	base, names := 1024.0, []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	if units == "decimal" {
+		base, names = 1000.0, []string{"B", "kB", "MB", "GB", "TB"}
+	}
+
+	value := float64(bytes)
+	i := 0
+	for value >= base && i < len(names)-1 {
+		value /= base
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.2f %s", value, names[i])
+}
+
+// formatMBps renders a throughput given in MiB/s, as computed throughout the
+// tool, as MiB/s or MB/s depending on the configured convention.
+func formatMBps(mbps float64, units string) string {
+	if units == "decimal" {
+		return fmt.Sprintf("%.2f MB/s", mbps*1024*1024/1e6)
+	}
+	return fmt.Sprintf("%.2f MiB/s", mbps)
+}
+
+// formatGBps is formatMBps for memory bandwidth, which is in the gigabytes
+func formatGBps(mbps float64, units string) string {
+	if units == "decimal" {
+		return fmt.Sprintf("%.2f GB/s", mbps*1024*1024/1e9)
+	}
+	return fmt.Sprintf("%.2f GiB/s", mbps/1024)
+}
@@ -0,0 +1,118 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   int64
+		shouldFail bool
+	}{
+		{"0", 0, false},
+		{"4096", 4096, false},
+		{"512B", 512, false},
+		{"4K", 4096, false},
+		{"4kb", 4096, false},
+		{"4KiB", 4096, false},
+		{"10MB", 10 * 1024 * 1024, false},
+		{"1.5G", 1536 * 1024 * 1024, false},
+		{"2 GiB", 2 * 1024 * 1024 * 1024, false},
+		{"1T", 1024 * 1024 * 1024 * 1024, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"ten", 0, true},
+		{"-1MB", 0, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseSize(test.input)
+		if (err != nil) != test.shouldFail {
+			t.Errorf("parseSize(%q) error = %v, expected shouldFail=%v", test.input, err, test.shouldFail)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("parseSize(%q) = %d, expected %d", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestSizeValue(t *testing.T) {
+	var size int64
+	value := (*sizeValue)(&size)
+
+	if err := value.Set("8MB"); err != nil {
+		t.Fatalf("sizeValue.Set(\"8MB\") returned error: %v", err)
+	}
+	if size != 8*1024*1024 {
+		t.Errorf("sizeValue.Set(\"8MB\") stored %d, expected %d", size, 8*1024*1024)
+	}
+	if value.String() != "8388608" {
+		t.Errorf("sizeValue.String() = %q, expected \"8388608\"", value.String())
+	}
+	if err := value.Set("bogus"); err == nil {
+		t.Errorf("sizeValue.Set(\"bogus\") expected an error")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		units    string
+		expected string
+	}{
+		{512, "binary", "512 B"},
+		{4096, "binary", "4.00 KiB"},
+		{100 * 1024 * 1024, "binary", "100.00 MiB"},
+		{3 * 1024 * 1024 * 1024 / 2, "binary", "1.50 GiB"},
+		{512, "decimal", "512 B"},
+		{4096, "decimal", "4.10 kB"},
+		{100 * 1024 * 1024, "decimal", "104.86 MB"},
+		{2000000000, "decimal", "2.00 GB"},
+		{5 * 1000 * 1000 * 1000 * 1000 * 1000, "decimal", "5000.00 TB"},
+	}
+
+	for _, test := range tests {
+		result := formatBytes(test.bytes, test.units)
+		if result != test.expected {
+			t.Errorf("formatBytes(%d, %q) = %q, expected %q", test.bytes, test.units, result, test.expected)
+		}
+	}
+}
+
+func TestFormatMBps(t *testing.T) {
+	tests := []struct {
+		mbps     float64
+		units    string
+		expected string
+	}{
+		{100, "binary", "100.00 MiB/s"},
+		{0.5, "binary", "0.50 MiB/s"},
+		{100, "decimal", "104.86 MB/s"},
+		{1000000 / (1024 * 1024.0), "decimal", "1.00 MB/s"},
+	}
+
+	for _, test := range tests {
+		result := formatMBps(test.mbps, test.units)
+		if result != test.expected {
+			t.Errorf("formatMBps(%g, %q) = %q, expected %q", test.mbps, test.units, result, test.expected)
+		}
+	}
+}
+
+func TestFormatGBps(t *testing.T) {
+	tests := []struct {
+		mbps     float64
+		units    string
+		expected string
+	}{
+		{10240, "binary", "10.00 GiB/s"},
+		{512, "binary", "0.50 GiB/s"},
+		{1e9 / (1024 * 1024.0), "decimal", "1.00 GB/s"},
+	}
+
+	for _, test := range tests {
+		if result := formatGBps(test.mbps, test.units); result != test.expected {
+			t.Errorf("formatGBps(%g, %q) = %q, expected %q", test.mbps, test.units, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// How often -post-results tries, and how long each attempt may take
+const (
+	postResultsAttempts = 3
+	postResultsTimeout  = 10 * time.Second
+	postResultsBackoff  = 2 * time.Second
+)
+
+// validResultsURL checks -post-results up front, so a typo does not only
+// show once a long run has finished
+func validResultsURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// postResults POSTs the JSON summary to url, retrying after backoff when the
+// collector cannot be reached or answers with a server error. Other non-2xx
+// responses fail at once, since sending the same payload again cannot help.
+func postResults(url string, summary Summary, attempts int, timeout, backoff time.Duration) error {
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	for attempt := 1; ; attempt++ {
+		retry, err := postResultsOnce(client, url, payload)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= attempts {
+			return fmt.Errorf("attempt %d of %d: %w", attempt, attempts, err)
+		}
+		time.Sleep(backoff)
+	}
+}
+
+// postResultsOnce sends payload once and reports whether a failure is worth
+// retrying
+func postResultsOnce(client *http.Client, url string, payload []byte) (bool, error) {
+	response, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	// Drain the body so the connection can be reused for a retry
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("server answered %s", response.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostResults(t *testing.T) {
+	var received Summary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Received %s with Content-Type %q, expected a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Payload is not a JSON summary: %v\n%s", err, body)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	summary := Summary{
+		SchemaVersion: CurrentSchemaVersion,
+		Host:          "node-1",
+		Tags:          map[string]string{"rack": "a1"},
+		Metrics:       MetricsSnapshot{CPUPrimesPerSec: 12345, DiskWriteMBps: 500},
+	}
+	if err := postResults(server.URL, summary, 3, time.Second, 0); err != nil {
+		t.Fatalf("postResults() failed: %v", err)
+	}
+	if received.SchemaVersion != CurrentSchemaVersion || received.Host != "node-1" || received.Tags["rack"] != "a1" ||
+		received.Metrics.CPUPrimesPerSec != 12345 || received.Metrics.DiskWriteMBps != 500 {
+		t.Errorf("Received summary %+v, expected the posted one", received)
+	}
+}
+
+func TestPostResultsRetries(t *testing.T) {
+	tests := []struct {
+		status   int
+		attempts int32
+	}{
+		// Server errors are retried until the attempts run out
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusTooManyRequests, 3},
+		// Client errors would fail the same way again
+		{http.StatusBadRequest, 1},
+	}
+
+	for _, test := range tests {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(test.status)
+		}))
+
+		if err := postResults(server.URL, Summary{}, 3, time.Second, 0); err == nil {
+			t.Errorf("postResults() answered with %d expected error", test.status)
+		}
+		if requests.Load() != test.attempts {
+			t.Errorf("postResults() answered with %d tried %d times, expected %d", test.status, requests.Load(), test.attempts)
+		}
+		server.Close()
+	}
+
+	// A server that recovers gets the results
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	if err := postResults(server.URL, Summary{}, 3, time.Second, 0); err != nil || requests.Load() != 2 {
+		t.Errorf("postResults() after one 502 = %v in %d requests, expected success in 2", err, requests.Load())
+	}
+}
+
+func TestPostResultsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	if err := postResults(server.URL, Summary{}, 2, 50*time.Millisecond, 0); err == nil {
+		t.Error("postResults() to a hanging server expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("postResults() to a hanging server took %v, expected the timeout to cut it short", elapsed)
+	}
+}
+
+func TestValidResultsURL(t *testing.T) {
+	for raw, valid := range map[string]bool{
+		"http://collector:8080/results": true,
+		"https://example.com/api":       true,
+		"collector:8080":                false,
+		"ftp://example.com":             false,
+		"http://":                       false,
+		"://bad":                        false,
+	} {
+		if result := validResultsURL(raw); result != valid {
+			t.Errorf("validResultsURL(%q) = %v, expected %v", raw, result, valid)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// usageGroups orders the flags by subsystem in -help. Flags missing here
+// are still listed under "Other".
+var usageGroups = []struct {
+	name  string
+	flags []string
+}{
+	{"Run", []string{"duration", "min-runtime", "strict", "warmup-iterations", "stagger-start", "shutdown-timeout", "sequential", "cooldown", "self-test", "quick-cpu", "burn-in", "resume", "checkpoint-interval", "checkpoint-file", "config", "dump-config", "merge", "merge-rank",
+		"disable-cpu", "disable-disk"}},
+	{"CPU", []string{"cpu-threads", "cpu-workload", "cpu-exec", "prime-range", "cpu-prime-start", "affinity-stride", "cpu-range-stagger", "cpu-range-sweep", "branchy-sorted", "memcpy-buffer", "regex-corpus-size", "collatz-range", "mandelbrot-size", "sort-size", "json-size", "crc-poly", "seed"}},
+	{"Memory", []string{"memory-percent", "memory-basis", "chunk-size", "mem-verify", "mem-scrub", "offheap", "allocate-upfront"}},
+	{"Disk", []string{"disk-path", "disk-workers-per-path", "disk-target", "disk-file-size", "disk-block-size", "disk-read-buffer", "disk-bs-sweep", "disk-mode", "disk-rw-mix",
+		"disk-fsync-interval", "disk-think-time", "disk-sync-latency", "disk-o-sync-every-write", "disk-direct-io-verify", "disk-sparse", "disk-test-pattern", "disk-compare-patterns", "disk-preallocate", "disk-latency-only", "disk-rotate-files", "disk-total-limit", "latency-samples"}},
+	{"Output", []string{"report-interval", "report-backoff", "report-backoff-max", "report-template", "full", "tui", "format", "table", "histogram", "histogram-buckets",
+		"units", "host-label", "tag", "output-file", "output-max-size", "output-max-files", "timestamps", "timestamp-tz", "openmetrics-file", "post-results", "post-results-required", "runtime-metrics", "pprof", "pprof-http"}},
+}
+
+var usageExamples = []struct {
+	description string
+	command     string
+}{
+	{"Quick CPU-only test", "perf-test -disable-disk -duration 30s"},
+	{"Disk-only soak test with bounded wear", "perf-test -disable-cpu -disk-path /mnt/data -duration 12h -disk-total-limit 2TB"},
+	{"JSON summary saved to a file", "perf-test -duration 5m -format json > result.json"},
+	{"Preflight check of every subsystem", "perf-test -self-test"},
+	{"Fleet statistics from saved JSON summaries", "perf-test -merge results/"},
+}
+
+func printUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s [flags]\n\n", flags.Name())
+	fmt.Fprintln(w, "Benchmarks CPU, memory and disk until interrupted or -duration elapses.")
+
+	listed := make(map[string]bool)
+	for _, group := range usageGroups {
+		var groupFlags []*flag.Flag
+		for _, name := range group.flags {
+			if f := flags.Lookup(name); f != nil {
+				groupFlags = append(groupFlags, f)
+				listed[name] = true
+			}
+		}
+		if len(groupFlags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", group.name)
+		for _, f := range groupFlags {
+			printFlagUsage(w, f)
+		}
+	}
+
+	var others []*flag.Flag
+	flags.VisitAll(func(f *flag.Flag) {
+		if !listed[f.Name] {
+			others = append(others, f)
+		}
+	})
+	if len(others) > 0 {
+		fmt.Fprintln(w, "\nOther:")
+		for _, f := range others {
+			printFlagUsage(w, f)
+		}
+	}
+
+	fmt.Fprintln(w, "\nExamples:")
+	for _, example := range usageExamples {
+		fmt.Fprintf(w, "  # %s\n  %s\n", example.description, example.command)
+	}
+}
+
+// printFlagUsage prints one flag in the layout of flag.PrintDefaults
+func printFlagUsage(w io.Writer, f *flag.Flag) {
+	name, usage := flag.UnquoteUsage(f)
+	line := "  -" + f.Name
+	if name != "" {
+		line += " " + name
+	}
+	line += "\n    \t" + strings.ReplaceAll(usage, "\n", "\n    \t")
+
+	if !isZeroValue(f) {
+		if name == "string" {
+			line += fmt.Sprintf(" (default %q)", f.DefValue)
+		} else {
+			line += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+	}
+	fmt.Fprintln(w, line)
+}
+
+// isZeroValue reports whether the flag's default is the zero value of its
+// type, which flag.PrintDefaults leaves out as well
+func isZeroValue(f *flag.Flag) bool {
+	typ := reflect.TypeOf(f.Value)
+	var zero reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		zero = reflect.New(typ.Elem())
+	} else {
+		zero = reflect.Zero(typ)
+	}
+	return f.DefValue == zero.Interface().(flag.Value).String()
+}
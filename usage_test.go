@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestPrintUsage(t *testing.T) {
+	flags := flag.NewFlagSet("perf-test", flag.ContinueOnError)
+	flags.Duration("duration", 0, "Stop after this long")
+	flags.String("disk-path", "./", "Path for disk benchmark files")
+	flags.Int("disk-rw-mix", -1, "Random mixed I/O with this percentage of `percent` reads")
+	flags.Bool("full", false, "Show full output")
+	flags.Bool("experimental", false, "Not in any group")
+
+	var buf bytes.Buffer
+	printUsage(&buf, flags)
+	output := buf.String()
+
+	expected := []string{
+		"Usage: perf-test [flags]",
+		"Run:\n  -duration duration\n    \tStop after this long\n",
+		"Disk:\n  -disk-path string\n    \tPath for disk benchmark files (default \"./\")\n",
+		"  -disk-rw-mix percent\n    \tRandom mixed I/O with this percentage of percent reads (default -1)\n",
+		"Output:\n  -full\n    \tShow full output\n",
+		"Other:\n  -experimental\n",
+		"Examples:\n",
+	}
+	for _, text := range expected {
+		if !strings.Contains(output, text) {
+			t.Errorf("printUsage() output missing %q:\n%s", text, output)
+		}
+	}
+
+	// Groups appear in subsystem order
+	if strings.Index(output, "Run:") > strings.Index(output, "Disk:") || strings.Index(output, "Disk:") > strings.Index(output, "Output:") {
+		t.Errorf("printUsage() groups out of order:\n%s", output)
+	}
+}
+
+func TestUsageGroupsUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for _, group := range usageGroups {
+		for _, name := range group.flags {
+			if other, ok := seen[name]; ok {
+				t.Errorf("Flag -%s is listed in both %s and %s", name, other, group.name)
+			}
+			seen[name] = group.name
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+)
+
+// validateConfig checks the flag values and their combinations after -burn-in,
+// -quick-cpu and the sweeps have filled in what they imply. It only looks at
+// the config; checks that touch the system, such as finding the -cpu-exec
+// command or opening the -disk-target, stay with the caller.
+func validateConfig(config Config, explicit map[string]bool) error {
+	if config.quickCPU && (explicit["format"] || explicit["cpu-workload"] || config.disableCPU || config.tui || config.sequential ||
+		len(config.cpuRangeSweep) > 0 || config.selfTest || config.burnIn || config.diskLatencyOnly) {
+		return errors.New("-quick-cpu cannot be combined with -format, -cpu-workload, -disable-cpu, -tui, -sequential, -cpu-range-sweep, -self-test, -burn-in or -disk-latency-only")
+	}
+
+	if config.memoryPercent < 0.1 || config.memoryPercent > 0.95 {
+		return errors.New("Memory percent must be between 0.1 and 0.95")
+	}
+
+	if config.memoryBasis != "available" && config.memoryBasis != "total" {
+		return errors.New("Memory basis must be available or total")
+	}
+
+	if config.cpuRangeStagger < 0 {
+		return errors.New("CPU range stagger must not be negative")
+	}
+
+	// Below 2 there are no numbers to test, so every iteration counts nothing
+	if config.primeRange < 2 {
+		return errors.New("Prime range must be at least 2")
+	}
+
+	if config.primeStart < 0 {
+		return errors.New("CPU prime start must not be negative")
+	}
+
+	if config.primeStart >= config.primeRange {
+		return errors.New("CPU prime start must be below the prime range")
+	}
+
+	if config.format != "text" && config.format != "json" && config.format != "none" {
+		return errors.New("Format must be text, json or none")
+	}
+
+	if config.outputMaxSize > 0 && config.outputFile == "" {
+		return errors.New("-output-max-size requires -output-file")
+	}
+
+	if config.outputMaxFiles < 1 {
+		return errors.New("Output max files must be at least 1")
+	}
+
+	if config.timestampTZ != "utc" && config.timestampTZ != "local" {
+		return errors.New("Timestamp time zone must be utc or local")
+	}
+
+	if config.timestamps && config.format == "json" {
+		return errors.New("-timestamps cannot be combined with -format json, whose summary would no longer parse")
+	}
+
+	if config.format == "none" && config.tui {
+		return errors.New("-tui cannot be combined with -format none")
+	}
+
+	seenWorkloads := make(map[string]bool)
+	for _, workload := range cpuWorkloadList(config) {
+		if !validCPUWorkload(workload) {
+			return fmt.Errorf("CPU workload must be one of: %s", strings.Join(cpuWorkloads, ", "))
+		}
+		if seenWorkloads[workload] {
+			return fmt.Errorf("CPU workload %s is listed twice", workload)
+		}
+		seenWorkloads[workload] = true
+	}
+	if len(seenWorkloads) == 0 {
+		return fmt.Errorf("CPU workload must be one of: %s", strings.Join(cpuWorkloads, ", "))
+	}
+
+	if config.cpuExec != "" {
+		if explicit["cpu-workload"] || config.disableCPU || len(config.cpuRangeSweep) > 0 || config.quickCPU {
+			return errors.New("-cpu-exec cannot be combined with -cpu-workload, -disable-cpu, -cpu-range-sweep or -quick-cpu")
+		}
+		args, err := execCommand(config)
+		if err != nil {
+			return fmt.Errorf("CPU exec command cannot be parsed: %v", err)
+		}
+		if len(args) == 0 {
+			return errors.New("CPU exec command must not be empty")
+		}
+	}
+
+	if rotatingWorkloads(config) && (hasCPUWorkload(config, "idle-spin") || config.resumeFile != "") {
+		return errors.New("Several CPU workloads cannot include idle-spin or be combined with -resume")
+	}
+
+	if hasCPUWorkload(config, "memcpy") && config.memcpyBuffer < 1 {
+		return errors.New("Memcpy buffer must be at least 1 byte")
+	}
+
+	if hasCPUWorkload(config, "regex") && config.regexCorpusSize < 1 {
+		return errors.New("Regex corpus size must be at least 1 byte")
+	}
+
+	if hasCPUWorkload(config, "mandelbrot") && (config.mandelbrotSize < 1 || config.mandelbrotSize > maxMandelbrotSize) {
+		return fmt.Errorf("Mandelbrot size must be between 1 and %d", maxMandelbrotSize)
+	}
+
+	if hasCPUWorkload(config, "collatz") && (config.collatzRange < 1 || config.collatzRange > maxCollatzRange) {
+		return fmt.Errorf("Collatz range must be between 1 and %d", maxCollatzRange)
+	}
+
+	if hasCPUWorkload(config, "sort") && config.sortSize < 1 {
+		return errors.New("Sort size must be at least 1")
+	}
+
+	if hasCPUWorkload(config, "json") && config.jsonSize < 1 {
+		return errors.New("JSON size must be at least 1 byte")
+	}
+
+	if _, ok := crcTables[config.crcPoly]; hasCPUWorkload(config, "crc") && !ok {
+		return errors.New("CRC polynomial must be ieee or castagnoli")
+	}
+
+	if config.units != "binary" && config.units != "decimal" {
+		return errors.New("Units must be binary or decimal")
+	}
+
+	if config.diskRWMix < -1 || config.diskRWMix > 100 {
+		return errors.New("Disk read/write mix must be between 0 and 100 (or -1 to disable)")
+	}
+
+	if err := checkChunkSize(config.chunkSizeMB, math.MaxInt); err != nil {
+		return fmt.Errorf("Invalid -chunk-size: %v", err)
+	}
+
+	if config.latencySamples < 1 {
+		return errors.New("Latency samples must be at least 1")
+	}
+
+	if config.diskFsyncEvery < 0 {
+		return errors.New("Disk fsync interval must not be negative")
+	}
+
+	if config.diskThinkTime < 0 {
+		return errors.New("Disk think time must not be negative")
+	}
+
+	if config.diskThinkTime > 0 && (config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config)) {
+		return errors.New("-disk-think-time cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries or -disk-workers-per-path")
+	}
+
+	if config.diskOSync && (config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config) ||
+		config.diskFsyncEvery > 0 || config.diskSyncLatency) {
+		return errors.New("-disk-o-sync-every-write cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries, -disk-workers-per-path, -disk-fsync-interval or -disk-sync-latency")
+	}
+
+	if config.diskDirectVerify {
+		if !directIOSupported {
+			return errors.New("-disk-direct-io-verify needs O_DIRECT, which is only available on Linux")
+		}
+		if directVerifyConflict(config) {
+			return errors.New("-disk-direct-io-verify cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries, -disk-workers-per-path, -disk-o-sync-every-write, -disk-fsync-interval, -disk-sync-latency, -disk-think-time, -disk-compare-patterns, -disk-rotate-files or -disk-bs-sweep")
+		}
+		if diskBlockSize(config)%directIOAlignment != 0 {
+			return errors.New("-disk-direct-io-verify needs a -disk-block-size that is a multiple of 4K")
+		}
+	}
+
+	if config.diskSparse && (config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config) || config.diskTarget != "" ||
+		config.diskPreallocate || config.diskDirectVerify || config.diskOSync || config.diskFsyncEvery > 0 || config.diskSyncLatency ||
+		config.diskThinkTime > 0 || config.diskComparePat || config.diskRotateFiles > 1 || len(config.diskBSSweep) > 0) {
+		return errors.New("-disk-sparse cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries, -disk-workers-per-path, -disk-target, -disk-preallocate, -disk-direct-io-verify, -disk-o-sync-every-write, -disk-fsync-interval, -disk-sync-latency, -disk-think-time, -disk-compare-patterns, -disk-rotate-files or -disk-bs-sweep")
+	}
+
+	if config.diskSyncLatency && (config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config)) {
+		return errors.New("-disk-sync-latency cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries or -disk-workers-per-path")
+	}
+
+	if config.checkpointEvery < 0 {
+		return errors.New("Checkpoint interval must not be negative")
+	}
+
+	if config.histBuckets < 1 {
+		return errors.New("Histogram buckets must be at least 1")
+	}
+
+	if config.histogram && config.format == "json" {
+		return errors.New("-histogram cannot be combined with -format json")
+	}
+
+	if config.table && config.format == "json" {
+		return errors.New("-table cannot be combined with -format json")
+	}
+
+	// A zero interval would print a report after every iteration
+	if config.reportInterval < 1 {
+		return errors.New("Report interval must be at least 1 second")
+	}
+
+	if config.reportTemplate != "" {
+		if config.tui {
+			return errors.New("-report-template cannot be combined with -tui")
+		}
+		if _, err := parseReportTemplate(config.reportTemplate); err != nil {
+			return fmt.Errorf("Invalid -report-template: %v", err)
+		}
+	}
+
+	if config.reportBackoff < 1 {
+		return errors.New("Report backoff must be at least 1")
+	}
+
+	if config.duration < 0 {
+		return errors.New("Duration must not be negative")
+	}
+
+	if config.minRuntime < 0 {
+		return errors.New("Minimum runtime must not be negative")
+	}
+
+	if config.strict && runtimeTooShort(config) {
+		return errors.New(runtimeTooShortMessage(config))
+	}
+
+	if len(config.cpuRangeSweep) > 0 {
+		if config.disableCPU || config.sequential {
+			return errors.New("CPU range sweep cannot be combined with -disable-cpu or -sequential")
+		}
+		if config.cpuWorkload != "prime" {
+			return errors.New("CPU range sweep requires the prime workload")
+		}
+		if config.cpuRangeSweep[0] <= config.primeStart {
+			return errors.New("CPU prime start must be below every range of the sweep")
+		}
+	}
+
+	if len(config.diskBSSweep) > 0 {
+		if config.disableDisk || config.sequential || len(config.cpuRangeSweep) > 0 || config.allocateUpfront || config.diskLatencyOnly {
+			return errors.New("-disk-bs-sweep cannot be combined with -disable-disk, -sequential, -cpu-range-sweep, -allocate-upfront or -disk-latency-only")
+		}
+		if config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config) || config.diskBlockSize > 0 || config.diskReadBuffer > 0 {
+			return errors.New("-disk-bs-sweep cannot be combined with -disk-rw-mix, -disk-mode append, several -disk-path entries, -disk-workers-per-path, -disk-block-size or -disk-read-buffer")
+		}
+	}
+
+	if config.diskMode != "rewrite" && config.diskMode != "append" {
+		return errors.New("Disk mode must be rewrite or append")
+	}
+
+	if config.diskReadBuffer > 0 && (config.diskRWMix >= 0 || config.diskMode == "append") {
+		return errors.New("-disk-read-buffer cannot be combined with -disk-rw-mix, which reads in -disk-block-size, or -disk-mode append, which does not read")
+	}
+
+	if config.postResults != "" && !validResultsURL(config.postResults) {
+		return errors.New("Post results URL must be an http or https URL")
+	}
+
+	if config.postRequired && config.postResults == "" {
+		return errors.New("-post-results-required needs -post-results")
+	}
+
+	if config.diskReadBuffer > math.MaxInt {
+		return errors.New("Disk read buffer is too large for this platform")
+	}
+
+	if config.diskMode == "append" && (config.diskTarget != "" || config.diskRWMix >= 0 || config.diskRotateFiles > 1) {
+		return errors.New("-disk-mode append cannot be combined with -disk-target, -disk-rw-mix or -disk-rotate-files")
+	}
+
+	if config.diskRotateFiles < 1 {
+		return errors.New("Disk rotate files must be at least 1")
+	}
+
+	if config.diskRotateFiles > 1 && (config.diskTarget != "" || config.diskRWMix >= 0) {
+		return errors.New("-disk-rotate-files cannot be combined with -disk-target or -disk-rw-mix")
+	}
+
+	if !validDiskPattern(config.diskPattern) {
+		return fmt.Errorf("Disk test pattern must be one of: %s", strings.Join(diskTestPatterns, ", "))
+	}
+
+	if (config.diskPattern != "random" || config.diskComparePat) && (config.diskRWMix >= 0 || config.diskMode == "append") {
+		return errors.New("-disk-test-pattern and -disk-compare-patterns cannot be combined with -disk-rw-mix or -disk-mode append, which write the memory fill pattern")
+	}
+
+	if config.diskComparePat && (config.diskPattern != "random" || parallelDisk(config) || config.burnIn) {
+		return errors.New("-disk-compare-patterns cannot be combined with -disk-test-pattern, several disk paths or workers, or -burn-in")
+	}
+
+	if config.diskLatencyOnly && (config.disableDisk || config.selfTest || config.diskTarget != "") {
+		return errors.New("-disk-latency-only cannot be combined with -disable-disk, -self-test or -disk-target")
+	}
+
+	if _, err := parseDiskPathList(config.diskPath); err != nil {
+		return fmt.Errorf("Invalid -disk-path: %v", err)
+	}
+
+	if config.diskPath == "auto" && config.diskTarget != "" {
+		return errors.New("-disk-path auto cannot be combined with -disk-target")
+	}
+
+	if config.warmupIters < 0 {
+		return errors.New("Warmup iterations must not be negative")
+	}
+
+	if config.staggerStart < 0 {
+		return errors.New("Stagger start must not be negative")
+	}
+
+	if config.warmupIters > 0 && !config.disableDisk && (config.diskRWMix >= 0 || config.diskMode == "append") {
+		return errors.New("-warmup-iterations cannot be combined with -disk-rw-mix or -disk-mode append, which have no disk iterations")
+	}
+
+	if config.diskWorkers < 1 {
+		return errors.New("Disk workers per path must be at least 1")
+	}
+
+	if parallelDisk(config) && (config.diskTarget != "" || config.diskRWMix >= 0 || config.diskMode == "append" ||
+		config.diskRotateFiles > 1 || config.diskPreallocate) {
+		return errors.New("Several disk paths or -disk-workers-per-path cannot be combined with -disk-target, -disk-rw-mix, -disk-mode append, -disk-rotate-files or -disk-preallocate")
+	}
+
+	if config.memScrub < 0 {
+		return errors.New("Memory scrub interval must not be negative")
+	}
+
+	if config.allocateUpfront && (config.sequential || config.disableDisk || len(config.cpuRangeSweep) > 0) {
+		return errors.New("-allocate-upfront cannot be combined with -sequential, -cpu-range-sweep or -disable-disk")
+	}
+
+	if config.memScrub > 0 && (config.sequential || config.disableDisk) {
+		return errors.New("-mem-scrub cannot be combined with -sequential or -disable-disk")
+	}
+
+	if config.shutdownTimeout < 0 {
+		return errors.New("Shutdown timeout must not be negative")
+	}
+
+	if config.cooldown < 0 {
+		return errors.New("Cooldown must not be negative")
+	}
+
+	if config.cooldown > 0 && !config.sequential {
+		return errors.New("-cooldown requires -sequential")
+	}
+
+	if config.sequential && config.duration == 0 {
+		return errors.New("Sequential mode requires -duration to be set")
+	}
+
+	if config.affinityStride < 0 {
+		return errors.New("Affinity stride must not be negative")
+	}
+	if config.affinityStride > 0 && runtime.GOOS != "linux" {
+		return errors.New("-affinity-stride is only supported on Linux")
+	}
+
+	return nil
+}
+
+// directVerifyConflict reports whether a flag is set that
+// -disk-direct-io-verify cannot be combined with
+func directVerifyConflict(config Config) bool {
+	return config.diskRWMix >= 0 || config.diskMode == "append" || parallelDisk(config) || config.diskOSync || config.diskFsyncEvery > 0 ||
+		config.diskSyncLatency || config.diskThinkTime > 0 || config.diskComparePat || config.diskRotateFiles > 1 || len(config.diskBSSweep) > 0
+}
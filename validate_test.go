@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string // part of the error, empty when the config is valid
+	}{
+		{nil, ""},
+		{[]string{"-memory-percent", "0.05"}, "Memory percent must be between 0.1 and 0.95"},
+		{[]string{"-memory-percent", "0.95"}, ""},
+		{[]string{"-prime-range", "1"}, "Prime range must be at least 2"},
+		{[]string{"-cpu-prime-start", "2000", "-prime-range", "1000"}, "CPU prime start must be below the prime range"},
+		{[]string{"-format", "xml"}, "Format must be text, json or none"},
+		{[]string{"-format", "none", "-tui"}, "-tui cannot be combined with -format none"},
+		{[]string{"-cpu-workload", "prime,sort"}, ""},
+		{[]string{"-cpu-workload", "prime,prime"}, "CPU workload prime is listed twice"},
+		{[]string{"-cpu-workload", "bogus"}, "CPU workload must be one of"},
+		{[]string{"-quick-cpu", "-format", "json"}, "-quick-cpu cannot be combined"},
+		{[]string{"-cpu-exec", "true", "-cpu-workload", "sort"}, "-cpu-exec cannot be combined"},
+		{[]string{"-cpu-exec", "'unterminated"}, "CPU exec command cannot be parsed"},
+		{[]string{"-report-template", "{{"}, "Invalid -report-template"},
+		{[]string{"-report-template", "{{.CPUPrimesPerSec}}", "-tui"}, "-report-template cannot be combined with -tui"},
+		{[]string{"-report-interval", "0"}, "Report interval must be at least 1 second"},
+		{[]string{"-disk-rw-mix", "101"}, "Disk read/write mix must be between 0 and 100"},
+		{[]string{"-duration", "5s", "-min-runtime", "10s"}, ""},
+		{[]string{"-duration", "5s", "-min-runtime", "10s", "-strict"}, "-duration 5s is shorter than -min-runtime 10s"},
+		{[]string{"-sequential"}, "Sequential mode requires -duration to be set"},
+		{[]string{"-cooldown", "1s"}, "-cooldown requires -sequential"},
+		{[]string{"-affinity-stride", "-1"}, "Affinity stride must not be negative"},
+	}
+
+	for _, test := range tests {
+		var config Config
+		flags := flag.NewFlagSet("perf-test", flag.ContinueOnError)
+		registerFlags(flags, &config)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("Parse(%q) failed: %v", test.args, err)
+		}
+		explicit := make(map[string]bool)
+		flags.Visit(func(f *flag.Flag) {
+			explicit[f.Name] = true
+		})
+
+		err := validateConfig(config, explicit)
+		switch {
+		case test.expected == "" && err != nil:
+			t.Errorf("validateConfig(%q) = %v, expected no error", test.args, err)
+		case test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)):
+			t.Errorf("validateConfig(%q) = %v, expected an error containing %q", test.args, err, test.expected)
+		}
+	}
+}
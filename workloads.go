@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var cpuWorkloads = []string{"prime", "idle-spin", "branchy", "memcpy", "pi", "regex", "sort", "json", "crc", "collatz", "mandelbrot"}
+
+// opsWorkload is a CPU workload measured in operations per second.
+// newIteration prepares the data of the given thread and returns a function that
+// runs one iteration and reports how many operations it completed.
+// formatRate renders a rate for workloads whose operations have a unit.
+type opsWorkload struct {
+	newIteration func(threadID int, config Config) func() int
+	formatRate   func(perSec float64, config Config) string
+}
+
+var opsWorkloads = map[string]opsWorkload{
+	"branchy":    {newIteration: newBranchyIteration},
+	"memcpy":     {newIteration: newMemcpyIteration, formatRate: formatBandwidth},
+	"pi":         {newIteration: newPiIteration, formatRate: formatPiRate},
+	"regex":      {newIteration: newRegexIteration, formatRate: formatRegexRate},
+	"sort":       {newIteration: newSortIteration, formatRate: formatSortRate},
+	"json":       {newIteration: newJSONIteration, formatRate: formatJSONRate},
+	"crc":        {newIteration: newCRCIteration, formatRate: formatCRCRate},
+	"collatz":    {newIteration: newCollatzIteration, formatRate: formatCollatzRate},
+	"mandelbrot": {newIteration: newMandelbrotIteration, formatRate: formatMandelbrotRate},
+	// Selected by -cpu-exec rather than -cpu-workload
+	"exec": {newIteration: newExecIteration, formatRate: formatExecRate},
+}
+
+func (w opsWorkload) rate(perSec float64, config Config) string {
+	if w.formatRate != nil {
+		return w.formatRate(perSec, config)
+	}
+	return formatWithCommas(perSec) + " ops/sec"
+}
+
+func validCPUWorkload(name string) bool {
+	for _, workload := range cpuWorkloads {
+		if workload == name {
+			return true
+		}
+	}
+	return false
+}
+
+// How long each idle-spin iteration asks to sleep
+const idleSpinInterval = 100 * time.Microsecond
+
+// JitterStats collects scheduler wakeup latencies from all idle-spin threads
+type JitterStats struct {
+	mu             sync.Mutex
+	samples        []time.Duration
+	lastReport     time.Time
+	reportInterval time.Duration
+}
+
+func newJitterStats(reportInterval time.Duration) *JitterStats {
+	return &JitterStats{lastReport: time.Now(), reportInterval: reportInterval}
+}
+
+// Add records a wakeup latency and, once a report is due, hands back the
+// samples collected since the previous report so memory stays bounded.
+func (j *JitterStats) Add(latency time.Duration, config Config) ([]time.Duration, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.samples = append(j.samples, latency)
+	if time.Since(j.lastReport) < j.reportInterval {
+		return nil, false
+	}
+
+	window := j.samples
+	j.samples = nil
+	j.lastReport = time.Now()
+	j.reportInterval = nextReportInterval(j.reportInterval, config)
+	return window, true
+}
+
+// jitterPercentiles sorts the samples in place and returns their p99 and max
+func jitterPercentiles(samples []time.Duration) (p99, max time.Duration) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return percentile(samples, 99), samples[len(samples)-1]
+}
+
+// benchmarkIdleSpin measures how late the scheduler wakes the thread up after
+// a short sleep, exercising the OS scheduler rather than the ALU.
+func benchmarkIdleSpin(threadID int, stopChan <-chan struct{}, config Config, jitterStats *JitterStats, metrics *Metrics) {
+	if config.full {
+		fmt.Printf("CPU Thread %d: Starting idle-spin\n", threadID)
+	}
+
+	wakeups := 0
+	for {
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("CPU Thread %d: Completed %d wakeups\n", threadID, wakeups)
+			}
+			return
+		default:
+			start := time.Now()
+			time.Sleep(idleSpinInterval)
+			latency := time.Since(start) - idleSpinInterval
+			wakeups++
+
+			window, due := jitterStats.Add(latency, config)
+			if !due {
+				continue
+			}
+
+			p99, max := jitterPercentiles(window)
+			metrics.Update(func(snapshot *MetricsSnapshot) {
+				snapshot.CPUJitterP99Micros = float64(p99) / float64(time.Microsecond)
+				snapshot.CPUJitterMaxMicros = float64(max) / float64(time.Microsecond)
+			})
+			fmt.Printf("Jitter: p99 %.1f µs, max %.1f µs\n",
+				float64(p99)/float64(time.Microsecond), float64(max)/float64(time.Microsecond))
+		}
+	}
+}
+
+// benchmarkOps runs an opsWorkload, aggregating operations across threads
+// the same way benchmarkPrimality aggregates primes.
+func benchmarkOps(threadID int, stopChan <-chan struct{}, config Config, workload opsWorkload, cpuStats *CPUStats) {
+	if config.full {
+		fmt.Printf("CPU Thread %d: Starting %s\n", threadID, config.cpuWorkload)
+	}
+
+	runIteration := workload.newIteration(threadID, config)
+	thread := cpuStats.Thread(threadID)
+	iteration := 0
+	lastReport := time.Now()
+	reportInterval := time.Duration(config.reportInterval) * time.Second
+	totalTime := time.Duration(0)
+
+	for {
+		select {
+		case <-stopChan:
+			if config.full {
+				fmt.Printf("CPU Thread %d: Completed %d iterations\n", threadID, iteration)
+			}
+			return
+		default:
+			start := time.Now()
+			ops := runIteration()
+			duration := time.Since(start)
+			iteration++
+			if !recordIteration(iteration, ops, duration, config, cpuStats, thread) {
+				continue
+			}
+			totalTime += duration
+
+			if config.full && time.Since(lastReport) >= reportInterval {
+				avgTime := totalTime / time.Duration(iteration-config.warmupIters)
+				opsPerSec := perSecond(float64(ops), duration)
+				fmt.Printf("CPU Thread %d: %d iterations, avg %.2fms/iter, %s\n",
+					threadID, iteration, avgTime.Seconds()*1000, workload.rate(opsPerSec, config))
+				lastReport = time.Now()
+				reportInterval = nextReportInterval(reportInterval, config)
+			}
+		}
+	}
+}
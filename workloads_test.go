@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidCPUWorkload(t *testing.T) {
+	for _, name := range []string{"prime", "idle-spin"} {
+		if !validCPUWorkload(name) {
+			t.Errorf("validCPUWorkload(%q) = false, expected true", name)
+		}
+	}
+	for _, name := range []string{"", "primes", "spin"} {
+		if validCPUWorkload(name) {
+			t.Errorf("validCPUWorkload(%q) = true, expected false", name)
+		}
+	}
+}
+
+func TestJitterPercentiles(t *testing.T) {
+	// 1..200µs in reverse order, p99 is the 198th smallest value
+	var samples []time.Duration
+	for i := 200; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Microsecond)
+	}
+
+	p99, max := jitterPercentiles(samples)
+	if p99 != 198*time.Microsecond {
+		t.Errorf("jitterPercentiles() p99 = %v, expected 198µs", p99)
+	}
+	if max != 200*time.Microsecond {
+		t.Errorf("jitterPercentiles() max = %v, expected 200µs", max)
+	}
+
+	if p99, max := jitterPercentiles(nil); p99 != 0 || max != 0 {
+		t.Errorf("jitterPercentiles(nil) = %v, %v, expected zero", p99, max)
+	}
+}
+
+func TestJitterStatsWindow(t *testing.T) {
+	config := Config{reportBackoff: 1, reportBackoffMax: time.Hour}
+	stats := newJitterStats(time.Hour)
+
+	for i := 0; i < 10; i++ {
+		if _, due := stats.Add(time.Microsecond, config); due {
+			t.Fatalf("JitterStats.Add() reported before the interval elapsed")
+		}
+	}
+
+	// Force the report to be due; the window holds every sample so far
+	stats.reportInterval = 0
+	window, due := stats.Add(time.Microsecond, config)
+	if !due || len(window) != 11 {
+		t.Errorf("JitterStats.Add() = %d samples, due=%v, expected 11 samples and due", len(window), due)
+	}
+	if len(stats.samples) != 0 {
+		t.Errorf("JitterStats kept %d samples after reporting, expected 0", len(stats.samples))
+	}
+}